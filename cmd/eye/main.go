@@ -20,6 +20,7 @@ import (
 	"github.com/droundy/goopt"
 	"github.com/mjolnir42/erebos"
 	"github.com/solnx/eye/internal/eye"
+	cfg "github.com/solnx/eye/internal/eye.cfg"
 	"github.com/solnx/eye/internal/eye.mock"
 	"github.com/solnx/eye/internal/eye.rest"
 )
@@ -67,6 +68,10 @@ func daemon() int {
 	if err = run.conf.FromFile(configurationFile); err != nil {
 		logrus.Fatal(err)
 	}
+	run.eyeConf = &cfg.Config{}
+	if err = run.eyeConf.FromFile(configurationFile); err != nil {
+		logrus.Fatal(err)
+	}
 
 	// open global default logger logfile
 	if lfhGlobal, err = reopen.NewFileWriter(
@@ -142,7 +147,7 @@ func daemon() int {
 	app.Start()
 
 	// start REST API
-	rst := rest.New(mock.AlwaysAuthorize, &hm, run.conf)
+	rst := rest.New(mock.AlwaysAuthorize, &hm, run.conf, run.eyeConf)
 	go rst.Run()
 
 	sigChanKill := make(chan os.Signal, 1)
@@ -15,10 +15,12 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/mjolnir42/erebos"
 	"github.com/solnx/eye/internal/eye"
+	cfg "github.com/solnx/eye/internal/eye.cfg"
 )
 
 type runtime struct {
 	conf        *erebos.Config
+	eyeConf     *cfg.Config
 	conn        *sql.DB
 	appLog      *logrus.Logger
 	errLog      *logrus.Logger
@@ -0,0 +1,46 @@
+-- SCHEMA VERSION UPGRADE: 201805070001 -> 201806010001
+--
+-- connect as owner of DB 'eye'
+\connect eye
+--
+-- feedback records the deployment feedback that has to be delivered
+-- to SOMA
+CREATE TABLE IF NOT EXISTS eye.feedback (
+  feedbackID              uuid            PRIMARY KEY,
+  requestID               uuid            NOT NULL,
+  configurationID         uuid            NULL,
+  task                    varchar(32)     NOT NULL,
+  outcome                 varchar(16)     NOT NULL CONSTRAINT valid_outcome CHECK ( outcome IN ( 'success', 'failed' ) ),
+  feedbackURL             text            NOT NULL,
+  status                  varchar(16)     NOT NULL CONSTRAINT valid_status CHECK ( status IN ( 'pending', 'failed', 'acknowledged' ) ),
+  attempts                integer         NOT NULL DEFAULT 0 CONSTRAINT valid_attempts CHECK ( attempts >= 0 ),
+  lastError               text            NULL,
+  createdAt               timestamptz(3)  NOT NULL DEFAULT NOW(),
+  lastAttemptAt           timestamptz(3)  NULL,
+  nextAttemptAt           timestamptz(3)  NOT NULL DEFAULT NOW(),
+  acknowledgedAt          timestamptz(3)  NULL,
+  CONSTRAINT createdAt_utc CHECK( EXTRACT( TIMEZONE FROM createdAt ) = '0' ),
+  CONSTRAINT lastAttemptAt_utc CHECK( EXTRACT( TIMEZONE FROM lastAttemptAt ) = '0' ),
+  CONSTRAINT nextAttemptAt_utc CHECK( EXTRACT( TIMEZONE FROM nextAttemptAt ) = '0' ),
+  CONSTRAINT acknowledgedAt_utc CHECK( EXTRACT( TIMEZONE FROM acknowledgedAt ) = '0' )
+);
+--
+-- create index to find feedback that is due for delivery
+CREATE INDEX _feedback_outstanding ON eye.feedback (
+  status,
+  nextAttemptAt
+);
+--
+-- register schema version installation
+INSERT INTO public.schema_versions (
+  schema,
+  version,
+  description
+) VALUES (
+  'eye',
+  201806010001,
+  'Schema migration via: schema-upgrade.201805070001:201806010001.sql'
+);
+--
+-- grant service user access to new tables
+GRANT INSERT,SELECT,UPDATE,DELETE ON ALL TABLES IN SCHEMA eye TO eye_service;
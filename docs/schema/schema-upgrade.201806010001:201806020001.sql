@@ -0,0 +1,59 @@
+-- SCHEMA VERSION UPGRADE: 201806010001 -> 201806020001
+--
+-- connect as owner of DB 'eye'
+\connect eye
+--
+-- subscription records the webhooks that are notified about changes
+CREATE TABLE IF NOT EXISTS eye.subscription (
+  subscriptionID          uuid            PRIMARY KEY,
+  url                     text            NOT NULL,
+  secret                  varchar(256)    NOT NULL,
+  section                 varchar(32)     NOT NULL CONSTRAINT valid_section CHECK ( section IN ( 'configuration', 'registration' ) ),
+  action                  varchar(32)     NULL,
+  team                    varchar(256)    NULL,
+  hostID                  numeric(16,0)   NULL,
+  createdAt               timestamptz(3)  NOT NULL DEFAULT NOW(),
+  CONSTRAINT createdAt_utc CHECK( EXTRACT( TIMEZONE FROM createdAt ) = '0' )
+);
+--
+-- subscription_delivery records the delivery of events to subscribed
+-- webhooks
+CREATE TABLE IF NOT EXISTS eye.subscription_delivery (
+  deliveryID              uuid            PRIMARY KEY,
+  subscriptionID          uuid            NOT NULL REFERENCES eye.subscription( subscriptionID ) ON DELETE CASCADE,
+  eventID                 uuid            NOT NULL,
+  eventType               varchar(128)    NOT NULL,
+  payload                 jsonb           NOT NULL,
+  status                  varchar(16)     NOT NULL CONSTRAINT valid_status CHECK ( status IN ( 'pending', 'failed', 'acknowledged' ) ),
+  attempts                integer         NOT NULL DEFAULT 0 CONSTRAINT valid_attempts CHECK ( attempts >= 0 ),
+  lastError               text            NULL,
+  createdAt               timestamptz(3)  NOT NULL DEFAULT NOW(),
+  lastAttemptAt           timestamptz(3)  NULL,
+  nextAttemptAt           timestamptz(3)  NOT NULL DEFAULT NOW(),
+  deliveredAt             timestamptz(3)  NULL,
+  UNIQUE ( subscriptionID, eventID ),
+  CONSTRAINT createdAt_utc CHECK( EXTRACT( TIMEZONE FROM createdAt ) = '0' ),
+  CONSTRAINT lastAttemptAt_utc CHECK( EXTRACT( TIMEZONE FROM lastAttemptAt ) = '0' ),
+  CONSTRAINT nextAttemptAt_utc CHECK( EXTRACT( TIMEZONE FROM nextAttemptAt ) = '0' ),
+  CONSTRAINT deliveredAt_utc CHECK( EXTRACT( TIMEZONE FROM deliveredAt ) = '0' )
+);
+--
+-- create index to find deliveries that are due
+CREATE INDEX _subscription_delivery_outstanding ON eye.subscription_delivery (
+  status,
+  nextAttemptAt
+);
+--
+-- register schema version installation
+INSERT INTO public.schema_versions (
+  schema,
+  version,
+  description
+) VALUES (
+  'eye',
+  201806020001,
+  'Schema migration via: schema-upgrade.201806010001:201806020001.sql'
+);
+--
+-- grant service user access to new tables
+GRANT INSERT,SELECT,UPDATE,DELETE ON ALL TABLES IN SCHEMA eye TO eye_service;
@@ -0,0 +1,32 @@
+-- SCHEMA VERSION UPGRADE: 201806020001 -> 201806030001
+--
+-- connect as owner of DB 'eye'
+\connect eye
+--
+-- changes records an ordered feed of configuration and activation
+-- changes. The changeID is assigned while holding a transaction level
+-- advisory lock, so that changeIDs become visible in commit order
+CREATE TABLE IF NOT EXISTS eye.changes (
+  changeID                bigserial       PRIMARY KEY,
+  section                 varchar(32)     NOT NULL CONSTRAINT valid_section CHECK ( section IN ( 'configuration', 'activation' ) ),
+  action                  varchar(32)     NOT NULL,
+  configurationID         uuid            NOT NULL REFERENCES eye.configurations( configurationID ) ON DELETE RESTRICT,
+  lookupID                char(64)        NOT NULL REFERENCES eye.lookup( lookupID ) ON DELETE RESTRICT,
+  changedAt               timestamptz(3)  NOT NULL DEFAULT NOW(),
+  CONSTRAINT changedAt_utc CHECK( EXTRACT( TIMEZONE FROM changedAt ) = '0' )
+);
+--
+-- register schema version installation
+INSERT INTO public.schema_versions (
+  schema,
+  version,
+  description
+) VALUES (
+  'eye',
+  201806030001,
+  'Schema migration via: schema-upgrade.201806020001:201806030001.sql'
+);
+--
+-- grant service user access to new tables
+GRANT INSERT,SELECT,UPDATE,DELETE ON ALL TABLES IN SCHEMA eye TO eye_service;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA eye TO eye_service;
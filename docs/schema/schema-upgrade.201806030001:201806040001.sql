@@ -0,0 +1,34 @@
+-- SCHEMA VERSION UPGRADE: 201806030001 -> 201806040001
+--
+-- connect as owner of DB 'eye'
+\connect eye
+--
+-- deployments records the SOMA deployments processed by eye, keyed by
+-- the SOMA deployment ID and task to detect repeated deliveries
+CREATE TABLE IF NOT EXISTS eye.deployments (
+  deploymentID            uuid            NOT NULL,
+  task                    varchar(32)     NOT NULL,
+  checkInstanceID         uuid            NOT NULL,
+  requestID               uuid            NOT NULL,
+  outcome                 varchar(16)     NOT NULL CONSTRAINT valid_outcome CHECK ( outcome IN ( 'processing', 'success', 'failed' ) ),
+  statusCode              numeric(3,0)    NULL,
+  receivedAt              timestamptz(3)  NOT NULL DEFAULT NOW(),
+  processedAt             timestamptz(3)  NULL,
+  PRIMARY KEY ( deploymentID, task ),
+  CONSTRAINT receivedAt_utc CHECK( EXTRACT( TIMEZONE FROM receivedAt ) = '0' ),
+  CONSTRAINT processedAt_utc CHECK( EXTRACT( TIMEZONE FROM processedAt ) = '0' )
+);
+--
+-- register schema version installation
+INSERT INTO public.schema_versions (
+  schema,
+  version,
+  description
+) VALUES (
+  'eye',
+  201806040001,
+  'Schema migration via: schema-upgrade.201806030001:201806040001.sql'
+);
+--
+-- grant service user access to new tables
+GRANT INSERT,SELECT,UPDATE,DELETE ON ALL TABLES IN SCHEMA eye TO eye_service;
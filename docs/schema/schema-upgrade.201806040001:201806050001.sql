@@ -0,0 +1,20 @@
+-- SCHEMA VERSION UPGRADE: 201806040001 -> 201806050001
+--
+-- connect as owner of DB 'eye'
+\connect eye
+--
+-- provisions record the origin of the provisioned configuration data
+ALTER TABLE eye.provisions ADD COLUMN source varchar(32) NOT NULL DEFAULT 'api';
+ALTER TABLE eye.provisions ADD COLUMN revision varchar(128) NULL;
+ALTER TABLE eye.provisions ADD COLUMN commitID varchar(64) NULL;
+--
+-- register schema version installation
+INSERT INTO public.schema_versions (
+  schema,
+  version,
+  description
+) VALUES (
+  'eye',
+  201806050001,
+  'Schema migration via: schema-upgrade.201806040001:201806050001.sql'
+);
@@ -0,0 +1,21 @@
+-- SCHEMA VERSION UPGRADE: 201806050001 -> 201806060001
+--
+-- connect as owner of DB 'eye'
+\connect eye
+--
+-- registrations carry a lease that expires unless it is renewed. A
+-- leaseTTL of 0 never expires, which keeps existing registrations.
+ALTER TABLE eye.registry ADD COLUMN leaseTTL integer NOT NULL DEFAULT 0 CONSTRAINT valid_lease CHECK ( leaseTTL >= 0 );
+ALTER TABLE eye.registry ADD COLUMN renewedAt timestamptz(3) NOT NULL DEFAULT NOW();
+ALTER TABLE eye.registry ADD CONSTRAINT renewedAt_utc CHECK( EXTRACT( TIMEZONE FROM renewedAt ) = '0' );
+--
+-- register schema version installation
+INSERT INTO public.schema_versions (
+  schema,
+  version,
+  description
+) VALUES (
+  'eye',
+  201806060001,
+  'Schema migration via: schema-upgrade.201806050001:201806060001.sql'
+);
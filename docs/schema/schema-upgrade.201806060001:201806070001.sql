@@ -0,0 +1,23 @@
+-- SCHEMA VERSION UPGRADE: 201806060001 -> 201806070001
+--
+-- connect as owner of DB 'eye'
+\connect eye
+--
+-- SOMA rolls out changed check instance configurations again under the
+-- same deployment ID with a new version. Deployments are keyed by
+-- version as well, records of existing deployments receive version 0.
+ALTER TABLE eye.deployments ADD COLUMN version numeric(20,0) NOT NULL DEFAULT 0;
+ALTER TABLE eye.deployments ALTER COLUMN version DROP DEFAULT;
+ALTER TABLE eye.deployments DROP CONSTRAINT deployments_pkey;
+ALTER TABLE eye.deployments ADD PRIMARY KEY ( deploymentID, version, task );
+--
+-- register schema version installation
+INSERT INTO public.schema_versions (
+  schema,
+  version,
+  description
+) VALUES (
+  'eye',
+  201806070001,
+  'Schema migration via: schema-upgrade.201806060001:201806070001.sql'
+);
@@ -0,0 +1,23 @@
+-- SCHEMA VERSION UPGRADE: 201806070001 -> 201806080001
+--
+-- connect as owner of DB 'eye'
+\connect eye
+--
+-- webhook deliveries with failed attempts stay pending until they
+-- are acknowledged. The failed status now marks deliveries that were
+-- given up after the maximum number of attempts, existing deliveries
+-- are retried again.
+UPDATE eye.subscription_delivery
+SET    status = 'pending'::varchar
+WHERE  status = 'failed'::varchar;
+--
+-- register schema version installation
+INSERT INTO public.schema_versions (
+  schema,
+  version,
+  description
+) VALUES (
+  'eye',
+  201806080001,
+  'Schema migration via: schema-upgrade.201806070001:201806080001.sql'
+);
@@ -0,0 +1,74 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+// Package cfg implements the eye specific configuration settings
+// that are not part of the shared erebos configuration. They are
+// read from the same configuration file.
+package cfg // import "github.com/solnx/eye/internal/eye.cfg"
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+
+	ucl "github.com/nahanni/go-ucl"
+)
+
+// Config holds the eye specific configuration
+type Config struct {
+	// Mapping contains the rules used to translate SOMA deployments
+	// into eye configurations
+	Mapping Mapping `json:"mapping"`
+	// Reconciliation configures the periodic comparison against SOMA
+	Reconciliation Reconciliation `json:"reconciliation"`
+	// Pull configures polling SOMA for pending deployments
+	Pull Pull `json:"pull"`
+	// GitOps configures the file based configuration source
+	GitOps GitOps `json:"gitops"`
+	// Push configures the verification of SOMA push notifications
+	Push Push `json:"push"`
+	// Registry configures the cache invalidation registry
+	Registry Registry `json:"registry"`
+}
+
+// FromFile sets Config c based on the file contents
+func (c *Config) FromFile(fname string) error {
+	var (
+		file, uclJSON []byte
+		err           error
+		uclData       map[string]interface{}
+	)
+	if file, err = ioutil.ReadFile(fname); err != nil {
+		return err
+	}
+
+	// UCL parses into map[string]interface{}
+	fileBytes := bytes.NewBuffer([]byte(file))
+	parser := ucl.NewParser(fileBytes)
+	if uclData, err = parser.Ucl(); err != nil {
+		return err
+	}
+
+	// take detour via JSON to load UCL into struct
+	if uclJSON, err = json.Marshal(uclData); err != nil {
+		return err
+	}
+	if err = json.Unmarshal([]byte(uclJSON), &c); err != nil {
+		return err
+	}
+
+	c.Reconciliation.setDefaults()
+	c.Pull.setDefaults()
+	c.GitOps.setDefaults()
+	c.Push.setDefaults()
+	c.Registry.setDefaults()
+	c.Mapping.setDefaults()
+	return c.Mapping.Validate()
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
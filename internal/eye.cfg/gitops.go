@@ -0,0 +1,34 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package cfg // import "github.com/solnx/eye/internal/eye.cfg"
+
+// GitOps configures the file based configuration source. Every YAML
+// or JSON file below Directory contains one configuration profile.
+type GitOps struct {
+	// Enabled starts watching Directory
+	Enabled bool `json:"enabled"`
+	// Directory is the checkout containing the profile files
+	Directory string `json:"directory"`
+	// Interval is the number of seconds between two scans
+	Interval uint64 `json:"interval.seconds"`
+	// AllowEmpty permits removing all file provisioned
+	// configurations if Directory contains no profile files. It
+	// guards against an empty or unmounted checkout.
+	AllowEmpty bool `json:"allow.empty"`
+}
+
+// setDefaults fills all unconfigured gitops settings with the
+// built-in defaults
+func (g *GitOps) setDefaults() {
+	if g.Interval == 0 {
+		g.Interval = 60
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
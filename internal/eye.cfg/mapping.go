@@ -0,0 +1,205 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package cfg // import "github.com/solnx/eye/internal/eye.cfg"
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mapping describes how a SOMA deployment is translated into an eye
+// configuration. All values are templates in which references of the
+// form {name} are replaced by deployment values. A template only
+// resolves if every reference it contains resolves to a non-empty
+// value. Supported references are:
+//
+//	{property.<name>}   value of the system property <name>
+//	{attribute.<name>}  value of the service attribute <name>
+//	{metric.path}       path of the deployed metric
+//	{node.name}         name of the node
+//	{node.name.absolute} name of the node if it has a trailing dot
+//	{node.assetid}      asset ID of the node
+//	{service.name}      name of the service
+//	{check.name}        name of the check configuration
+//	{team.name}         name of the owning team
+//	{monitoring.name}   name of the monitoring system
+//	{oncall.name}       name of the oncall duty
+//	{oncall.number}     phone number of the oncall duty
+type Mapping struct {
+	// Rewrites are applied to the metric path in order, the first
+	// matching rule wins
+	Rewrites []MetricRewrite `json:"metric.rewrites"`
+	// Fields maps deployment values onto configuration data fields
+	Fields FieldMapping `json:"fields"`
+	// Tags is the list of tag templates, every resolving template
+	// adds one tag to the configuration
+	Tags []string `json:"tags"`
+}
+
+// MetricRewrite is a rule that rewrites the metric path of matching
+// metrics
+type MetricRewrite struct {
+	// Metrics is the list of metric paths the rule applies to
+	Metrics []string `json:"metrics"`
+	// Template is the rewritten metric path
+	Template string `json:"template"`
+	// Required rejects deployments where Template does not resolve,
+	// otherwise the metric path is left unchanged
+	Required bool `json:"required"`
+}
+
+// FieldMapping holds the resolution order for configuration data
+// fields. The first resolving template of each list is used.
+type FieldMapping struct {
+	Targethost []string `json:"targethost"`
+	Source     []string `json:"source"`
+	Oncall     []string `json:"oncall"`
+}
+
+// mappingReferences are the fixed references supported in templates
+var mappingReferences = map[string]bool{
+	`metric.path`:        true,
+	`node.name`:          true,
+	`node.name.absolute`: true,
+	`node.assetid`:       true,
+	`service.name`:       true,
+	`check.name`:         true,
+	`team.name`:          true,
+	`monitoring.name`:    true,
+	`oncall.name`:        true,
+	`oncall.number`:      true,
+}
+
+// DefaultMapping returns the built-in mapping rules
+func DefaultMapping() Mapping {
+	return Mapping{
+		Rewrites: []MetricRewrite{
+			{
+				Metrics: []string{
+					`disk.write.per.second`,
+					`disk.read.per.second`,
+					`disk.free`,
+					`disk.usage.percent`,
+				},
+				Template: `{metric.path}:{attribute.filesystem}`,
+				Required: true,
+			},
+		},
+		Fields: FieldMapping{
+			Targethost: []string{
+				// specified fqdn has the highest priority
+				`{property.fqdn}`,
+				// trailing dot prevents appending the configured zone
+				`{node.name.absolute}`,
+				// configured zone is appended to the hostname
+				`{node.name}.{property.dns_zone}`,
+				// no better data available
+				`{node.name}`,
+			},
+			Source: []string{
+				`{service.name}, {check.name}`,
+				`System ({node.name}), {check.name}`,
+			},
+			Oncall: []string{
+				`{oncall.name} ({oncall.number})`,
+			},
+		},
+		Tags: []string{},
+	}
+}
+
+// setDefaults fills all unconfigured mapping sections with the
+// built-in defaults
+func (m *Mapping) setDefaults() {
+	def := DefaultMapping()
+	if m.Rewrites == nil {
+		m.Rewrites = def.Rewrites
+	}
+	if len(m.Fields.Targethost) == 0 {
+		m.Fields.Targethost = def.Fields.Targethost
+	}
+	if len(m.Fields.Source) == 0 {
+		m.Fields.Source = def.Fields.Source
+	}
+	if m.Fields.Oncall == nil {
+		m.Fields.Oncall = def.Fields.Oncall
+	}
+	if m.Tags == nil {
+		m.Tags = def.Tags
+	}
+}
+
+// Validate checks that all templates in m are well-formed
+func (m *Mapping) Validate() error {
+	for i := range m.Rewrites {
+		if len(m.Rewrites[i].Metrics) == 0 {
+			return fmt.Errorf("mapping: metric rewrite %d has no metrics", i)
+		}
+		if err := validateTemplate(m.Rewrites[i].Template); err != nil {
+			return fmt.Errorf("mapping: metric rewrite %d: %s", i, err)
+		}
+	}
+	for name, list := range map[string][]string{
+		`targethost`: m.Fields.Targethost,
+		`source`:     m.Fields.Source,
+		`oncall`:     m.Fields.Oncall,
+		`tags`:       m.Tags,
+	} {
+		for _, tmpl := range list {
+			if err := validateTemplate(tmpl); err != nil {
+				return fmt.Errorf("mapping: %s: %s", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// References returns the references contained in template tmpl
+func References(tmpl string) ([]string, error) {
+	refs := []string{}
+	for {
+		start := strings.Index(tmpl, `{`)
+		end := strings.Index(tmpl, `}`)
+		switch {
+		case start == -1 && end == -1:
+			return refs, nil
+		case start == -1 || end < start:
+			return nil, fmt.Errorf("unbalanced braces in template")
+		}
+		ref := tmpl[start+1 : end]
+		if ref == `` || strings.Contains(ref, `{`) {
+			return nil, fmt.Errorf("invalid reference in template")
+		}
+		refs = append(refs, ref)
+		tmpl = tmpl[end+1:]
+	}
+}
+
+// validateTemplate checks that tmpl only contains supported references
+func validateTemplate(tmpl string) error {
+	if tmpl == `` {
+		return fmt.Errorf("empty template")
+	}
+	refs, err := References(tmpl)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		switch {
+		case mappingReferences[ref]:
+		case strings.HasPrefix(ref, `property.`) && len(ref) > len(`property.`):
+		case strings.HasPrefix(ref, `attribute.`) && len(ref) > len(`attribute.`):
+		default:
+			return fmt.Errorf("unsupported reference {%s}", ref)
+		}
+	}
+	return nil
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
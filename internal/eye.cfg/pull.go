@@ -0,0 +1,36 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package cfg // import "github.com/solnx/eye/internal/eye.cfg"
+
+// Pull configures the pull-mode ingestion of SOMA deployments, for
+// eye instances that can not receive SOMA push notifications
+type Pull struct {
+	// Enabled starts polling SOMA for pending deployments
+	Enabled bool `json:"enabled"`
+	// MonitoringID is the SOMA monitoring system eye serves
+	MonitoringID string `json:"monitoring.id"`
+	// Path is the SOMA API path below which the pending deployments
+	// of a monitoring system are listed, the MonitoringID is appended
+	Path string `json:"path"`
+	// Interval is the number of seconds between two polls
+	Interval uint64 `json:"interval.seconds"`
+}
+
+// setDefaults fills all unconfigured pull settings with the built-in
+// defaults
+func (p *Pull) setDefaults() {
+	if p.Path == `` {
+		p.Path = `/deployments/pending`
+	}
+	if p.Interval == 0 {
+		p.Interval = 60
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
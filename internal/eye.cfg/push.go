@@ -0,0 +1,34 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package cfg // import "github.com/solnx/eye/internal/eye.cfg"
+
+// Push configures the acceptance of SOMA push notifications
+type Push struct {
+	// Secret is the shared secret used to verify the HMAC-SHA256
+	// signature of push notifications. If empty, notifications are
+	// accepted unsigned.
+	Secret string `json:"hmac.secret"`
+	// Window is the number of seconds a signed notification's
+	// timestamp may deviate from the local clock. Signatures seen
+	// within the window are rejected as replays.
+	Window uint64 `json:"replay.window.seconds"`
+	// PathPrefixes lists the SOMA API paths notifications may point
+	// eye to. If empty, all absolute paths are accepted.
+	PathPrefixes []string `json:"path.prefixes"`
+}
+
+// setDefaults fills all unconfigured push settings with the built-in
+// defaults
+func (p *Push) setDefaults() {
+	if p.Window == 0 {
+		p.Window = 300
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,39 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package cfg // import "github.com/solnx/eye/internal/eye.cfg"
+
+// Reconciliation configures the periodic comparison of the
+// deployments known to SOMA with the configurations valid in eye
+type Reconciliation struct {
+	// Enabled starts the periodic reconciliation job
+	Enabled bool `json:"enabled"`
+	// MonitoringID is the SOMA monitoring system eye serves
+	MonitoringID string `json:"monitoring.id"`
+	// Path is the SOMA API path below which the deployments of a
+	// monitoring system are listed, the MonitoringID is appended
+	Path string `json:"path"`
+	// Interval is the number of seconds between two runs
+	Interval uint64 `json:"interval.seconds"`
+	// Apply processes missing rollouts and deprovisions found
+	// during periodic runs, otherwise drift is only reported
+	Apply bool `json:"apply"`
+}
+
+// setDefaults fills all unconfigured reconciliation settings with
+// the built-in defaults
+func (r *Reconciliation) setDefaults() {
+	if r.Path == `` {
+		r.Path = `/deployments/monitoring`
+	}
+	if r.Interval == 0 {
+		r.Interval = 900
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
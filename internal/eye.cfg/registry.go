@@ -0,0 +1,71 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package cfg // import "github.com/solnx/eye/internal/eye.cfg"
+
+// Registry configures how the cache invalidation registry is kept in
+// sync with the registrations stored in the database
+type Registry struct {
+	// Interval is the number of seconds between two reloads of the
+	// registry, which also retries unreachable caches
+	Interval uint64 `json:"sync.interval.seconds"`
+	// LeaseTTL is the number of seconds a registration stays valid
+	// without renewal, if the client does not request a lease. The
+	// default of 0 grants no lease, registrations then never expire.
+	LeaseTTL uint64 `json:"lease.ttl.seconds"`
+	// ReapInterval is the number of seconds between two checks for
+	// expired registration leases
+	ReapInterval uint64 `json:"lease.reap.interval.seconds"`
+	// RetryLimit is the number of retries of a failed invalidation
+	// before all thresholds of the cache are flushed
+	RetryLimit uint64 `json:"invalidation.retry.limit"`
+	// RetryBackoff is the number of seconds before the first retry of
+	// a failed invalidation, doubled for every further retry
+	RetryBackoff uint64 `json:"invalidation.retry.backoff.seconds"`
+	// WriteThrough enables writing changed profiles into the
+	// registered caches instead of deleting them
+	WriteThrough bool `json:"invalidation.writethrough"`
+	// WriteTTL is the number of seconds profiles written into the
+	// registered caches stay valid
+	WriteTTL uint64 `json:"invalidation.writethrough.ttl.seconds"`
+	// PublishConnect is the host:port of the Redis invalidations are
+	// published on. Publishing is disabled if empty.
+	PublishConnect string `json:"invalidation.publish.connect"`
+	// PublishPassword is the password of the publish Redis
+	PublishPassword string `json:"invalidation.publish.password"`
+	// PublishDB is the database of the publish Redis
+	PublishDB int `json:"invalidation.publish.db"`
+	// PublishChannel is the pub/sub channel invalidations are
+	// published on
+	PublishChannel string `json:"invalidation.publish.channel"`
+}
+
+// setDefaults fills all unconfigured registry settings with the
+// built-in defaults
+func (r *Registry) setDefaults() {
+	if r.Interval == 0 {
+		r.Interval = 60
+	}
+	if r.ReapInterval == 0 {
+		r.ReapInterval = 60
+	}
+	if r.RetryLimit == 0 {
+		r.RetryLimit = 5
+	}
+	if r.RetryBackoff == 0 {
+		r.RetryBackoff = 1
+	}
+	if r.WriteTTL == 0 {
+		r.WriteTTL = 300
+	}
+	if r.PublishChannel == `` {
+		r.PublishChannel = `eye.invalidation`
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -31,45 +31,90 @@ const (
 
 // Sections in category global are unscoped sections
 const (
-	CategoryGlobal       = `global`
-	SectionConfiguration = `configuration`
-	SectionDeployment    = `deployment`
-	SectionLookup        = `lookup`
-	SectionRegistration  = `registration`
-	TaskClearing         = `clearing`
-	TaskDelete           = `delete`
-	TaskDeprovision      = `deprovision`
-	TaskPending          = `pending`
-	TaskRollout          = `rollout`
-	TaskUpdate           = `update`
+	CategoryGlobal        = `global`
+	SectionActivation     = `activation`
+	SectionChange         = `change`
+	SectionConfiguration  = `configuration`
+	SectionDeployment     = `deployment`
+	SectionFeedback       = `feedback`
+	SectionLookup         = `lookup`
+	SectionReconciliation = `reconciliation`
+	SectionRegistration   = `registration`
+	SectionSubscription   = `subscription`
+	SectionSync           = `sync`
+	TaskClearing          = `clearing`
+	TaskDelete            = `delete`
+	TaskDeprovision       = `deprovision`
+	TaskPending           = `pending`
+	TaskRollout           = `rollout`
+	TaskUpdate            = `update`
 )
 
 // Actions for the various permission sections
 const (
+	ActionAcknowledge   = `acknowledge`
 	ActionActivate      = `activate`
 	ActionActivation    = `activation`
 	ActionAdd           = `add`
 	ActionAuthenticate  = `authenticate`
 	ActionAuthorize     = `authorize`
+	ActionBatch         = `batch`
 	ActionConfiguration = `configuration`
+	ActionExpire        = `expire`
 	ActionHistory       = `history`
 	ActionList          = `list`
 	ActionNop           = `nop`
 	ActionNotification  = `notification`
 	ActionPending       = `pending`
 	ActionProcess       = `process`
+	ActionReconcile     = `reconcile`
 	ActionRegistration  = `registration`
+	ActionRenew         = `renew`
 	ActionRemove        = `remove`
+	ActionReplay        = `replay`
+	ActionReschedule    = `reschedule`
 	ActionSearch        = `search`
 	ActionShow          = `show`
+	ActionStream        = `stream`
+	ActionTranslate     = `translate`
 	ActionUpdate        = `update`
 	ActionVersion       = `version`
 )
 
+// Delivery states of deployment feedback
+const (
+	FeedbackAcknowledged = `acknowledged`
+	FeedbackFailed       = `failed`
+	FeedbackPending      = `pending`
+)
+
+// Delivery states of webhook events
+const (
+	DeliveryAcknowledged = `acknowledged`
+	DeliveryFailed       = `failed`
+	DeliveryPending      = `pending`
+)
+
+// Deployment outcomes reported as deployment feedback. Processing is
+// only used while a deployment is processed
+const (
+	OutcomeFailed     = `failed`
+	OutcomeProcessing = `processing`
+	OutcomeSuccess    = `success`
+)
+
+// Sources of provisioned configuration data
+const (
+	SourceAPI  = `api`
+	SourceFile = `file`
+	SourceSOMA = `soma`
+)
+
 // Result codes
 const (
 	ResultOK             = 200
 	ResultNoContent      = 204
+	ResultPartial        = 206
 	ResultBadRequest     = 400
 	ResultUnauthorized   = 401
 	ResultForbidden      = 403
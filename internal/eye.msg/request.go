@@ -9,6 +9,7 @@
 package msg // import "github.com/solnx/eye/internal/eye.msg"
 
 import (
+	"database/sql"
 	"net/http"
 	"strings"
 	"time"
@@ -36,12 +37,22 @@ type Request struct {
 		PathPrefix string
 	}
 
-	Flags  Flags
-	Search Search
+	Flags     Flags
+	Search    Search
+	Provision Provision
 
 	ConfigurationTask string
 	Configuration     v2.Configuration
 	Registration      v2.Registration
+	Feedback          v2.Feedback
+	Subscription      v2.Subscription
+	Delivery          v2.Delivery
+	Deployment        v2.Deployment
+	Batch             []Request
+	// Tx is the transaction of the batch chunk the request is part
+	// of. Handlers apply such requests within Tx and leave committing
+	// or rolling it back to the batch.
+	Tx *sql.Tx
 }
 
 // Flags represents the fully resolved proto.Request flags as they
@@ -54,10 +65,22 @@ type Flags struct {
 	Pending                bool
 }
 
+// Provision describes the origin of the configuration data
+// provisioned by this request
+type Provision struct {
+	Source   string
+	Revision string
+	Commit   string
+}
+
 // Search contains search paramaters for this request
 type Search struct {
 	Registration  v2.Registration
 	Configuration v2.Configuration
+	Feedback      v2.Feedback
+	Subscription  v2.Subscription
+	Change        v2.Change
+	Provision     Provision
 	ValidAt       time.Time
 	Since         time.Time
 }
@@ -82,4 +105,16 @@ func New(r *http.Request, params httprouter.Params) Request {
 	}
 }
 
+// NewInternal returns a Request for requests that are generated by
+// eye itself instead of being received via the REST API
+func NewInternal() Request {
+	return Request{
+		ID:       uuid.Must(uuid.NewV4()),
+		Time:     time.Now().UTC(),
+		AuthUser: `eye`,
+		Reply:    make(chan Result, 1),
+		Version:  ProtocolTwo,
+	}
+}
+
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
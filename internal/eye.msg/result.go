@@ -35,6 +35,17 @@ type Result struct {
 	ConfigurationTask string
 	Configuration     []v2.Configuration
 	Registration      []v2.Registration
+	Feedback          []v2.Feedback
+	Subscription      []v2.Subscription
+	Delivery          []v2.Delivery
+	Change            []v2.Change
+	Deployment        []v2.Deployment
+	Reconciliation    []v2.Reconciliation
+	Sync              []v2.Sync
+	Batch             []Result
+	// ChangeID is the highest changeID recorded in the change feed
+	// while processing the request, or 0 if nothing changed
+	ChangeID int64
 
 	fixated bool
 }
@@ -100,6 +111,12 @@ func (r *Result) OK() {
 	r.shrinkwrap(ResultOK, nil)
 }
 
+// Partial configures the result to reflect that the request was
+// processed, but some of its batched requests failed
+func (r *Result) Partial() {
+	r.shrinkwrap(ResultPartial, nil)
+}
+
 // NoContent configures the result to reflect that the request was
 // processed fully and the reply has been intentionally been left blank
 func (r *Result) NoContent() {
@@ -181,7 +198,10 @@ func (r *Result) shrinkwrap(code uint16, err error) {
 		err = fmt.Errorf(http.StatusText(int(code)))
 	}
 	r.setError(err)
-	r.clear()
+	// only failed requests can have partial results
+	if r.Code >= 400 {
+		r.clear()
+	}
 	r.fixated = true
 }
 
@@ -200,10 +220,22 @@ func (r *Result) clear() {
 		r.Configuration = []v2.Configuration{}
 	case SectionDeployment:
 		r.Configuration = []v2.Configuration{}
+		r.Deployment = []v2.Deployment{}
 	case SectionConfiguration:
 		r.Configuration = []v2.Configuration{}
 	case SectionRegistration:
 		r.Registration = []v2.Registration{}
+	case SectionFeedback:
+		r.Feedback = []v2.Feedback{}
+	case SectionSubscription:
+		r.Subscription = []v2.Subscription{}
+		r.Delivery = []v2.Delivery{}
+	case SectionChange:
+		r.Change = []v2.Change{}
+	case SectionReconciliation:
+		r.Reconciliation = []v2.Reconciliation{}
+	case SectionSync:
+		r.Sync = []v2.Sync{}
 	}
 }
 
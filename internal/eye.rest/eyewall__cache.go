@@ -9,9 +9,73 @@
 package rest // import "github.com/solnx/eye/internal/eye.rest"
 
 import (
+	"log"
+	"time"
+
 	msg "github.com/solnx/eye/internal/eye.msg"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+	wall "github.com/solnx/eye/lib/eye.wall"
 )
 
+// eyewallRegistrySync loads the cache invalidation registry from the
+// database at startup and periodically reloads it, which picks up
+// registrations made via other eye instances and retries caches that
+// were unreachable
+func (x *Rest) eyewallRegistrySync() {
+	ticker := time.NewTicker(time.Duration(x.registryConf.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if ShutdownInProgress {
+			return
+		}
+		if regs, err := x.eyewallRegistrations(); err != nil {
+			log.Println(`Registry`, `Error`, err.Error())
+		} else {
+			for _, err := range x.invl.Sync(regs) {
+				log.Println(`Registry`, `Error`, err.Error())
+			}
+			for _, err := range x.invl.Reconnect() {
+				log.Println(`Registry`, `Error`, err.Error())
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// eyewallRegistrations returns all registrations stored in the
+// database
+func (x *Rest) eyewallRegistrations() ([]v2.Registration, error) {
+	request := msg.NewInternal()
+	request.Section = msg.SectionRegistration
+	request.Action = msg.ActionSearch
+	// -1 is the unspecified database, since 0 is valid
+	request.Search.Registration.Database = -1
+
+	x.handlerMap.Get(`registration_r`).Intake() <- request
+	result := <-request.Reply
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return result.Registration, nil
+}
+
+// eyewallInvalidationRetry periodically retries failed cache
+// invalidations
+func (x *Rest) eyewallInvalidationRetry() {
+	ticker := time.NewTicker(x.invl.RetryBackoff)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if ShutdownInProgress {
+			return
+		}
+		for _, err := range x.invl.Retry() {
+			log.Println(`Invalidation`, `Error`, err.Error())
+		}
+	}
+}
+
 // eyewallCacheRegister adds a cache to the invalidation registry
 func (x *Rest) eyewallCacheRegister(r *msg.Result) {
 	switch r.Section {
@@ -23,6 +87,7 @@ func (x *Rest) eyewallCacheRegister(r *msg.Result) {
 	switch r.Action {
 	case msg.ActionAdd:
 	case msg.ActionUpdate:
+	case msg.ActionRenew:
 	default:
 		return
 	}
@@ -34,7 +99,9 @@ func (x *Rest) eyewallCacheRegister(r *msg.Result) {
 	}
 
 	reg := r.Registration[0]
-	x.invl.Register(reg.ID, reg.Address, reg.Port, reg.Database)
+	if err := x.invl.Register(reg.ID, reg.Address, reg.Port, reg.Database); err != nil {
+		log.Println(`Registry`, `Error`, err.Error())
+	}
 }
 
 // eyewallCacheUnregister removes a cache from the invalidation registry
@@ -90,7 +157,9 @@ func (x *Rest) eyewallCacheInvalidate(r *msg.Result) {
 		// asynchronous active cache invalidation, since no
 		// clearing action depends on the invalidation having been
 		// performed
-		x.invl.AsyncInvalidate(r.Configuration[0].LookupID)
+		go func(lookupID string, changeID int64) {
+			wall.Drain(x.eyewallCacheUpdate(lookupID, changeID))
+		}(r.Configuration[0].LookupID, r.ChangeID)
 		return
 	}
 
@@ -99,14 +168,45 @@ func (x *Rest) eyewallCacheInvalidate(r *msg.Result) {
 	// synchronous active cache invalidation, since the
 	// clearing has to be blocked until the invalidation has been
 	// performed
-	done, errors := x.invl.Invalidate(r.Configuration[0].LookupID)
-	for {
-		select {
-		case <-errors:
-		case <-done:
-			break
-		}
+	for _, err := range wall.Drain(
+		x.eyewallCacheUpdate(r.Configuration[0].LookupID, r.ChangeID),
+	) {
+		log.Println(`Invalidation`, `Error`, err.Error())
+	}
+}
+
+// eyewallCacheUpdate updates lookupID in all registered caches after
+// the change changeID. In write-through mode the currently valid
+// configurations are written into the caches, versioned by changeID.
+// Otherwise, if no change was recorded or if they can not be loaded
+// lookupID is invalidated.
+func (x *Rest) eyewallCacheUpdate(lookupID string, changeID int64) (chan struct{}, chan error) {
+	if !x.registryConf.WriteThrough || changeID == 0 {
+		return x.invl.Invalidate(lookupID)
+	}
+
+	request := msg.NewInternal()
+	request.Section = msg.SectionLookup
+	request.Action = msg.ActionConfiguration
+	request.LookupHash = lookupID
+
+	x.handlerMap.Get(`lookup_r`).Intake() <- request
+	result := <-request.Reply
+
+	// changeIDs increase monotonically across all eye instances and
+	// the configurations are loaded after changeID was committed, a
+	// write of a later change always carries a higher version
+	switch result.Code {
+	case msg.ResultOK:
+		return x.invl.Update(lookupID, result.Configuration, changeID)
+	case msg.ResultNotFound:
+		// no valid configurations remain, write negative entry
+		return x.invl.Update(lookupID, nil, changeID)
+	}
+	if result.Error != nil {
+		log.Println(`Invalidation`, `Error`, result.Error.Error())
 	}
+	return x.invl.Invalidate(lookupID)
 }
 
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
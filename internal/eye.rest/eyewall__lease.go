@@ -0,0 +1,56 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"log"
+	"time"
+
+	msg "github.com/solnx/eye/internal/eye.msg"
+)
+
+// eyewallLeaseReaper periodically deletes registrations whose lease
+// has expired and removes them from the invalidation registry
+func (x *Rest) eyewallLeaseReaper() {
+	ticker := time.NewTicker(time.Duration(x.registryConf.ReapInterval) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if ShutdownInProgress {
+			return
+		}
+
+		request := msg.NewInternal()
+		request.Section = msg.SectionRegistration
+		request.Action = msg.ActionExpire
+
+		x.handlerMap.Get(`registration_w`).Intake() <- request
+		result := <-request.Reply
+		if result.Error != nil {
+			log.Println(`Registry`, `Error`, result.Error.Error())
+			continue
+		}
+
+		for _, reg := range result.Registration {
+			log.Println(`Registry`, `Expired`, reg.ID)
+			x.invl.Unregister(reg.ID)
+		}
+	}
+}
+
+// registrationLease applies the configured default lease to
+// registration requests that do not request one. Without a configured
+// default, such registrations are granted no lease and never expire.
+func (x *Rest) registrationLease(q *msg.Request) {
+	if q.Registration.LeaseTTL <= 0 {
+		q.Registration.LeaseTTL = int64(x.registryConf.LeaseTTL)
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,429 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	uuid "github.com/satori/go.uuid"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	eyeproto "github.com/solnx/eye/lib/eye.proto"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// gitopsNamespace is the namespace of the name based configurationIDs
+// of file provisioned configurations
+var gitopsNamespace = uuid.Must(uuid.FromString(`8b7f3a52-4c1e-5d0b-9a5e-2f1c6e0d7b43`))
+
+// Actions performed for a profile file during synchronization
+const (
+	gitopsActionAdd    = `add`
+	gitopsActionUpdate = `update`
+	gitopsActionRemove = `remove`
+	gitopsActionNone   = `none`
+	gitopsActionError  = `error`
+)
+
+// gitopsState holds the state of the file based configuration source
+type gitopsState struct {
+	// run serializes synchronization runs
+	run sync.Mutex
+	// lock protects status
+	lock   sync.RWMutex
+	status *v2.Sync
+}
+
+// gitopsProfile is the format of a configuration profile file
+type gitopsProfile struct {
+	HostID uint64 `json:"hostID"`
+	Metric string `json:"metric"`
+	Data   struct {
+		Interval   uint64   `json:"interval"`
+		Monitoring string   `json:"monitoring"`
+		Team       string   `json:"team"`
+		Oncall     string   `json:"oncall"`
+		Source     string   `json:"source"`
+		Targethost string   `json:"targethost"`
+		Tags       []string `json:"tags"`
+	} `json:"data"`
+	Thresholds []v2.Threshold `json:"thresholds"`
+}
+
+// gitopsWatch periodically synchronizes the profile files with eye if
+// the file based configuration source is enabled
+func (x *Rest) gitopsWatch() {
+	if !x.gitopsConf.Enabled {
+		return
+	}
+
+	x.gitopsSync()
+	ticker := time.NewTicker(time.Duration(x.gitopsConf.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if ShutdownInProgress {
+			return
+		}
+		x.gitopsSync()
+	}
+}
+
+// gitopsStatus returns the status of the last synchronization, or nil
+// if none has finished yet
+func (x *Rest) gitopsStatus() *v2.Sync {
+	x.gitops.lock.RLock()
+	defer x.gitops.lock.RUnlock()
+	return x.gitops.status
+}
+
+// gitopsSync performs one synchronization of the profile files with
+// the file provisioned configurations. Configurations whose file was
+// removed are only removed if all files could be read, since the
+// configurationID of an unreadable file is unknown. If no files are
+// found at all, nothing is removed unless this is explicitly allowed.
+func (x *Rest) gitopsSync() {
+	x.gitops.run.Lock()
+	defer x.gitops.run.Unlock()
+
+	status := &v2.Sync{
+		Directory: x.gitopsConf.Directory,
+		Commit:    gitopsCommit(x.gitopsConf.Directory),
+		StartedAt: time.Now().UTC().Format(eyeproto.RFC3339Milli),
+		Files:     []v2.SyncFile{},
+	}
+	defer func() {
+		status.FinishedAt = time.Now().UTC().Format(eyeproto.RFC3339Milli)
+		x.gitops.lock.Lock()
+		x.gitops.status = status
+		x.gitops.lock.Unlock()
+	}()
+
+	paths, err := gitopsFiles(x.gitopsConf.Directory)
+	if err != nil {
+		status.Errors = append(status.Errors, err.Error())
+		return
+	}
+
+	provisioned, err := x.configurationsFromSource(msg.SourceFile)
+	if err != nil {
+		status.Errors = append(status.Errors, err.Error())
+		return
+	}
+
+	failed := false
+	seen := map[string]string{}
+	for _, path := range paths {
+		file := v2.SyncFile{
+			Path:   path,
+			Action: gitopsActionNone,
+		}
+
+		var configuration v2.Configuration
+		var revision string
+		if configuration, revision, err = gitopsLoad(
+			filepath.Join(x.gitopsConf.Directory, path),
+		); err != nil {
+			file.Action = gitopsActionError
+			file.Error = err.Error()
+			status.Files = append(status.Files, file)
+			failed = true
+			continue
+		}
+		file.ConfigurationID = configuration.ID
+		file.Revision = revision
+
+		if other, ok := seen[configuration.ID]; ok {
+			file.Action = gitopsActionError
+			file.Error = fmt.Sprintf("Profile for host %d metric %s is already defined in %s",
+				configuration.HostID, configuration.Metric, other)
+			status.Files = append(status.Files, file)
+			failed = true
+			continue
+		}
+		seen[configuration.ID] = path
+
+		// unchanged files are not provisioned again
+		if current, ok := provisioned[configuration.ID]; ok && current == revision {
+			status.Files = append(status.Files, file)
+			continue
+		}
+
+		request := msg.NewInternal()
+		request.Section = msg.SectionConfiguration
+		request.Action = msg.ActionAdd
+		file.Action = gitopsActionAdd
+		if _, ok := provisioned[configuration.ID]; ok {
+			request.Action = msg.ActionUpdate
+			file.Action = gitopsActionUpdate
+		}
+		request.Configuration = configuration
+		request.LookupHash = configuration.LookupID
+		request.Flags.CacheInvalidation = true
+		request.Provision = msg.Provision{
+			Source:   msg.SourceFile,
+			Revision: revision,
+			Commit:   status.Commit,
+		}
+
+		if err = x.gitopsApply(&request); err != nil {
+			file.Error = err.Error()
+			failed = true
+		}
+		status.Files = append(status.Files, file)
+	}
+
+	// remove configurations whose profile file is gone
+	if failed {
+		status.Errors = append(status.Errors,
+			`Removal of configurations skipped due to failed files`)
+		return
+	}
+	if len(paths) == 0 && len(provisioned) > 0 && !x.gitopsConf.AllowEmpty {
+		status.Errors = append(status.Errors,
+			`Removal of configurations skipped since no profile files were found`)
+		return
+	}
+	status.Pruned = true
+
+	removed := []string{}
+	for configurationID := range provisioned {
+		if _, ok := seen[configurationID]; !ok {
+			removed = append(removed, configurationID)
+		}
+	}
+	sort.Strings(removed)
+	for _, configurationID := range removed {
+		file := v2.SyncFile{
+			ConfigurationID: configurationID,
+			Action:          gitopsActionRemove,
+		}
+
+		request := msg.NewInternal()
+		request.Section = msg.SectionConfiguration
+		request.Action = msg.ActionRemove
+		request.Configuration.ID = configurationID
+		request.Flags.CacheInvalidation = true
+
+		if err = x.gitopsApply(&request); err != nil {
+			file.Error = err.Error()
+		}
+		status.Files = append(status.Files, file)
+	}
+	return
+}
+
+// gitopsApply sends request q to the configuration write handler and
+// performs the same post-processing as requests received via the API
+func (x *Rest) gitopsApply(q *msg.Request) error {
+	x.handlerMap.Get(`configuration_w`).Intake() <- *q
+	result := <-q.Reply
+	x.exportV2(&result)
+	x.postProcess(&result)
+	if result.Error != nil {
+		log.Println(`GitOps`, `RequestID`, result.ID, `ConfigurationID`,
+			q.Configuration.ID, `Error`, result.Error.Error())
+	}
+	return result.Error
+}
+
+// configurationsFromSource returns the revisions of all currently
+// valid configurations provisioned from source, keyed by
+// configurationID
+func (x *Rest) configurationsFromSource(source string) (map[string]string, error) {
+	request := msg.NewInternal()
+	request.Section = msg.SectionConfiguration
+	request.Action = msg.ActionList
+	request.Search.Provision.Source = source
+
+	x.handlerMap.Get(`configuration_r`).Intake() <- request
+	result := <-request.Reply
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	configurations := map[string]string{}
+	for _, configuration := range result.Configuration {
+		configurations[configuration.ID] = configuration.Data[0].Info.Revision
+	}
+	return configurations, nil
+}
+
+// gitopsFiles returns the paths of all profile files below dir,
+// relative to dir. Hidden files and directories are skipped.
+func gitopsFiles(dir string) ([]string, error) {
+	paths := []string{}
+	if dir == `` {
+		return nil, fmt.Errorf("GitOps: no directory configured")
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if path != dir && strings.HasPrefix(info.Name(), `.`) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case `.yaml`, `.yml`, `.json`:
+		default:
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// gitopsLoad reads and validates the profile file at path. It returns
+// the configuration and the content hash of the file.
+func gitopsLoad(path string) (v2.Configuration, string, error) {
+	var (
+		profile gitopsProfile
+		content []byte
+		doc     []byte
+		err     error
+	)
+
+	if content, err = ioutil.ReadFile(path); err != nil {
+		return v2.Configuration{}, ``, err
+	}
+	hash := sha256.Sum256(content)
+	revision := hex.EncodeToString(hash[:])
+
+	// JSON is valid YAML, both formats are decoded via JSON
+	if doc, err = yaml.YAMLToJSON(content); err != nil {
+		return v2.Configuration{}, ``, err
+	}
+	if err = json.Unmarshal(doc, &profile); err != nil {
+		return v2.Configuration{}, ``, err
+	}
+
+	switch {
+	case profile.HostID == 0:
+		return v2.Configuration{}, ``, fmt.Errorf("Profile is missing hostID")
+	case profile.Metric == ``:
+		return v2.Configuration{}, ``, fmt.Errorf("Profile is missing metric")
+	case profile.Data.Interval == 0:
+		return v2.Configuration{}, ``, fmt.Errorf("Profile is missing data.interval")
+	case len(profile.Thresholds) == 0:
+		return v2.Configuration{}, ``, fmt.Errorf("Profile has no thresholds")
+	}
+	for _, thr := range profile.Thresholds {
+		switch thr.Predicate {
+		case `<`, `<=`, `==`, `>=`, `>`, `!=`:
+		default:
+			return v2.Configuration{}, ``, fmt.Errorf("Invalid threshold predicate: %s", thr.Predicate)
+		}
+	}
+
+	lookupID := calculateLookupID(profile.HostID, profile.Metric)
+	data := v2.Data{
+		Interval:   profile.Data.Interval,
+		Monitoring: profile.Data.Monitoring,
+		Team:       profile.Data.Team,
+		Oncall:     profile.Data.Oncall,
+		Source:     profile.Data.Source,
+		Targethost: profile.Data.Targethost,
+		Tags:       profile.Data.Tags,
+		Thresholds: profile.Thresholds,
+	}
+	if data.Source == `` {
+		data.Source = fmt.Sprintf("file (%s)", filepath.Base(path))
+	}
+
+	configuration := v2.Configuration{
+		ID:       uuid.NewV5(gitopsNamespace, lookupID).String(),
+		HostID:   profile.HostID,
+		LookupID: lookupID,
+		Metric:   profile.Metric,
+		Data:     []v2.Data{data},
+	}
+
+	// the configurationID is derived from the lookupID and always valid
+	if err = validateConfiguration(&configuration); err != nil {
+		return v2.Configuration{}, ``, err
+	}
+	return configuration, revision, nil
+}
+
+// gitopsCommit returns the commit checked out in the git repository
+// containing dir, or the empty string if there is none
+func gitopsCommit(dir string) string {
+	var gitDir string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if info, err := os.Stat(filepath.Join(d, `.git`)); err == nil && info.IsDir() {
+			gitDir = filepath.Join(d, `.git`)
+			break
+		}
+		if d == filepath.Dir(d) {
+			return ``
+		}
+	}
+
+	head, err := ioutil.ReadFile(filepath.Join(gitDir, `HEAD`))
+	if err != nil {
+		return ``
+	}
+	ref := strings.TrimSpace(string(head))
+	if !strings.HasPrefix(ref, `ref: `) {
+		// detached HEAD
+		return ref
+	}
+	ref = strings.TrimPrefix(ref, `ref: `)
+
+	// loose reference
+	var commit []byte
+	if commit, err = ioutil.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(commit))
+	}
+
+	// packed reference
+	var packed *os.File
+	if packed, err = os.Open(filepath.Join(gitDir, `packed-refs`)); err != nil {
+		return ``
+	}
+	defer packed.Close()
+	scanner := bufio.NewScanner(packed)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0]
+		}
+	}
+	return ``
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
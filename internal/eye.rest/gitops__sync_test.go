@@ -0,0 +1,162 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mjolnir42/erebos"
+	"github.com/solnx/eye/internal/eye"
+	cfg "github.com/solnx/eye/internal/eye.cfg"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	wall "github.com/solnx/eye/lib/eye.wall"
+)
+
+const testGitopsProfile = `
+hostID: 42
+metric: cpu.usage.percent
+data:
+  interval: 60
+  monitoring: monitoring
+  team: team
+  targethost: host.example.org
+thresholds:
+  - predicate: ">="
+    level: 3
+    value: 90
+`
+
+// testGitops records the configuration writes of a GitOps test
+type testGitops struct {
+	lock    sync.Mutex
+	written []msg.Request
+}
+
+// write answers the requests of the configuration_w handler
+func (tg *testGitops) write(q *msg.Request) msg.Result {
+	tg.lock.Lock()
+	defer tg.lock.Unlock()
+
+	tg.written = append(tg.written, *q)
+	result := msg.FromRequest(q)
+	result.Configuration = append(result.Configuration, q.Configuration)
+	result.OK()
+	return result
+}
+
+// testGitopsRest returns a Rest that synchronizes the profile files in
+// dir and applies them via tg
+func testGitopsRest(dir string, tg *testGitops) *Rest {
+	conf := &erebos.Config{}
+	empty := &testReconcile{}
+
+	hm := &eye.HandlerMap{}
+	hm.Add(`configuration_r`, newTestHandler(empty.empty))
+	hm.Add(`configuration_w`, newTestHandler(tg.write))
+	hm.Add(`subscription_r`, newTestHandler(empty.empty))
+
+	return &Rest{
+		handlerMap:   hm,
+		conf:         conf,
+		webhookWake:  make(chan struct{}, 1),
+		invl:         wall.NewInvalidation(conf),
+		registryConf: &cfg.Registry{},
+		gitopsConf:   &cfg.GitOps{Enabled: true, Directory: dir},
+		gitops:       &gitopsState{},
+	}
+}
+
+func TestGitopsSyncApply(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `gitops`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, `cpu.yaml`),
+		[]byte(testGitopsProfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tg := &testGitops{}
+	x := testGitopsRest(dir, tg)
+	x.gitopsSync()
+
+	status := x.gitopsStatus()
+	if status == nil {
+		t.Fatal("no synchronization status recorded")
+	}
+	if len(status.Errors) != 0 {
+		t.Errorf("unexpected errors: %v", status.Errors)
+	}
+	if len(status.Files) != 1 {
+		t.Fatalf("status lists %d files, want 1", len(status.Files))
+	}
+	if file := status.Files[0]; file.Action != gitopsActionAdd || file.Error != `` {
+		t.Errorf("cpu.yaml synchronized as %s/%q, want %s",
+			file.Action, file.Error, gitopsActionAdd)
+	}
+
+	tg.lock.Lock()
+	defer tg.lock.Unlock()
+	if len(tg.written) != 1 {
+		t.Fatalf("%d configurations written, want 1", len(tg.written))
+	}
+	q := tg.written[0]
+	if q.Action != msg.ActionAdd || q.Provision.Source != msg.SourceFile {
+		t.Errorf("profile applied as %s from source %q", q.Action, q.Provision.Source)
+	}
+	if q.Configuration.HostID != 42 || q.Configuration.Metric != `cpu.usage.percent` {
+		t.Errorf("applied configuration is for %d/%s",
+			q.Configuration.HostID, q.Configuration.Metric)
+	}
+	if want := calculateLookupID(42, `cpu.usage.percent`); q.LookupHash != want {
+		t.Errorf("lookupID is %s, want %s", q.LookupHash, want)
+	}
+	if len(q.Configuration.Data) != 1 || len(q.Configuration.Data[0].Thresholds) != 1 {
+		t.Errorf("applied configuration data is %+v", q.Configuration.Data)
+	}
+}
+
+func TestGitopsLoadInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `gitops`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		profile string
+	}{
+		{
+			name:    `missing team`,
+			profile: "hostID: 42\nmetric: m\ndata:\n  interval: 60\n  monitoring: m\n  targethost: host.example.org\nthresholds:\n  - predicate: \">\"\n",
+		},
+		{
+			name:    `invalid targethost`,
+			profile: "hostID: 42\nmetric: m\ndata:\n  interval: 60\n  monitoring: m\n  team: t\n  targethost: \"not a host\"\nthresholds:\n  - predicate: \">\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, `profile.yaml`)
+		if err = ioutil.WriteFile(path, []byte(tt.profile), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err = gitopsLoad(path); err == nil {
+			t.Errorf("%s: no validation error", tt.name)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
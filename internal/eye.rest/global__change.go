@@ -0,0 +1,169 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	msg "github.com/solnx/eye/internal/eye.msg"
+)
+
+// Polling and keepalive intervals of change feed streams
+const (
+	changeStreamPollInterval      = 2 * time.Second
+	changeStreamKeepaliveInterval = 15 * time.Second
+)
+
+// ChangeList accepts requests to read the change feed after the cursor
+// given via the since URL query parameter
+func (x *Rest) ChangeList(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionChange
+	request.Action = msg.ActionList
+
+	if err := r.ParseForm(); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+	if err := parseChangeCursor(r.Form.Get(`since`), &request); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	handler := x.handlerMap.Get(`change_r`)
+	handler.Intake() <- request
+	result := <-request.Reply
+	x.respond(&w, &result)
+}
+
+// ChangeStream accepts requests to stream the change feed as
+// Server-Sent Events. The stream starts after the cursor given via
+// the since URL query parameter or the Last-Event-ID header
+func (x *Rest) ChangeStream(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionChange
+	request.Action = msg.ActionStream
+
+	if err := r.ParseForm(); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+	cursor := r.Form.Get(`since`)
+	// reconnecting EventSource clients send the last received ID
+	if lastEventID := r.Header.Get(`Last-Event-ID`); lastEventID != `` {
+		cursor = lastEventID
+	}
+	if err := parseChangeCursor(cursor, &request); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		x.replyInternalError(&w, &request, fmt.Errorf(
+			"Streaming is not supported by the connection"))
+		return
+	}
+
+	w.Header().Set(`Content-Type`, `text/event-stream`)
+	w.Header().Set(`Cache-Control`, `no-cache`)
+	w.Header().Set(`Connection`, `keep-alive`)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	poll := time.NewTicker(changeStreamPollInterval)
+	defer poll.Stop()
+	keepalive := time.NewTicker(changeStreamKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		// read all available changes before waiting for new ones
+		for {
+			query := request
+			query.Reply = make(chan msg.Result, 1)
+			x.handlerMap.Get(`change_r`).Intake() <- query
+			result := <-query.Reply
+			if result.Error != nil {
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n",
+					result.Error.Error())
+				flusher.Flush()
+				return
+			}
+
+			for _, change := range result.Change {
+				data, err := json.Marshal(&change)
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "id: %d\nevent: change\ndata: %s\n\n",
+					change.ID, data)
+				request.Search.Change.ID = change.ID
+			}
+			flusher.Flush()
+
+			if len(result.Change) == 0 {
+				break
+			}
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-poll.C:
+		}
+
+		if ShutdownInProgress {
+			return
+		}
+	}
+}
+
+// parseChangeCursor sets the change feed cursor of q from s. An empty
+// s starts the feed at the beginning
+func parseChangeCursor(s string, q *msg.Request) error {
+	if s == `` {
+		q.Search.Change.ID = 0
+		return nil
+	}
+	cursor, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	if cursor < 0 {
+		return fmt.Errorf("Invalid negative change cursor: %d", cursor)
+	}
+	q.Search.Change.ID = cursor
+	return nil
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/asaskevich/govalidator"
@@ -22,6 +23,7 @@ import (
 	msg "github.com/solnx/eye/internal/eye.msg"
 	"github.com/mjolnir42/soma/lib/proto"
 	uuid "github.com/satori/go.uuid"
+	"github.com/solnx/eye/lib/eye.proto/v2"
 )
 
 // DeploymentNotification implements the API call that receives
@@ -35,6 +37,12 @@ func (x *Rest) DeploymentNotification(w http.ResponseWriter, r *http.Request,
 	request.Section = msg.SectionDeployment
 	request.Action = msg.ActionNotification
 
+	// verify the notification was signed by SOMA
+	if err := x.pushVerify(r); err != nil {
+		x.replyForbidden(&w, &request, err)
+		return
+	}
+
 	// decode client payload
 	clientReq := proto.NewPushNotification()
 	if err := decodeJSONBody(r, &clientReq); err != nil {
@@ -65,6 +73,18 @@ func (x *Rest) DeploymentNotification(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
+	// only follow notifications to whitelisted SOMA paths
+	var allowed bool
+	if request.Notification.PathPrefix, allowed = x.pushPathAllowed(
+		request.Notification.PathPrefix,
+	); !allowed {
+		x.replyForbidden(&w, &request, fmt.Errorf(
+			"Notification path not permitted: %s",
+			request.Notification.PathPrefix,
+		))
+		return
+	}
+
 	// request authorization for request
 	if !x.isAuthorized(&request) {
 		x.replyForbidden(&w, &request, nil)
@@ -91,21 +111,36 @@ func (x *Rest) DeploymentProcess(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	if err = resolveFlags(nil, &request); err != nil {
-		x.replyBadRequest(&w, &request, err)
+	switch {
+	case cReq.Deployments == nil || len(*cReq.Deployments) == 0:
+		x.replyUnprocessableEntity(&w, &request, fmt.Errorf("Deployment count 0"))
 		return
-	}
-
-	if len(*cReq.Deployments) != 1 {
+	case len(*cReq.Deployments) > 1 && request.Version == msg.ProtocolOne:
+		// v1 API results can not express per-deployment results
 		x.replyUnprocessableEntity(&w, &request, fmt.Errorf("Deployment count %d != 1", len(*cReq.Deployments)))
 		return
+	case len(*cReq.Deployments) > 1:
+		if !x.isAuthorized(&request) {
+			x.replyForbidden(&w, &request, nil)
+			return
+		}
+		x.deploymentBatch(&w, &request, *cReq.Deployments)
+		return
 	}
 
 	request.ConfigurationTask = (*cReq.Deployments)[0].Task
-	if request.LookupHash, request.Configuration, err = processDeploymentDetails(&(*cReq.Deployments)[0]); err != nil {
+	if request.LookupHash, request.Configuration, err = x.processDeploymentDetails(&(*cReq.Deployments)[0]); err != nil {
 		x.replyInternalError(&w, &request, err)
 		return
 	}
+	request.Deployment = deploymentFromDetails(&(*cReq.Deployments)[0])
+	request.Provision.Source = msg.SourceSOMA
+
+	// request flags depend on the deployment task
+	if err = resolveFlags(nil, &request); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
 
 	// build URL to send deployment feedback
 	x.somaSetFeedbackURL(&request)
@@ -158,6 +193,107 @@ func (x *Rest) DeploymentProcess(w http.ResponseWriter, r *http.Request,
 	x.respond(&w, &result)
 }
 
+// DeploymentTranslate accepts SOMA deployment results and replies
+// with the configurations they translate to using the configured
+// mapping rules. The deployments are not processed.
+func (x *Rest) DeploymentTranslate(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionDeployment
+	request.Action = msg.ActionTranslate
+
+	cReq := proto.NewDeploymentResult()
+	if err := decodeJSONBody(r, &cReq); err != nil {
+		x.replyUnprocessableEntity(&w, &request, err)
+		return
+	}
+
+	if cReq.Deployments == nil || len(*cReq.Deployments) == 0 {
+		x.replyUnprocessableEntity(&w, &request, fmt.Errorf("Deployment count 0"))
+		return
+	}
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	result := msg.FromRequest(&request)
+	result.Batch = make([]msg.Result, len(*cReq.Deployments))
+	partial := false
+	for i := range *cReq.Deployments {
+		result.Batch[i] = msg.FromRequest(&request)
+		_, config, err := x.processDeploymentDetails(&(*cReq.Deployments)[i])
+		if err != nil {
+			result.Batch[i].UnprocessableEntity(err)
+			partial = true
+			continue
+		}
+		result.Batch[i].Configuration = []v2.Configuration{config}
+		result.Batch[i].OK()
+	}
+
+	// a single deployment is answered without the batch wrapper
+	switch {
+	case len(result.Batch) == 1:
+		result = result.Batch[0]
+	case partial:
+		result.Partial()
+	default:
+		result.OK()
+	}
+	x.respond(&w, &result)
+}
+
+// DeploymentList accepts requests to list all processed deployments
+func (x *Rest) DeploymentList(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionDeployment
+	request.Action = msg.ActionList
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	handler := x.handlerMap.Get(`deployment_r`)
+	handler.Intake() <- request
+	result := <-request.Reply
+	x.respond(&w, &result)
+}
+
+// DeploymentShow accepts requests to retrieve the processing records
+// of a specific deployment
+func (x *Rest) DeploymentShow(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionDeployment
+	request.Action = msg.ActionShow
+	request.Deployment.ID = strings.ToLower(params.ByName(`ID`))
+
+	if _, err := uuid.FromString(request.Deployment.ID); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	handler := x.handlerMap.Get(`deployment_r`)
+	handler.Intake() <- request
+	result := <-request.Reply
+	x.respond(&w, &result)
+}
+
 // fetchPushDeployment fetches DeploymentDetails for which a push
 // notification was received by x.DeploymentNotification
 func (x *Rest) fetchPushDeployment(w *http.ResponseWriter, q *msg.Request) {
@@ -240,16 +376,30 @@ func (x *Rest) fetchPushDeployment(w *http.ResponseWriter, q *msg.Request) {
 		return
 	}
 
-	if len(*res.Deployments) != 1 {
+	switch {
+	case res.Deployments == nil || len(*res.Deployments) == 0:
+		x.replyUnprocessableEntity(w, q, fmt.Errorf("Deployment count 0"))
+		return
+	case len(*res.Deployments) > 1 && q.Version == msg.ProtocolOne:
+		// v1 API results can not express per-deployment results
 		x.replyUnprocessableEntity(w, q, fmt.Errorf("Deployment count %d != 1", len(*res.Deployments)))
 		return
+	case len(*res.Deployments) > 1:
+		if !x.isAuthorized(q) {
+			x.replyForbidden(w, q, nil)
+			return
+		}
+		x.deploymentBatch(w, q, *res.Deployments)
+		return
 	}
 
 	q.ConfigurationTask = (*res.Deployments)[0].Task
-	if q.LookupHash, q.Configuration, err = processDeploymentDetails(&(*res.Deployments)[0]); err != nil {
+	if q.LookupHash, q.Configuration, err = x.processDeploymentDetails(&(*res.Deployments)[0]); err != nil {
 		x.replyInternalError(w, q, err)
 		return
 	}
+	q.Deployment = deploymentFromDetails(&(*res.Deployments)[0])
+	q.Provision.Source = msg.SourceSOMA
 
 	if err := resolveFlags(nil, q); err != nil {
 		x.replyBadRequest(w, q, err)
@@ -270,4 +420,105 @@ func (x *Rest) fetchPushDeployment(w *http.ResponseWriter, q *msg.Request) {
 	x.respond(w, &result)
 }
 
+// deploymentBatch processes multiple SOMA deployments received with
+// request q and replies with the per-deployment results
+func (x *Rest) deploymentBatch(w *http.ResponseWriter, q *msg.Request,
+	deployments []proto.Deployment) {
+
+	result := x.deploymentBatchProcess(q, deployments)
+	x.respond(w, &result)
+}
+
+// deploymentBatchProcess processes multiple SOMA deployments as part
+// of request q and returns the merged per-deployment results
+func (x *Rest) deploymentBatchProcess(q *msg.Request,
+	deployments []proto.Deployment) msg.Result {
+
+	batch := *q
+	batch.Action = msg.ActionBatch
+	batch.Flags = msg.Flags{}
+	batch.Batch = []msg.Request{}
+
+	// deployments that fail conversion are not sent to the handler,
+	// but are part of the results at their position
+	failed := map[int]msg.Result{}
+	for i := range deployments {
+		item, err := x.deploymentRequest(q, &deployments[i])
+		if err != nil {
+			res := msg.FromRequest(&item)
+			res.UnprocessableEntity(err)
+			failed[i] = res
+			continue
+		}
+		batch.Batch = append(batch.Batch, item)
+	}
+
+	processed := []msg.Result{}
+	if len(batch.Batch) > 0 {
+		handler := x.handlerMap.Get(`deployment_w`)
+		handler.Intake() <- batch
+		res := <-batch.Reply
+		if res.Error != nil && len(res.Batch) == 0 {
+			return res
+		}
+		processed = res.Batch
+	}
+
+	result := msg.FromRequest(&batch)
+	result.Batch = make([]msg.Result, len(deployments))
+	partial := false
+	for i, j := 0, 0; i < len(deployments); i++ {
+		if res, ok := failed[i]; ok {
+			result.Batch[i] = res
+		} else {
+			result.Batch[i] = processed[j]
+			j++
+		}
+		if result.Batch[i].HasFailed() {
+			partial = true
+		}
+	}
+	if partial {
+		result.Partial()
+	} else {
+		result.OK()
+	}
+	return result
+}
+
+// deploymentRequest returns the request to process the SOMA
+// deployment details as part of the batch request q. The returned
+// request is set up to send deployment feedback even if an error is
+// returned
+func (x *Rest) deploymentRequest(q *msg.Request,
+	details *proto.Deployment) (msg.Request, error) {
+
+	var err error
+	item := *q
+	item.Reply = make(chan msg.Result, 1)
+	item.ConfigurationTask = details.Task
+	item.Deployment = deploymentFromDetails(details)
+	item.Provision.Source = msg.SourceSOMA
+	item.Configuration.ID = details.CheckInstance.InstanceID
+
+	// batched push notifications carry multiple deployments, their
+	// feedback is sent for the individual deployment IDs
+	if !uuid.Equal(uuid.Nil, item.Notification.ID) {
+		item.Notification.ID = uuid.FromStringOrNil(item.Deployment.ID)
+	}
+
+	// the feedback URL depends on the resolved flags, but has to be
+	// set up even if resolving them fails
+	err = resolveFlags(nil, &item)
+	x.somaSetFeedbackURL(&item)
+	if err != nil {
+		return item, err
+	}
+
+	if item.LookupHash, item.Configuration, err = x.processDeploymentDetails(details); err != nil {
+		return item, err
+	}
+	return item, nil
+}
+
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
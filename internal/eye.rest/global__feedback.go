@@ -0,0 +1,105 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
+	msg "github.com/solnx/eye/internal/eye.msg"
+)
+
+// FeedbackList accepts requests to list all deployment feedbacks. The
+// list can be filtered by delivery status via the status URL query
+// parameter
+func (x *Rest) FeedbackList(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionFeedback
+	request.Action = msg.ActionList
+
+	if err := r.ParseForm(); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+	if status := r.Form.Get(`status`); status != `` {
+		switch status {
+		case msg.FeedbackAcknowledged, msg.FeedbackFailed, msg.FeedbackPending:
+		default:
+			x.replyBadRequest(&w, &request, fmt.Errorf(
+				"Invalid feedback status: %s", status))
+			return
+		}
+		request.Action = msg.ActionSearch
+		request.Search.Feedback.Status = status
+	}
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	handler := x.handlerMap.Get(`feedback_r`)
+	handler.Intake() <- request
+	result := <-request.Reply
+	x.respond(&w, &result)
+}
+
+// FeedbackReplay accepts requests to immediately redeliver a specific
+// deployment feedback to SOMA
+func (x *Rest) FeedbackReplay(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionFeedback
+	request.Action = msg.ActionReplay
+	request.Feedback.ID = strings.ToLower(params.ByName(`ID`))
+
+	if _, err := uuid.FromString(request.Feedback.ID); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	// load the stored feedback
+	show := request
+	show.Action = msg.ActionShow
+	x.handlerMap.Get(`feedback_r`).Intake() <- show
+	result := <-show.Reply
+	if result.Error != nil || len(result.Feedback) == 0 {
+		result.Action = msg.ActionReplay
+		x.respond(&w, &result)
+		return
+	}
+
+	feedback := result.Feedback[0]
+	if err := x.somaFeedbackAttempt(&feedback); err != nil {
+		x.replyBadGateway(&w, &request, err)
+		return
+	}
+
+	// return the updated feedback
+	show.Reply = make(chan msg.Result, 1)
+	x.handlerMap.Get(`feedback_r`).Intake() <- show
+	result = <-show.Reply
+	result.Action = msg.ActionReplay
+	x.respond(&w, &result)
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,84 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// ReconciliationShow accepts requests to retrieve the drift report of
+// the last reconciliation run against SOMA
+func (x *Rest) ReconciliationShow(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionReconciliation
+	request.Action = msg.ActionShow
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	result := msg.FromRequest(&request)
+	report := x.somaReconcileReport()
+	if report == nil {
+		result.NotFound(fmt.Errorf("No reconciliation run has finished yet"))
+		x.respond(&w, &result)
+		return
+	}
+	result.Reconciliation = []v2.Reconciliation{*report}
+	result.OK()
+	x.respond(&w, &result)
+}
+
+// ReconciliationRun accepts requests to perform a reconciliation run
+// against SOMA. Drift is applied if the apply query parameter is true.
+func (x *Rest) ReconciliationRun(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionReconciliation
+	request.Action = msg.ActionReconcile
+
+	apply := false
+	if val := r.URL.Query().Get(`apply`); val != `` {
+		var err error
+		if apply, err = strconv.ParseBool(val); err != nil {
+			x.replyBadRequest(&w, &request, err)
+			return
+		}
+	}
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	result := msg.FromRequest(&request)
+	report, err := x.somaReconcileRun(apply)
+	if err != nil {
+		result.BadGateway(err)
+		x.respond(&w, &result)
+		return
+	}
+	result.Reconciliation = []v2.Reconciliation{*report}
+	result.OK()
+	x.respond(&w, &result)
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
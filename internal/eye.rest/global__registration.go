@@ -46,6 +46,38 @@ func (x *Rest) RegistrationShow(w http.ResponseWriter, r *http.Request,
 	x.respond(&w, &result)
 }
 
+// RegistrationStatus accepts requests to retrieve a specific
+// registration together with its cache invalidation delivery status
+func (x *Rest) RegistrationStatus(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionRegistration
+	request.Action = msg.ActionShow
+	request.Registration.ID = strings.ToLower(params.ByName(`ID`))
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	if _, err := uuid.FromString(request.Registration.ID); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+
+	handler := x.handlerMap.Get(`registration_r`)
+	handler.Intake() <- request
+	result := <-request.Reply
+	for i := range result.Registration {
+		result.Registration[i].Status = x.invl.Status(
+			result.Registration[i].ID,
+		)
+	}
+	x.respond(&w, &result)
+}
+
 // RegistrationList accepts requests to list all registrations. If r
 // contains URL query parameters that indicate a search request, the
 // returned list will be filtered for those search terms
@@ -138,6 +170,7 @@ func (x *Rest) RegistrationAdd(w http.ResponseWriter, r *http.Request,
 		return
 	}
 	request.Registration = *cReq.Registration
+	x.registrationLease(&request)
 
 	if !x.isAuthorized(&request) {
 		x.replyForbidden(&w, &request, nil)
@@ -173,6 +206,7 @@ func (x *Rest) RegistrationUpdate(w http.ResponseWriter, r *http.Request,
 			params.ByName(`ID`),
 		))
 	}
+	x.registrationLease(&request)
 
 	if !x.isAuthorized(&request) {
 		x.replyForbidden(&w, &request, nil)
@@ -213,4 +247,31 @@ func (x *Rest) RegistrationRemove(w http.ResponseWriter, r *http.Request,
 	x.respond(&w, &result)
 }
 
+// RegistrationRenew accepts requests to renew the lease of a
+// registration
+func (x *Rest) RegistrationRenew(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionRegistration
+	request.Action = msg.ActionRenew
+	request.Registration.ID = strings.ToLower(params.ByName(`ID`))
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	if _, err := uuid.FromString(request.Registration.ID); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+
+	handler := x.handlerMap.Get(`registration_w`)
+	handler.Intake() <- request
+	result := <-request.Reply
+	x.respond(&w, &result)
+}
+
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
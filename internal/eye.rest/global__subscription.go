@@ -0,0 +1,180 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/julienschmidt/httprouter"
+	uuid "github.com/satori/go.uuid"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// SubscriptionList accepts requests to list all webhook subscriptions
+func (x *Rest) SubscriptionList(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionSubscription
+	request.Action = msg.ActionList
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	handler := x.handlerMap.Get(`subscription_r`)
+	handler.Intake() <- request
+	result := <-request.Reply
+	x.respond(&w, &result)
+}
+
+// SubscriptionShow accepts requests to retrieve a specific webhook
+// subscription
+func (x *Rest) SubscriptionShow(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionSubscription
+	request.Action = msg.ActionShow
+	request.Subscription.ID = strings.ToLower(params.ByName(`ID`))
+
+	if _, err := uuid.FromString(request.Subscription.ID); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	handler := x.handlerMap.Get(`subscription_r`)
+	handler.Intake() <- request
+	result := <-request.Reply
+	x.respond(&w, &result)
+}
+
+// SubscriptionHistory accepts requests to list the event deliveries
+// of a specific webhook subscription
+func (x *Rest) SubscriptionHistory(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionSubscription
+	request.Action = msg.ActionHistory
+	request.Subscription.ID = strings.ToLower(params.ByName(`ID`))
+
+	if _, err := uuid.FromString(request.Subscription.ID); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	handler := x.handlerMap.Get(`subscription_r`)
+	handler.Intake() <- request
+	result := <-request.Reply
+	x.respond(&w, &result)
+}
+
+// SubscriptionAdd accepts requests to add a webhook subscription
+func (x *Rest) SubscriptionAdd(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionSubscription
+	request.Action = msg.ActionAdd
+
+	cReq := v2.NewSubscriptionRequest()
+	if err := decodeJSONBody(r, &cReq); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+	if cReq.Subscription == nil {
+		x.replyBadRequest(&w, &request, fmt.Errorf(
+			"Request is missing the subscription"))
+		return
+	}
+	request.Subscription = *cReq.Subscription
+
+	// the fields are validated explicitly, validating the struct
+	// depends on the process wide setting whether fields are required
+	// by default
+	if request.Subscription.ID != `` && !govalidator.IsUUIDv4(request.Subscription.ID) {
+		x.replyUnprocessableEntity(&w, &request, fmt.Errorf(
+			"Invalid subscriptionID: %s", request.Subscription.ID))
+		return
+	}
+	if !govalidator.IsURL(request.Subscription.URL) {
+		x.replyUnprocessableEntity(&w, &request, fmt.Errorf(
+			"Invalid webhook URL: %s", request.Subscription.URL))
+		return
+	}
+	if !webhookValidAction(
+		request.Subscription.Filter.Section,
+		request.Subscription.Filter.Action,
+	) {
+		x.replyUnprocessableEntity(&w, &request, fmt.Errorf(
+			"Section %s has no events for action %s",
+			request.Subscription.Filter.Section,
+			request.Subscription.Filter.Action,
+		))
+		return
+	}
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	handler := x.handlerMap.Get(`subscription_w`)
+	handler.Intake() <- request
+	result := <-request.Reply
+	x.respond(&w, &result)
+}
+
+// SubscriptionRemove accepts requests to remove a webhook subscription
+func (x *Rest) SubscriptionRemove(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionSubscription
+	request.Action = msg.ActionRemove
+	request.Subscription.ID = strings.ToLower(params.ByName(`ID`))
+
+	if _, err := uuid.FromString(request.Subscription.ID); err != nil {
+		x.replyBadRequest(&w, &request, err)
+		return
+	}
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	handler := x.handlerMap.Get(`subscription_w`)
+	handler.Intake() <- request
+	result := <-request.Reply
+	x.respond(&w, &result)
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
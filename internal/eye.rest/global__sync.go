@@ -0,0 +1,48 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// SyncShow accepts requests to retrieve the status of the last
+// synchronization of the file based configuration source, including
+// the errors per profile file
+func (x *Rest) SyncShow(w http.ResponseWriter, r *http.Request,
+	params httprouter.Params) {
+	defer panicCatcher(w)
+
+	request := msg.New(r, params)
+	request.Section = msg.SectionSync
+	request.Action = msg.ActionShow
+
+	if !x.isAuthorized(&request) {
+		x.replyForbidden(&w, &request, nil)
+		return
+	}
+
+	result := msg.FromRequest(&request)
+	status := x.gitopsStatus()
+	if status == nil {
+		result.NotFound(fmt.Errorf("No file synchronization has finished yet"))
+		x.respond(&w, &result)
+		return
+	}
+	result.Sync = []v2.Sync{*status}
+	result.OK()
+	x.respond(&w, &result)
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
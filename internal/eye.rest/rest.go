@@ -10,11 +10,14 @@
 package rest // import "github.com/solnx/eye/internal/eye.rest"
 
 import (
+	"log"
 	"net/http"
 	"text/template"
+	"time"
 
 	"github.com/mjolnir42/erebos"
 	"github.com/solnx/eye/internal/eye"
+	cfg "github.com/solnx/eye/internal/eye.cfg"
 	msg "github.com/solnx/eye/internal/eye.msg"
 	wall "github.com/solnx/eye/lib/eye.wall"
 	"github.com/mjolnir42/limit"
@@ -35,10 +38,29 @@ type Rest struct {
 	restricted   bool
 	// concurrenyLimit caps the number of active outgoing HTTP requests
 	limit *limit.Limit
+	// transport enforcing limit for outgoing requests
+	transport *limitTransport
+	// webhook deliveries use their own concurrency limit and worker
+	webhookTransport *limitTransport
+	webhookWake      chan struct{}
 	// notification template
 	tmpl *template.Template
 	// cache invalidator
-	invl *wall.Invalidation
+	invl         *wall.Invalidation
+	registryConf *cfg.Registry
+	// SOMA deployment mapping rules
+	mapping *cfg.Mapping
+	// SOMA reconciliation settings and state
+	reconcileConf *cfg.Reconciliation
+	recon         *reconcileState
+	// SOMA pull-mode ingestion settings
+	pullConf *cfg.Pull
+	// file based configuration source settings and state
+	gitopsConf *cfg.GitOps
+	gitops     *gitopsState
+	// SOMA push notification settings and replay state
+	pushConf *cfg.Push
+	push     *pushState
 }
 
 // New returns a new REST interface
@@ -46,6 +68,7 @@ func New(
 	authorizationFunction func(*msg.Request) bool,
 	appHandlerMap *eye.HandlerMap,
 	conf *erebos.Config,
+	eyeConf *cfg.Config,
 ) *Rest {
 	x := Rest{}
 	x.isAuthorized = authorizationFunction
@@ -53,8 +76,35 @@ func New(
 	x.handlerMap = appHandlerMap
 	x.conf = conf
 	x.limit = limit.New(conf.Eye.ConcurrencyLimit)
+	x.transport = newLimitTransport(x.limit)
+	x.webhookTransport = newLimitTransport(
+		limit.New(conf.Eye.ConcurrencyLimit),
+	)
+	x.webhookWake = make(chan struct{}, 1)
 	x.tmpl = template.Must(template.ParseFiles(conf.Eye.AlarmTemplateFile))
 	x.invl = wall.NewInvalidation(conf)
+	x.registryConf = &eyeConf.Registry
+	x.invl.RetryLimit = int(eyeConf.Registry.RetryLimit)
+	x.invl.RetryBackoff = time.Duration(eyeConf.Registry.RetryBackoff) * time.Second
+	x.invl.WriteTTL = time.Duration(eyeConf.Registry.WriteTTL) * time.Second
+	if eyeConf.Registry.PublishConnect != `` {
+		if err := x.invl.EnablePublish(
+			eyeConf.Registry.PublishConnect,
+			eyeConf.Registry.PublishPassword,
+			eyeConf.Registry.PublishDB,
+			eyeConf.Registry.PublishChannel,
+		); err != nil {
+			log.Println(`Invalidation`, `Error`, err.Error())
+		}
+	}
+	x.mapping = &eyeConf.Mapping
+	x.reconcileConf = &eyeConf.Reconciliation
+	x.recon = &reconcileState{}
+	x.pullConf = &eyeConf.Pull
+	x.gitopsConf = &eyeConf.GitOps
+	x.gitops = &gitopsState{}
+	x.pushConf = &eyeConf.Push
+	x.push = newPushState()
 	return &x
 }
 
@@ -62,6 +112,30 @@ func New(
 func (x *Rest) Run() {
 	router := x.setupRouter()
 
+	// restore and maintain the cache invalidation registry
+	go x.eyewallRegistrySync()
+
+	// expire registrations whose lease was not renewed
+	go x.eyewallLeaseReaper()
+
+	// retry failed cache invalidations
+	go x.eyewallInvalidationRetry()
+
+	// redeliver failed deployment feedback
+	go x.somaFeedbackRetry()
+
+	// deliver webhook events
+	go x.webhookRetry()
+
+	// reconcile configurations with SOMA
+	go x.somaReconcile()
+
+	// poll SOMA for pending deployments
+	go x.somaPull()
+
+	// synchronize file based configurations
+	go x.gitopsWatch()
+
 	// TODO switch to new abortable interface
 	if x.conf.Eye.Daemon.TLS {
 		// XXX log.Fatal
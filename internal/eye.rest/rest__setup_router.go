@@ -18,18 +18,30 @@ func (x *Rest) setupRouter() *httprouter.Router {
 	router.DELETE(`/api/v1/item/:ID`, x.Verify(x.ConfigurationRemove))
 	router.DELETE(`/api/v2/configuration/:ID`, x.Verify(x.ConfigurationRemove))
 	router.DELETE(`/api/v2/registration/:ID`, x.Verify(x.RegistrationRemove))
+	router.DELETE(`/api/v2/subscription/:ID`, x.Verify(x.SubscriptionRemove))
 	router.GET(`/api/v1/configuration/:hash`, x.Verify(x.LookupConfiguration))
 	router.GET(`/api/v1/item/:ID`, x.Verify(x.ConfigurationShow))
 	router.GET(`/api/v1/item/`, x.Verify(x.ConfigurationList))
+	router.GET(`/api/v2/changes/stream`, x.Verify(x.ChangeStream))
+	router.GET(`/api/v2/changes`, x.Verify(x.ChangeList))
 	router.GET(`/api/v2/configuration/:ID/history/*DATA`, x.Verify(x.ConfigurationVersion))
 	router.GET(`/api/v2/configuration/:ID/history`, x.Verify(x.ConfigurationHistory))
 	router.GET(`/api/v2/configuration/:ID`, x.Verify(x.ConfigurationShow))
 	router.GET(`/api/v2/configuration/`, x.Verify(x.ConfigurationList))
+	router.GET(`/api/v2/deployment/:ID`, x.Verify(x.DeploymentShow))
+	router.GET(`/api/v2/deployment/`, x.Verify(x.DeploymentList))
+	router.GET(`/api/v2/feedback/`, x.Verify(x.FeedbackList))
 	router.GET(`/api/v2/lookup/configuration/:hash`, x.Verify(x.LookupConfiguration))
 	router.GET(`/api/v2/lookup/registration/:application`, x.Verify(x.LookupRegistration))
 	router.GET(`/api/v2/lookup/activation/`, x.Verify(x.LookupActivation))
+	router.GET(`/api/v2/reconciliation/`, x.Verify(x.ReconciliationShow))
 	router.GET(`/api/v2/registration/:ID`, x.Verify(x.RegistrationShow))
+	router.GET(`/api/v2/registration/:ID/status`, x.Verify(x.RegistrationStatus))
 	router.GET(`/api/v2/registration/`, x.Verify(x.RegistrationList))
+	router.GET(`/api/v2/subscription/:ID/delivery`, x.Verify(x.SubscriptionHistory))
+	router.GET(`/api/v2/subscription/:ID`, x.Verify(x.SubscriptionShow))
+	router.GET(`/api/v2/subscription/`, x.Verify(x.SubscriptionList))
+	router.GET(`/api/v2/sync/`, x.Verify(x.SyncShow))
 	router.HEAD(`/api`, x.VersionInfo)
 	router.PATCH(`/api/v2/configuration/:ID/active`, x.Verify(x.ConfigurationActivate))
 	router.POST(`/api/v1/item/`, x.Verify(x.DeploymentProcess))
@@ -38,10 +50,15 @@ func (x *Rest) setupRouter() *httprouter.Router {
 	router.POST(`/api/v2/configuration/`, x.Verify(x.ConfigurationAdd))
 	router.POST(`/api/v2/deployment/`, x.Verify(x.DeploymentProcess))
 	router.POST(`/api/v2/deployment/notification`, x.Verify(x.DeploymentNotification))
+	router.POST(`/api/v2/deployment/translate`, x.Verify(x.DeploymentTranslate))
+	router.POST(`/api/v2/feedback/:ID/replay`, x.Verify(x.FeedbackReplay))
+	router.POST(`/api/v2/reconciliation/`, x.Verify(x.ReconciliationRun))
 	router.POST(`/api/v2/registration/`, x.Verify(x.RegistrationAdd))
+	router.POST(`/api/v2/subscription/`, x.Verify(x.SubscriptionAdd))
 	router.PUT(`/api/v1/item/:ID`, x.Verify(x.DeploymentProcess))
 	router.PUT(`/api/v2/configuration/:ID`, x.Verify(x.ConfigurationUpdate))
 	router.PUT(`/api/v2/registration/:ID`, x.Verify(x.RegistrationUpdate))
+	router.PUT(`/api/v2/registration/:ID/renew`, x.Verify(x.RegistrationRenew))
 
 	return router
 }
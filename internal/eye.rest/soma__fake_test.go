@@ -0,0 +1,223 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjolnir42/soma/lib/proto"
+)
+
+// fakeSoma is a local SOMA server that serves deployment details and
+// records deployment feedback, for use in place of Eye.SomaURL
+type fakeSoma struct {
+	server       *httptest.Server
+	lock         sync.Mutex
+	monitoringID string
+	listPath     string
+	pendingPath  string
+	prefix       string
+	deployments  map[string]proto.Deployment
+	feedback     []fakeSomaFeedback
+}
+
+// fakeSomaFeedback is a deployment feedback received by fakeSoma
+type fakeSomaFeedback struct {
+	DeploymentID string
+	Status       string
+}
+
+// newfakeSoma returns a started fakeSoma. The deployments of
+// monitoring system monitoringID are listed below listPath, single
+// deployments and their feedback are served below prefix
+func newfakeSoma(monitoringID, listPath, prefix string) *fakeSoma {
+	f := &fakeSoma{
+		monitoringID: monitoringID,
+		listPath:     path.Clean(`/` + listPath),
+		prefix:       path.Clean(`/` + prefix),
+		deployments:  map[string]proto.Deployment{},
+		feedback:     []fakeSomaFeedback{},
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// URL returns the base URL of f
+func (f *fakeSoma) URL() string {
+	return f.server.URL
+}
+
+// Close shuts f down
+func (f *fakeSoma) Close() {
+	f.server.Close()
+}
+
+// ServePending lists all deployments without received feedback below
+// pendingPath, as used by pull-mode ingestion
+func (f *fakeSoma) ServePending(pendingPath string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.pendingPath = path.Clean(`/` + pendingPath)
+}
+
+// SetDeployment adds or replaces deployment d, keyed by the ID of its
+// check instance configuration
+func (f *fakeSoma) SetDeployment(d proto.Deployment) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.deployments[d.CheckInstance.InstanceConfigID] = d
+}
+
+// RemoveDeployment removes the deployment with ID deploymentID
+func (f *fakeSoma) RemoveDeployment(deploymentID string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.deployments, deploymentID)
+}
+
+// Feedback returns all deployment feedback received so far
+func (f *fakeSoma) Feedback() []fakeSomaFeedback {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]fakeSomaFeedback{}, f.feedback...)
+}
+
+// Notify sends a push notification for deploymentID to the eye
+// instance at eyeURL. If secret is not empty, the notification is
+// signed with it.
+func (f *fakeSoma) Notify(eyeURL, secret, deploymentID string) error {
+	notification := proto.NewPushNotification()
+	notification.UUID = deploymentID
+	notification.Path = f.prefix
+	body, err := json.Marshal(&notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost,
+		strings.TrimSuffix(eyeURL, `/`)+`/api/v1/notify/`,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(`Content-Type`, `application/json`)
+
+	if secret != `` {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + `.`))
+		mac.Write(body)
+		req.Header.Set(`X-Soma-Timestamp`, timestamp)
+		req.Header.Set(`X-Soma-Signature`,
+			`sha256=`+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("eye responded with: %s", resp.Status)
+	}
+	return nil
+}
+
+// serve implements the SOMA API subset used by eye
+func (f *fakeSoma) serve(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	p := path.Clean(r.URL.Path)
+	switch {
+	case r.Method == http.MethodGet && p == path.Join(f.listPath, f.monitoringID):
+		f.reply(w, f.list(false))
+
+	case r.Method == http.MethodGet && f.pendingPath != `` &&
+		p == path.Join(f.pendingPath, f.monitoringID):
+		f.reply(w, f.list(true))
+
+	case r.Method == http.MethodGet && strings.HasPrefix(p, f.prefix+`/`):
+		d, ok := f.deployments[strings.TrimPrefix(p, f.prefix+`/`)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		f.reply(w, []proto.Deployment{d})
+
+	case r.Method == http.MethodPatch && strings.HasPrefix(p, f.prefix+`/`):
+		parts := strings.Split(strings.TrimPrefix(p, f.prefix+`/`), `/`)
+		if len(parts) != 2 {
+			http.NotFound(w, r)
+			return
+		}
+		f.feedback = append(f.feedback, fakeSomaFeedback{
+			DeploymentID: parts[0],
+			Status:       parts[1],
+		})
+		f.reply(w, nil)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// list returns the deployments of f sorted by ID. If pending is true,
+// deployments with received feedback are omitted
+func (f *fakeSoma) list(pending bool) []proto.Deployment {
+	acknowledged := map[string]bool{}
+	for _, fb := range f.feedback {
+		acknowledged[fb.DeploymentID] = true
+	}
+
+	keys := []string{}
+	for id := range f.deployments {
+		keys = append(keys, id)
+	}
+	sort.Strings(keys)
+
+	list := []proto.Deployment{}
+	for _, id := range keys {
+		d := f.deployments[id]
+		if pending && (acknowledged[id] || acknowledged[d.CheckInstance.InstanceID]) {
+			continue
+		}
+		list = append(list, d)
+	}
+	return list
+}
+
+// reply writes a SOMA result containing deployments
+func (f *fakeSoma) reply(w http.ResponseWriter, deployments []proto.Deployment) {
+	res := proto.NewDeploymentResult()
+	res.StatusCode = http.StatusOK
+	res.StatusText = http.StatusText(http.StatusOK)
+	if deployments != nil {
+		res.Deployments = &deployments
+	}
+
+	w.Header().Set(`Content-Type`, `application/json`)
+	json.NewEncoder(w).Encode(&res)
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,64 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	msg "github.com/solnx/eye/internal/eye.msg"
+)
+
+// feedbackRetryInterval is the interval at which outstanding deployment
+// feedbacks are checked for redelivery
+const feedbackRetryInterval = 30 * time.Second
+
+// somaFeedbackRetry periodically redelivers all deployment feedbacks
+// to SOMA that are not yet acknowledged and due for another attempt
+func (x *Rest) somaFeedbackRetry() {
+	ticker := time.NewTicker(feedbackRetryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if ShutdownInProgress {
+			return
+		}
+		x.somaFeedbackRedeliver()
+	}
+}
+
+// somaFeedbackRedeliver performs one redelivery run for all due
+// deployment feedbacks. The due feedbacks are claimed, so that other
+// eye instances on the same database do not redeliver them concurrently
+func (x *Rest) somaFeedbackRedeliver() {
+	request := msg.NewInternal()
+	request.Section = msg.SectionFeedback
+	request.Action = msg.ActionPending
+
+	x.handlerMap.Get(`feedback_w`).Intake() <- request
+	result := <-request.Reply
+	if result.Error != nil {
+		log.Println(`Feedback redelivery`, `Error`, result.Error.Error())
+		return
+	}
+
+	// concurrency of the deliveries is capped by x.limit
+	wg := sync.WaitGroup{}
+	for i := range result.Feedback {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			x.somaFeedbackAttempt(&result.Feedback[i])
+		}(i)
+	}
+	wg.Wait()
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
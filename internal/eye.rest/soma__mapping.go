@@ -0,0 +1,139 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mjolnir42/soma/lib/proto"
+	cfg "github.com/solnx/eye/internal/eye.cfg"
+)
+
+// mappingRewriteMetric applies the configured metric rewrite rules
+// to the metric path of details
+func (x *Rest) mappingRewriteMetric(details *proto.Deployment) (string, error) {
+	path := details.Metric.Path
+	for _, rule := range x.mapping.Rewrites {
+		for _, metric := range rule.Metrics {
+			if metric != path {
+				continue
+			}
+			if rewritten, ok := mappingResolve(details, rule.Template); ok {
+				return rewritten, nil
+			}
+			if rule.Required {
+				return ``, fmt.Errorf("Metric %s does not resolve mapping template %s", path, rule.Template)
+			}
+			return path, nil
+		}
+	}
+	return path, nil
+}
+
+// mappingField returns the value of the first resolving template in
+// order, or the empty string
+func mappingField(details *proto.Deployment, order []string) string {
+	for _, tmpl := range order {
+		if value, ok := mappingResolve(details, tmpl); ok {
+			return value
+		}
+	}
+	return ``
+}
+
+// mappingTags returns the tags of all resolving tag templates
+func (x *Rest) mappingTags(details *proto.Deployment) []string {
+	tags := []string{}
+	for _, tmpl := range x.mapping.Tags {
+		if value, ok := mappingResolve(details, tmpl); ok {
+			tags = append(tags, value)
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// mappingResolve replaces all references in template tmpl with their
+// values from details. It returns false if any reference resolves to
+// the empty string
+func mappingResolve(details *proto.Deployment, tmpl string) (string, bool) {
+	refs, err := cfg.References(tmpl)
+	if err != nil {
+		return ``, false
+	}
+	value := tmpl
+	for _, ref := range refs {
+		repl := mappingValue(details, ref)
+		if repl == `` {
+			return ``, false
+		}
+		value = strings.Replace(value, `{`+ref+`}`, repl, 1)
+	}
+	return value, true
+}
+
+// mappingValue returns the value of reference ref in details
+func mappingValue(details *proto.Deployment, ref string) string {
+	switch {
+	case strings.HasPrefix(ref, `property.`):
+		return getSystemPropertyValue(details, strings.TrimPrefix(ref, `property.`))
+	case strings.HasPrefix(ref, `attribute.`):
+		return getServiceAttributeValue(details, strings.TrimPrefix(ref, `attribute.`))
+	}
+
+	switch ref {
+	case `metric.path`:
+		if details.Metric != nil {
+			return details.Metric.Path
+		}
+	case `node.name`:
+		if details.Node != nil {
+			return details.Node.Name
+		}
+	case `node.name.absolute`:
+		if details.Node != nil && strings.HasSuffix(details.Node.Name, `.`) {
+			return details.Node.Name
+		}
+	case `node.assetid`:
+		if details.Node != nil {
+			return strconv.FormatUint(details.Node.AssetID, 10)
+		}
+	case `service.name`:
+		if details.Service != nil {
+			return details.Service.Name
+		}
+	case `check.name`:
+		if details.CheckConfig != nil {
+			return details.CheckConfig.Name
+		}
+	case `team.name`:
+		if details.Team != nil {
+			return details.Team.Name
+		}
+	case `monitoring.name`:
+		if details.Monitoring != nil {
+			return details.Monitoring.Name
+		}
+	case `oncall.name`:
+		if details.Oncall != nil && details.Oncall.ID != `` {
+			return details.Oncall.Name
+		}
+	case `oncall.number`:
+		if details.Oncall != nil && details.Oncall.ID != `` {
+			return details.Oncall.Number
+		}
+	}
+	return ``
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
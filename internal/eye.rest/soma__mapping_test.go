@@ -0,0 +1,129 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mjolnir42/soma/lib/proto"
+	cfg "github.com/solnx/eye/internal/eye.cfg"
+	wall "github.com/solnx/eye/lib/eye.wall"
+)
+
+func TestProcessDeploymentDetailsLookupID(t *testing.T) {
+	mapping := cfg.DefaultMapping()
+	x := &Rest{mapping: &mapping}
+
+	tests := []struct {
+		metric  string
+		service string
+		want    string
+	}{
+		{
+			metric:  `cpu.usage.percent`,
+			service: `{"name":"service"}`,
+			want:    `cpu.usage.percent`,
+		},
+		{
+			metric:  `disk.free`,
+			service: `{"name":"service","attributes":[{"name":"filesystem","value":"/var"}]}`,
+			want:    `disk.free:/var`,
+		},
+	}
+
+	for _, tt := range tests {
+		details := testDeployment(`rollout`, 42, tt.metric)
+		if err := json.Unmarshal([]byte(tt.service), &details.Service); err != nil {
+			t.Fatalf("%s: %s", tt.metric, err.Error())
+		}
+
+		lookupID, config, err := x.processDeploymentDetails(&details)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.metric, err.Error())
+		}
+		if config.Metric != tt.want {
+			t.Errorf("%s: metric is %s, want %s", tt.metric, config.Metric, tt.want)
+		}
+		// configurations stored before the metric was rewritten keep
+		// their lookupID, which is calculated from the SOMA metric path
+		if want := wall.CalculateLookupID(42, tt.metric); lookupID != want {
+			t.Errorf("%s: lookupID is %s, existing configuration has %s", tt.metric, lookupID, want)
+		}
+		if config.LookupID != lookupID {
+			t.Errorf("%s: configuration lookupID is %s, want %s", tt.metric, config.LookupID, lookupID)
+		}
+	}
+}
+
+func TestProcessDeploymentDetailsExistingDisk(t *testing.T) {
+	mapping := cfg.DefaultMapping()
+	x := &Rest{mapping: &mapping}
+
+	// lookupID of a disk.free configuration for the filesystem /var on
+	// asset 42, as stored before the metric rewrite was configurable
+	const existing = `4fd46dbc3b0e831f7fcaecf96b0e922bbeaa9c2e18b7df6c1323285846e9ed4f`
+
+	details := testDeployment(`rollout`, 42, `disk.free`)
+	if err := json.Unmarshal(
+		[]byte(`{"name":"service","attributes":[{"name":"filesystem","value":"/var"}]}`),
+		&details.Service,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	lookupID, config, err := x.processDeploymentDetails(&details)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if lookupID != existing || config.LookupID != existing {
+		t.Errorf("lookupID is %s/%s, existing configuration has %s",
+			lookupID, config.LookupID, existing)
+	}
+}
+
+func TestProcessDeploymentDetailsValidation(t *testing.T) {
+	mapping := cfg.DefaultMapping()
+	x := &Rest{mapping: &mapping}
+
+	tests := []struct {
+		name   string
+		modify func(*proto.Deployment)
+		valid  bool
+	}{
+		{
+			name:   `translated deployment`,
+			modify: func(d *proto.Deployment) {},
+			valid:  true,
+		},
+		{
+			name:   `invalid configurationID`,
+			modify: func(d *proto.Deployment) { d.CheckInstance.InstanceID = `garbage` },
+		},
+		{
+			name:   `missing team`,
+			modify: func(d *proto.Deployment) { d.Team.Name = `` },
+		},
+	}
+
+	for _, tt := range tests {
+		details := testDeployment(`rollout`, 42, `cpu.usage.percent`)
+		tt.modify(&details)
+
+		_, _, err := x.processDeploymentDetails(&details)
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err.Error())
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: no validation error", tt.name)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
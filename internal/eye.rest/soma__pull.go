@@ -0,0 +1,73 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"log"
+	"time"
+
+	msg "github.com/solnx/eye/internal/eye.msg"
+)
+
+// somaPull periodically polls SOMA for pending deployments if
+// pull-mode ingestion is enabled
+func (x *Rest) somaPull() {
+	if !x.pullConf.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(x.pullConf.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if ShutdownInProgress {
+			return
+		}
+		if err := x.somaPullRun(); err != nil {
+			log.Println(`Deployment pull`, `Error`, err.Error())
+		}
+	}
+}
+
+// somaPullRun fetches all pending deployments from SOMA and processes
+// them. Deployment feedback sent for the processed deployments
+// acknowledges them with SOMA.
+func (x *Rest) somaPullRun() error {
+	deployments, err := x.somaFetchDeployments(
+		x.pullConf.Path,
+		x.pullConf.MonitoringID,
+	)
+	if err != nil {
+		return err
+	}
+	if len(deployments) == 0 {
+		return nil
+	}
+
+	request := msg.NewInternal()
+	request.Section = msg.SectionDeployment
+	request.Action = msg.ActionProcess
+
+	result := x.deploymentBatchProcess(&request, deployments)
+	if len(result.Batch) == 0 {
+		return result.Error
+	}
+	for i := range result.Batch {
+		// exportV2 resets the flags of failed results
+		x.exportV2(&result.Batch[i])
+		x.postProcess(&result.Batch[i])
+		if result.Batch[i].Error != nil {
+			log.Println(`Deployment pull`, `RequestID`, result.Batch[i].ID,
+				`Error`, result.Batch[i].Error.Error())
+		}
+	}
+	return nil
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,146 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pushHeaderSignature carries the hex encoded HMAC-SHA256 of a
+	// push notification, prefixed with sha256=
+	pushHeaderSignature = `X-Soma-Signature`
+	// pushHeaderTimestamp carries the unix time at which a push
+	// notification was signed
+	pushHeaderTimestamp = `X-Soma-Timestamp`
+	// pushBodyLimit caps the size of accepted push notifications
+	pushBodyLimit = 64 * 1024
+)
+
+// pushState holds the signatures of recently accepted push
+// notifications for replay detection
+type pushState struct {
+	lock sync.Mutex
+	seen map[string]time.Time
+}
+
+// newPushState returns an initialized pushState
+func newPushState() *pushState {
+	return &pushState{
+		seen: map[string]time.Time{},
+	}
+}
+
+// pushVerify checks the HMAC signature and timestamp of the push
+// notification in r against the configured shared secret. The
+// request body is restored for decoding.
+func (x *Rest) pushVerify(r *http.Request) error {
+	if x.pushConf.Secret == `` {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, pushBodyLimit+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > pushBodyLimit {
+		return fmt.Errorf("Push notification exceeds %d bytes", pushBodyLimit)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	timestamp := r.Header.Get(pushHeaderTimestamp)
+	signature := strings.TrimPrefix(r.Header.Get(pushHeaderSignature), `sha256=`)
+	if timestamp == `` || signature == `` {
+		return fmt.Errorf("Push notification is not signed")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid push notification timestamp: %s", timestamp)
+	}
+	window := time.Duration(x.pushConf.Window) * time.Second
+	signedAt := time.Unix(unix, 0)
+	if skew := time.Since(signedAt); skew > window || skew < -window {
+		return fmt.Errorf("Push notification timestamp outside of replay window")
+	}
+
+	mac, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("Invalid push notification signature encoding")
+	}
+	if !hmac.Equal(mac, pushSignature(x.pushConf.Secret, timestamp, body)) {
+		return fmt.Errorf("Invalid push notification signature")
+	}
+
+	// the replay check uses the canonical encoding of the signature,
+	// since hex decoding accepts upper and lower case
+	return x.push.register(hex.EncodeToString(mac), signedAt.Add(window))
+}
+
+// pushPathAllowed returns the cleaned pathPrefix and whether it is
+// covered by the configured notification path prefixes
+func (x *Rest) pushPathAllowed(pathPrefix string) (string, bool) {
+	cleaned := path.Clean(pathPrefix)
+	if len(x.pushConf.PathPrefixes) == 0 {
+		return cleaned, true
+	}
+
+	for _, allowed := range x.pushConf.PathPrefixes {
+		allowed = path.Clean(`/` + allowed)
+		if cleaned == allowed || allowed == `/` ||
+			strings.HasPrefix(cleaned, allowed+`/`) {
+			return cleaned, true
+		}
+	}
+	return cleaned, false
+}
+
+// register records an accepted signature until expires and rejects
+// signatures that have already been seen
+func (p *pushState) register(signature string, expires time.Time) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	for sig, exp := range p.seen {
+		if now.After(exp) {
+			delete(p.seen, sig)
+		}
+	}
+
+	if _, ok := p.seen[signature]; ok {
+		return fmt.Errorf("Replayed push notification")
+	}
+	p.seen[signature] = expires
+	return nil
+}
+
+// pushSignature returns the HMAC-SHA256 over timestamp and body,
+// separated by a dot
+func pushSignature(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte(`.`))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,151 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	cfg "github.com/solnx/eye/internal/eye.cfg"
+)
+
+const testPushSecret = `secret`
+
+// testPushRest returns a Rest that verifies push notifications with
+// testPushSecret
+func testPushRest() *Rest {
+	return &Rest{
+		pushConf: &cfg.Push{
+			Secret: testPushSecret,
+			Window: 300,
+		},
+		push: newPushState(),
+	}
+}
+
+// testPushRequest returns a push notification request for body,
+// signed at ts with secret
+func testPushRequest(secret string, ts time.Time, body string) *http.Request {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	r := httptest.NewRequest(http.MethodPost, `/api/v1/notify/`,
+		strings.NewReader(body))
+	r.Header.Set(pushHeaderTimestamp, timestamp)
+	r.Header.Set(pushHeaderSignature, `sha256=`+hex.EncodeToString(
+		pushSignature(secret, timestamp, []byte(body)),
+	))
+	return r
+}
+
+func TestPushVerify(t *testing.T) {
+	x := testPushRest()
+	body := `{"uuid":"8f1f6d41-7a3b-4c4e-9d7e-0b1e2f3a4b5c"}`
+
+	r := testPushRequest(testPushSecret, time.Now(), body)
+	if err := x.pushVerify(r); err != nil {
+		t.Fatalf("valid notification rejected: %s", err.Error())
+	}
+
+	// the body is restored for decoding
+	restored, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("reading restored body: %s", err.Error())
+	}
+	if string(restored) != body {
+		t.Errorf("restored body is %q, want %q", restored, body)
+	}
+}
+
+func TestPushVerifyReplay(t *testing.T) {
+	x := testPushRest()
+	ts := time.Now()
+	body := `{}`
+
+	if err := x.pushVerify(testPushRequest(testPushSecret, ts, body)); err != nil {
+		t.Fatalf("valid notification rejected: %s", err.Error())
+	}
+	if err := x.pushVerify(testPushRequest(testPushSecret, ts, body)); err == nil {
+		t.Error("replayed notification accepted")
+	}
+
+	// the same signature in upper case is a replay as well
+	r := testPushRequest(testPushSecret, ts, body)
+	r.Header.Set(pushHeaderSignature, `sha256=`+strings.ToUpper(
+		strings.TrimPrefix(r.Header.Get(pushHeaderSignature), `sha256=`),
+	))
+	if err := x.pushVerify(r); err == nil {
+		t.Error("replayed notification with upper case signature accepted")
+	}
+}
+
+func TestPushVerifyRejects(t *testing.T) {
+	body := `{}`
+	now := time.Now()
+
+	unsigned := httptest.NewRequest(http.MethodPost, `/api/v1/notify/`,
+		strings.NewReader(body))
+
+	noTimestamp := testPushRequest(testPushSecret, now, body)
+	noTimestamp.Header.Del(pushHeaderTimestamp)
+
+	badTimestamp := testPushRequest(testPushSecret, now, body)
+	badTimestamp.Header.Set(pushHeaderTimestamp, `yesterday`)
+
+	badEncoding := testPushRequest(testPushSecret, now, body)
+	badEncoding.Header.Set(pushHeaderSignature, `sha256=zz`)
+
+	tampered := testPushRequest(testPushSecret, now, body)
+	tampered.Body = ioutil.NopCloser(strings.NewReader(`{"tampered":true}`))
+
+	oversized := testPushRequest(testPushSecret, now, body)
+	oversized.Body = ioutil.NopCloser(bytes.NewReader(
+		make([]byte, pushBodyLimit+1),
+	))
+
+	tests := []struct {
+		name string
+		r    *http.Request
+	}{
+		{`unsigned`, unsigned},
+		{`missing timestamp`, noTimestamp},
+		{`malformed timestamp`, badTimestamp},
+		{`malformed signature`, badEncoding},
+		{`wrong secret`, testPushRequest(`other`, now, body)},
+		{`tampered body`, tampered},
+		{`expired`, testPushRequest(testPushSecret, now.Add(-time.Hour), body)},
+		{`future`, testPushRequest(testPushSecret, now.Add(time.Hour), body)},
+		{`oversized`, oversized},
+	}
+
+	for _, tc := range tests {
+		if err := testPushRest().pushVerify(tc.r); err == nil {
+			t.Errorf("%s: notification accepted", tc.name)
+		}
+	}
+}
+
+func TestPushVerifyDisabled(t *testing.T) {
+	x := testPushRest()
+	x.pushConf.Secret = ``
+
+	r := httptest.NewRequest(http.MethodPost, `/api/v1/notify/`,
+		strings.NewReader(`{}`))
+	if err := x.pushVerify(r); err != nil {
+		t.Errorf("unsigned notification rejected without secret: %s",
+			err.Error())
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,315 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/mjolnir42/soma/lib/proto"
+	uuid "github.com/satori/go.uuid"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	eyeproto "github.com/solnx/eye/lib/eye.proto"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// reconcileState holds the state of the SOMA reconciliation
+type reconcileState struct {
+	// run serializes reconciliation runs
+	run sync.Mutex
+	// lock protects report
+	lock   sync.RWMutex
+	report *v2.Reconciliation
+}
+
+// somaReconcile periodically reconciles the configurations in eye
+// with the deployments known to SOMA
+func (x *Rest) somaReconcile() {
+	if !x.reconcileConf.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(x.reconcileConf.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if ShutdownInProgress {
+			return
+		}
+		if _, err := x.somaReconcileRun(x.reconcileConf.Apply); err != nil {
+			log.Println(`Reconciliation`, `Error`, err.Error())
+		}
+	}
+}
+
+// somaReconcileReport returns the report of the last reconciliation
+// run, or nil if none has finished yet
+func (x *Rest) somaReconcileReport() *v2.Reconciliation {
+	x.recon.lock.RLock()
+	defer x.recon.lock.RUnlock()
+	return x.recon.report
+}
+
+// somaReconcileRun performs one reconciliation run. If apply is true,
+// drift that can be resolved by processing the SOMA deployment is
+// applied. Configurations unknown to SOMA are only reported.
+func (x *Rest) somaReconcileRun(apply bool) (*v2.Reconciliation, error) {
+	x.recon.run.Lock()
+	defer x.recon.run.Unlock()
+
+	report := &v2.Reconciliation{
+		ID:           uuid.Must(uuid.NewV4()).String(),
+		MonitoringID: x.reconcileConf.MonitoringID,
+		StartedAt:    time.Now().UTC().Format(eyeproto.RFC3339Milli),
+		Apply:        apply,
+		Drift:        []v2.Drift{},
+	}
+
+	deployments, err := x.somaFetchDeployments(
+		x.reconcileConf.Path,
+		x.reconcileConf.MonitoringID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	report.DeploymentCount = len(deployments)
+
+	configurations, err := x.reconcileConfigurationIDs()
+	if err != nil {
+		return nil, err
+	}
+	report.ConfigurationCount = len(configurations)
+
+	// compare the SOMA deployments with the valid configurations
+	pending := map[int]*proto.Deployment{}
+	known := map[string]bool{}
+	for i := range deployments {
+		details := &deployments[i]
+		if details.CheckInstance == nil {
+			continue
+		}
+		configurationID := details.CheckInstance.InstanceID
+		known[configurationID] = true
+
+		var want v2.Configuration
+		var have *v2.Configuration
+		drift := v2.Drift{
+			ConfigurationID: configurationID,
+			DeploymentID:    details.CheckInstance.InstanceConfigID,
+			Task:            details.Task,
+		}
+
+		switch details.Task {
+		case msg.TaskRollout, msg.TaskUpdate:
+			if _, want, err = x.processDeploymentDetails(details); err != nil {
+				report.Errors = append(report.Errors,
+					fmt.Sprintf("Deployment %s: %s", drift.DeploymentID, err.Error()))
+				continue
+			}
+			drift.LookupID = want.LookupID
+
+			if !configurations[configurationID] {
+				drift.Kind = v2.DriftMissing
+				break
+			}
+			if have, err = x.reconcileConfiguration(configurationID); err != nil {
+				report.Errors = append(report.Errors,
+					fmt.Sprintf("Configuration %s: %s", configurationID, err.Error()))
+				continue
+			}
+			if !configurationDiffers(&want, have) {
+				continue
+			}
+			drift.Kind = v2.DriftOutdated
+
+		case msg.TaskDeprovision, msg.TaskDelete:
+			if !configurations[configurationID] {
+				continue
+			}
+			drift.Kind = v2.DriftStale
+
+		default:
+			continue
+		}
+
+		report.Drift = append(report.Drift, drift)
+		pending[len(report.Drift)-1] = details
+	}
+
+	// configurations provisioned from files are not managed by SOMA
+	files, err := x.configurationsFromSource(msg.SourceFile)
+	if err != nil {
+		return nil, err
+	}
+	for configurationID := range configurations {
+		if _, ok := files[configurationID]; ok || known[configurationID] {
+			continue
+		}
+		report.Drift = append(report.Drift, v2.Drift{
+			Kind:            v2.DriftUnknown,
+			ConfigurationID: configurationID,
+		})
+	}
+
+	if apply {
+		for i, details := range pending {
+			if err = x.reconcileApply(details); err != nil {
+				report.Drift[i].Error = err.Error()
+				continue
+			}
+			report.Drift[i].Applied = true
+		}
+	}
+	report.FinishedAt = time.Now().UTC().Format(eyeproto.RFC3339Milli)
+
+	x.recon.lock.Lock()
+	x.recon.report = report
+	x.recon.lock.Unlock()
+	return report, nil
+}
+
+// somaFetchDeployments returns the deployments SOMA lists below
+// listPath for monitoring system monitoringID
+func (x *Rest) somaFetchDeployments(listPath, monitoringID string) ([]proto.Deployment, error) {
+	var res proto.Result
+
+	if monitoringID == `` {
+		return nil, fmt.Errorf("No SOMA monitoring system configured")
+	}
+
+	soma, err := url.Parse(x.conf.Eye.SomaURL)
+	if err != nil {
+		return nil, err
+	}
+	soma.Path = fmt.Sprintf("/%s/%s",
+		listPath,
+		monitoringID,
+	)
+	foldSlashes(soma)
+
+	resp, err := x.httpClient().R().Get(soma.String())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != 200 {
+		return nil, fmt.Errorf("SOMA responded with: %s", resp.Status())
+	}
+	if err = json.Unmarshal(resp.Body(), &res); err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("SOMA: %d/%s", res.StatusCode, res.StatusText)
+	}
+	if res.Deployments == nil {
+		return []proto.Deployment{}, nil
+	}
+	return *res.Deployments, nil
+}
+
+// reconcileConfigurationIDs returns the set of currently valid
+// configurationIDs
+func (x *Rest) reconcileConfigurationIDs() (map[string]bool, error) {
+	request := msg.NewInternal()
+	request.Section = msg.SectionConfiguration
+	request.Action = msg.ActionList
+
+	x.handlerMap.Get(`configuration_r`).Intake() <- request
+	result := <-request.Reply
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	configurations := map[string]bool{}
+	for _, configuration := range result.Configuration {
+		configurations[configuration.ID] = true
+	}
+	return configurations, nil
+}
+
+// reconcileConfiguration returns the currently valid version of a
+// configuration
+func (x *Rest) reconcileConfiguration(configurationID string) (*v2.Configuration, error) {
+	request := msg.NewInternal()
+	request.Section = msg.SectionConfiguration
+	request.Action = msg.ActionShow
+	request.Configuration.ID = configurationID
+
+	x.handlerMap.Get(`configuration_r`).Intake() <- request
+	result := <-request.Reply
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(result.Configuration) == 0 {
+		return nil, fmt.Errorf("Configuration %s not found", configurationID)
+	}
+	return &result.Configuration[0], nil
+}
+
+// reconcileApply processes a SOMA deployment found during
+// reconciliation. Previously recorded outcomes for the deployment are
+// bypassed, since the authoritative state is enforced.
+func (x *Rest) reconcileApply(details *proto.Deployment) error {
+	request := msg.NewInternal()
+	request.Section = msg.SectionDeployment
+	request.Action = msg.ActionProcess
+
+	item, err := x.deploymentRequest(&request, details)
+	if err != nil {
+		return err
+	}
+	item.Deployment = v2.Deployment{}
+
+	x.handlerMap.Get(`deployment_w`).Intake() <- item
+	result := <-item.Reply
+	x.postProcess(&result)
+	return result.Error
+}
+
+// configurationDiffers returns true if the currently valid
+// configuration have differs from the translated SOMA deployment want
+func configurationDiffers(want, have *v2.Configuration) bool {
+	switch {
+	case want.LookupID != have.LookupID:
+		return true
+	case want.HostID != have.HostID:
+		return true
+	case want.Metric != have.Metric:
+		return true
+	case len(want.Data) != len(have.Data):
+		return true
+	}
+
+	for i := range want.Data {
+		switch {
+		case want.Data[i].Interval != have.Data[i].Interval:
+			return true
+		case want.Data[i].Targethost != have.Data[i].Targethost:
+			return true
+		case len(want.Data[i].Thresholds) != len(have.Data[i].Thresholds):
+			return true
+		}
+
+		thresholds := map[v2.Threshold]bool{}
+		for _, thr := range have.Data[i].Thresholds {
+			thresholds[thr] = true
+		}
+		for _, thr := range want.Data[i].Thresholds {
+			if !thresholds[thr] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,344 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/mjolnir42/erebos"
+	"github.com/mjolnir42/limit"
+	"github.com/mjolnir42/soma/lib/proto"
+	uuid "github.com/satori/go.uuid"
+	"github.com/solnx/eye/internal/eye"
+	cfg "github.com/solnx/eye/internal/eye.cfg"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+	wall "github.com/solnx/eye/lib/eye.wall"
+)
+
+const (
+	testReconcileMonitoringID = `monitoring`
+	testReconcilePath         = `/deployments/monitoring`
+	testReconcilePrefix       = `/deployments/id`
+)
+
+// testHandler is an application handler that answers every request
+// with reply
+type testHandler struct {
+	input chan msg.Request
+	reply func(*msg.Request) msg.Result
+}
+
+// newTestHandler returns a started testHandler
+func newTestHandler(reply func(*msg.Request) msg.Result) *testHandler {
+	h := &testHandler{
+		input: make(chan msg.Request, 16),
+		reply: reply,
+	}
+	go h.Run()
+	return h
+}
+
+// Register implements eye.Handler
+func (h *testHandler) Register(*sql.DB, ...*logrus.Logger) {}
+
+// Run implements eye.Handler
+func (h *testHandler) Run() {
+	for q := range h.input {
+		q.Reply <- h.reply(&q)
+	}
+}
+
+// ShutdownNow implements eye.Handler
+func (h *testHandler) ShutdownNow() {
+	close(h.input)
+}
+
+// Intake implements eye.Handler
+func (h *testHandler) Intake() chan msg.Request {
+	return h.input
+}
+
+// testReconcile is the eye side of a reconciliation test, it holds the
+// valid configurations and records the processed deployments
+type testReconcile struct {
+	lock           sync.Mutex
+	configurations map[string]v2.Configuration
+	files          map[string]bool
+	processed      []msg.Request
+}
+
+// configuration answers the requests of the configuration_r handler
+func (tr *testReconcile) configuration(q *msg.Request) msg.Result {
+	tr.lock.Lock()
+	defer tr.lock.Unlock()
+
+	result := msg.FromRequest(q)
+	result.Code = msg.ResultOK
+	switch q.Action {
+	case msg.ActionList:
+		for id, configuration := range tr.configurations {
+			if q.Search.Provision.Source == msg.SourceFile && !tr.files[id] {
+				continue
+			}
+			result.Configuration = append(result.Configuration, configuration)
+		}
+	case msg.ActionShow:
+		configuration, ok := tr.configurations[q.Configuration.ID]
+		if !ok {
+			result.Code = msg.ResultNotFound
+			result.Error = fmt.Errorf("Configuration %s not found", q.Configuration.ID)
+			break
+		}
+		result.Configuration = []v2.Configuration{configuration}
+	}
+	return result
+}
+
+// deployment answers the requests of the deployment_w handler
+func (tr *testReconcile) deployment(q *msg.Request) msg.Result {
+	tr.lock.Lock()
+	defer tr.lock.Unlock()
+
+	tr.processed = append(tr.processed, *q)
+	result := msg.FromRequest(q)
+	result.Code = msg.ResultOK
+	result.Configuration = []v2.Configuration{q.Configuration}
+	return result
+}
+
+// empty answers all other requests without data
+func (tr *testReconcile) empty(q *msg.Request) msg.Result {
+	result := msg.FromRequest(q)
+	result.Code = msg.ResultOK
+	return result
+}
+
+// testReconcileRest returns a Rest that reconciles the deployments of
+// soma with the configurations of tr
+func testReconcileRest(soma *fakeSoma, tr *testReconcile) *Rest {
+	conf := &erebos.Config{}
+	conf.Eye.SomaURL = soma.URL()
+	conf.Eye.SomaPrefix = testReconcilePrefix
+	conf.Eye.RequestTimeout = 1000
+
+	hm := &eye.HandlerMap{}
+	hm.Add(`configuration_r`, newTestHandler(tr.configuration))
+	hm.Add(`deployment_w`, newTestHandler(tr.deployment))
+	for _, name := range []string{`feedback_w`, `lookup_r`, `subscription_r`} {
+		hm.Add(name, newTestHandler(tr.empty))
+	}
+
+	mapping := cfg.DefaultMapping()
+	return &Rest{
+		handlerMap:       hm,
+		conf:             conf,
+		transport:        newLimitTransport(limit.New(4)),
+		webhookTransport: newLimitTransport(limit.New(4)),
+		webhookWake:      make(chan struct{}, 1),
+		invl:             wall.NewInvalidation(conf),
+		registryConf:     &cfg.Registry{},
+		mapping:          &mapping,
+		reconcileConf: &cfg.Reconciliation{
+			MonitoringID: testReconcileMonitoringID,
+			Path:         testReconcilePath,
+		},
+		recon: &reconcileState{},
+	}
+}
+
+// testDeployment returns a SOMA deployment of task for metric on
+// asset
+func testDeployment(task string, asset uint64, metric string) proto.Deployment {
+	return proto.Deployment{
+		Task:       task,
+		Node:       &proto.Node{AssetID: asset, Name: `host.example.org`},
+		Metric:     &proto.Metric{Path: metric},
+		Monitoring: &proto.Monitoring{Name: testReconcileMonitoringID},
+		Team:       &proto.Team{Name: `team`},
+		CheckConfig: &proto.CheckConfig{
+			Interval: 60,
+		},
+		CheckInstance: &proto.CheckInstance{
+			InstanceID:       uuid.Must(uuid.NewV4()).String(),
+			InstanceConfigID: uuid.Must(uuid.NewV4()).String(),
+		},
+	}
+}
+
+func TestSomaReconcileRun(t *testing.T) {
+	soma := newFakeSoma(testReconcileMonitoringID, testReconcilePath,
+		testReconcilePrefix)
+	defer soma.Close()
+
+	tr := &testReconcile{
+		configurations: map[string]v2.Configuration{},
+		files:          map[string]bool{},
+	}
+	x := testReconcileRest(soma, tr)
+
+	missing := testDeployment(msg.TaskRollout, 1, `cpu.usage`)
+	current := testDeployment(msg.TaskRollout, 2, `cpu.usage`)
+	outdated := testDeployment(msg.TaskRollout, 3, `cpu.usage`)
+	stale := testDeployment(msg.TaskDeprovision, 4, `cpu.usage`)
+	removed := testDeployment(msg.TaskDelete, 5, `cpu.usage`)
+	for _, d := range []proto.Deployment{missing, current, outdated, stale, removed} {
+		soma.SetDeployment(d)
+	}
+
+	for _, d := range []proto.Deployment{current, outdated, stale} {
+		_, configuration, err := x.processDeploymentDetails(&d)
+		if err != nil {
+			t.Fatalf("processDeploymentDetails: %s", err.Error())
+		}
+		tr.configurations[configuration.ID] = configuration
+	}
+	changed := tr.configurations[outdated.CheckInstance.InstanceID]
+	changed.Data[0].Interval = 300
+	tr.configurations[changed.ID] = changed
+
+	unknown := v2.Configuration{
+		ID:   uuid.Must(uuid.NewV4()).String(),
+		Data: []v2.Data{{}},
+	}
+	file := v2.Configuration{
+		ID:   uuid.Must(uuid.NewV4()).String(),
+		Data: []v2.Data{{Info: v2.MetaInformation{Revision: `rev`}}},
+	}
+	tr.configurations[unknown.ID] = unknown
+	tr.configurations[file.ID] = file
+	tr.files[file.ID] = true
+
+	report, err := x.somaReconcileRun(false)
+	if err != nil {
+		t.Fatalf("somaReconcileRun: %s", err.Error())
+	}
+	if len(report.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", report.Errors)
+	}
+	if report.DeploymentCount != 5 || report.ConfigurationCount != 5 {
+		t.Errorf("counted %d deployments and %d configurations, expected 5 and 5",
+			report.DeploymentCount, report.ConfigurationCount)
+	}
+
+	expected := map[string]string{
+		missing.CheckInstance.InstanceID:  v2.DriftMissing,
+		outdated.CheckInstance.InstanceID: v2.DriftOutdated,
+		stale.CheckInstance.InstanceID:    v2.DriftStale,
+		unknown.ID:                        v2.DriftUnknown,
+	}
+	if len(report.Drift) != len(expected) {
+		t.Fatalf("reported %d drifts, expected %d: %+v",
+			len(report.Drift), len(expected), report.Drift)
+	}
+	for _, drift := range report.Drift {
+		kind, ok := expected[drift.ConfigurationID]
+		if !ok {
+			t.Errorf("unexpected drift for configuration %s: %s",
+				drift.ConfigurationID, drift.Kind)
+			continue
+		}
+		if drift.Kind != kind {
+			t.Errorf("configuration %s reported as %s, expected %s",
+				drift.ConfigurationID, drift.Kind, kind)
+		}
+		if drift.Applied {
+			t.Errorf("configuration %s applied without apply", drift.ConfigurationID)
+		}
+	}
+	if len(tr.processed) != 0 {
+		t.Errorf("processed %d deployments without apply", len(tr.processed))
+	}
+	if x.somaReconcileReport() != report {
+		t.Errorf("report of the run was not recorded")
+	}
+}
+
+func TestSomaReconcileRunApply(t *testing.T) {
+	soma := newFakeSoma(testReconcileMonitoringID, testReconcilePath,
+		testReconcilePrefix)
+	defer soma.Close()
+
+	tr := &testReconcile{
+		configurations: map[string]v2.Configuration{},
+		files:          map[string]bool{},
+	}
+	x := testReconcileRest(soma, tr)
+
+	missing := testDeployment(msg.TaskRollout, 1, `cpu.usage`)
+	stale := testDeployment(msg.TaskDeprovision, 2, `cpu.usage`)
+	soma.SetDeployment(missing)
+	soma.SetDeployment(stale)
+
+	_, configuration, err := x.processDeploymentDetails(&stale)
+	if err != nil {
+		t.Fatalf("processDeploymentDetails: %s", err.Error())
+	}
+	tr.configurations[configuration.ID] = configuration
+
+	unknown := v2.Configuration{
+		ID:   uuid.Must(uuid.NewV4()).String(),
+		Data: []v2.Data{{}},
+	}
+	tr.configurations[unknown.ID] = unknown
+
+	report, err := x.somaReconcileRun(true)
+	if err != nil {
+		t.Fatalf("somaReconcileRun: %s", err.Error())
+	}
+	if !report.Apply {
+		t.Errorf("report does not record the apply")
+	}
+	for _, drift := range report.Drift {
+		if drift.Error != `` {
+			t.Errorf("configuration %s: %s", drift.ConfigurationID, drift.Error)
+		}
+		switch drift.Kind {
+		case v2.DriftUnknown:
+			if drift.Applied {
+				t.Errorf("unknown configuration %s applied", drift.ConfigurationID)
+			}
+		default:
+			if !drift.Applied {
+				t.Errorf("%s configuration %s not applied",
+					drift.Kind, drift.ConfigurationID)
+			}
+		}
+	}
+
+	tr.lock.Lock()
+	defer tr.lock.Unlock()
+	if len(tr.processed) != 2 {
+		t.Fatalf("processed %d deployments, expected 2", len(tr.processed))
+	}
+	tasks := map[string]string{}
+	for _, q := range tr.processed {
+		if q.Section != msg.SectionDeployment || q.Action != msg.ActionProcess {
+			t.Errorf("deployment processed as %s/%s", q.Section, q.Action)
+		}
+		if q.Deployment.ID != `` {
+			t.Errorf("deployment %s not bypassing recorded outcomes", q.Deployment.ID)
+		}
+		tasks[q.Configuration.ID] = q.ConfigurationTask
+	}
+	if tasks[missing.CheckInstance.InstanceID] != msg.TaskRollout {
+		t.Errorf("missing configuration processed as %q",
+			tasks[missing.CheckInstance.InstanceID])
+	}
+	if tasks[stale.CheckInstance.InstanceID] != msg.TaskDeprovision {
+		t.Errorf("stale configuration processed as %q",
+			tasks[stale.CheckInstance.InstanceID])
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -14,76 +14,100 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
-	"github.com/go-resty/resty"
 	msg "github.com/solnx/eye/internal/eye.msg"
 	uuid "github.com/satori/go.uuid"
+	"github.com/solnx/eye/lib/eye.proto/v2"
 )
 
 // somaStatusUpdate encapsulates the handling of deployment feedback
-// notifications to SOMA
+// notifications to SOMA. The feedback is persisted before the first
+// delivery attempt, failed deliveries are retried by somaFeedbackRetry
 func (x *Rest) somaStatusUpdate(r *msg.Result) {
 	if !r.Flags.SendDeploymentFeedback {
 		return
 	}
 
-	var feedback string
+	var outcome string
 
 	switch {
 	case r.Error != nil:
-		feedback = `failed`
+		outcome = msg.OutcomeFailed
 	case r.Code >= 400:
-		feedback = `failed`
+		outcome = msg.OutcomeFailed
 	default:
-		feedback = `success`
+		outcome = msg.OutcomeSuccess
 	}
 
-	url := strings.Replace(r.FeedbackURL, `{STATUS}`, feedback, -1)
-
-	client := resty.New().
-		// set generic client options
-		SetDisableWarn(true).
-		SetHeader(`Content-Type`, `application/json`).
-		SetContentLength(true).
-		// follow redirects
-		SetRedirectPolicy(resty.FlexibleRedirectPolicy(5)).
-		// configure request retry
-		SetRetryCount(x.conf.Eye.RetryCount).
-		SetRetryWaitTime(time.Duration(x.conf.Eye.RetryMinWaitTime) * time.Millisecond).
-		SetRetryMaxWaitTime(time.Duration(x.conf.Eye.RetryMaxWaitTime) * time.Millisecond).
-		// reset timeout deadline before every request
-		OnBeforeRequest(func(cl *resty.Client, rq *resty.Request) error {
-			cl.SetTimeout(time.Duration(x.conf.Eye.RequestTimeout) * time.Millisecond)
-			return nil
-		}).
-		// enter concurrency limit before performing request
-		OnBeforeRequest(func(cl *resty.Client, rq *resty.Request) error {
-			x.limit.Start()
-			return nil
-		}).
-		// leave concurrency limit after receiving a response
-		OnAfterResponse(func(cl *resty.Client, rp *resty.Response) error {
-			x.limit.Done()
-			return nil
-		}).
-		// clear timeout deadline after each request (http.Client
-		// timeout also cancels reading the response body)
-		OnAfterResponse(func(cl *resty.Client, rp *resty.Response) error {
-			cl.SetTimeout(0)
-			return nil
-		})
-
-	res, err := client.R().Patch(url)
-	if err != nil {
-		log.Println(`RequestID`, r.ID.String(), `DeploymentID`, r.Configuration[0].ID, `Error`, err.Error())
+	feedback := v2.Feedback{
+		RequestID: r.ID.String(),
+		Task:      r.ConfigurationTask,
+		Outcome:   outcome,
+		URL:       strings.Replace(r.FeedbackURL, `{STATUS}`, outcome, -1),
+	}
+	// failed requests might not have a configuration
+	if len(r.Configuration) > 0 {
+		feedback.ConfigurationID = r.Configuration[0].ID
+	}
+
+	res := x.somaFeedbackUpdate(msg.ActionAdd, feedback)
+	if res.Error != nil || len(res.Feedback) == 0 {
+		log.Println(`RequestID`, r.ID.String(), `DeploymentID`,
+			feedback.ConfigurationID, `Error`, `failed to persist feedback`,
+			res.Error)
+		// still attempt the delivery, but it can not be retried
+		x.somaFeedbackDeliver(&feedback)
 		return
 	}
+	feedback = res.Feedback[0]
+	x.somaFeedbackAttempt(&feedback)
+}
+
+// somaFeedbackAttempt delivers feedback to SOMA and records the
+// outcome of the delivery attempt
+func (x *Rest) somaFeedbackAttempt(feedback *v2.Feedback) error {
+	action := msg.ActionAcknowledge
+	err := x.somaFeedbackDeliver(feedback)
+	if err != nil {
+		action = msg.ActionReschedule
+		feedback.LastError = err.Error()
+	}
+
+	res := x.somaFeedbackUpdate(action, *feedback)
+	if res.Error != nil {
+		log.Println(`FeedbackID`, feedback.ID, `Error`, res.Error.Error())
+	}
+	return err
+}
+
+// somaFeedbackUpdate sends feedback to the feedback_w handler with
+// action and returns the result
+func (x *Rest) somaFeedbackUpdate(action string, feedback v2.Feedback) msg.Result {
+	request := msg.NewInternal()
+	request.Section = msg.SectionFeedback
+	request.Action = action
+	request.Feedback = feedback
+
+	x.handlerMap.Get(`feedback_w`).Intake() <- request
+	return <-request.Reply
+}
+
+// somaFeedbackDeliver sends feedback to SOMA
+func (x *Rest) somaFeedbackDeliver(feedback *v2.Feedback) error {
+	res, err := x.httpClient().R().Patch(feedback.URL)
+	if err != nil {
+		log.Println(`RequestID`, feedback.RequestID, `DeploymentID`,
+			feedback.ConfigurationID, `Error`, err.Error())
+		return err
+	}
 
 	switch res.StatusCode() {
 	case http.StatusOK:
+		return nil
 	default:
-		log.Println(`RequestID`, r.ID.String(), `DeploymentID`, r.Configuration[0].ID, res.StatusCode(), res.Status())
+		log.Println(`RequestID`, feedback.RequestID, `DeploymentID`,
+			feedback.ConfigurationID, res.StatusCode(), res.Status())
+		return fmt.Errorf("SOMA responded with: %s", res.Status())
 	}
 }
 
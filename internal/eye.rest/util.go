@@ -45,6 +45,12 @@ func decodeJSONBody(r *http.Request, s interface{}) (err error) {
 	case *proto.PushNotification:
 		c := s.(*proto.PushNotification)
 		err = decoder.Decode(c)
+	case *proto.Result:
+		c := s.(*proto.Result)
+		err = decoder.Decode(c)
+	case *v2.Request:
+		c := s.(*v2.Request)
+		err = decoder.Decode(c)
 	default:
 		err = fmt.Errorf("decodeJSONBody: unhandled request type: %s", reflect.TypeOf(s))
 	}
@@ -53,9 +59,7 @@ func decodeJSONBody(r *http.Request, s interface{}) (err error) {
 
 // processDeploymentDetails creates an eye protocol configuration from
 // SOMA deployment details
-func processDeploymentDetails(details *proto.Deployment) (string, v2.Configuration, error) {
-	lookupID := calculateLookupID(details.Node.AssetID, details.Metric.Path)
-
+func (x *Rest) processDeploymentDetails(details *proto.Deployment) (string, v2.Configuration, error) {
 	config := v2.Configuration{
 		HostID: details.Node.AssetID,
 		ID:     details.CheckInstance.InstanceID,
@@ -68,37 +72,21 @@ func processDeploymentDetails(details *proto.Deployment) (string, v2.Configurati
 		Thresholds: []v2.Threshold{},
 	}
 
-	// append filesystem to disk metrics
-	switch config.Metric {
-	case
-		`disk.write.per.second`,
-		`disk.read.per.second`,
-		`disk.free`,
-		`disk.usage.percent`:
-		mountpoint := getServiceAttributeValue(details, `filesystem`)
-		if mountpoint == `` {
-			return ``, v2.Configuration{}, fmt.Errorf("Disk metric %s is missing filesystem service attribute", config.Metric)
-		}
-
-		// update metric path and recalculate updated lookupID
-		config.Metric = fmt.Sprintf("%s:%s", config.Metric, mountpoint)
-		lookupID = calculateLookupID(details.Node.AssetID, details.Metric.Path)
+	// apply metric rewrite rules. The lookupID is always calculated
+	// from the SOMA metric path, rewriting the metric must not change
+	// the lookupID of existing configurations
+	metric, err := x.mappingRewriteMetric(details)
+	if err != nil {
+		return ``, v2.Configuration{}, err
 	}
+	config.Metric = metric
+	lookupID := calculateLookupID(config.HostID, details.Metric.Path)
 	config.LookupID = lookupID
 
-	// set oncall duty
-	if details.Oncall != nil && details.Oncall.ID != `` {
-		data.Oncall = fmt.Sprintf("%s (%s)", details.Oncall.Name, details.Oncall.Number)
-	}
-
-	data.Targethost = getTargetHost(details)
-
-	// construct item.Metadata.Source
-	if details.Service != nil && details.Service.Name != `` {
-		data.Source = fmt.Sprintf("%s, %s", details.Service.Name, details.CheckConfig.Name)
-	} else {
-		data.Source = fmt.Sprintf("System (%s), %s", details.Node.Name, details.CheckConfig.Name)
-	}
+	data.Targethost = mappingField(details, x.mapping.Fields.Targethost)
+	data.Source = mappingField(details, x.mapping.Fields.Source)
+	data.Oncall = mappingField(details, x.mapping.Fields.Oncall)
+	data.Tags = x.mappingTags(details)
 
 	// slurp all thresholds
 	for _, thr := range details.CheckConfig.Thresholds {
@@ -111,13 +99,47 @@ func processDeploymentDetails(details *proto.Deployment) (string, v2.Configurati
 	}
 	config.Data = []v2.Data{data}
 
-	govalidator.SetFieldsRequiredByDefault(true)
-	if ok, err := govalidator.ValidateStruct(config); !ok {
+	if !govalidator.IsUUIDv4(config.ID) {
+		return ``, v2.Configuration{}, fmt.Errorf("Invalid configurationID: %s", config.ID)
+	}
+	if err = validateConfiguration(&config); err != nil {
 		return ``, v2.Configuration{}, err
 	}
 	return lookupID, config, nil
 }
 
+// validateConfiguration checks the fields of a configuration that is
+// provisioned from an external source. The struct tags can not be used
+// with fields required by default, since the dataIDs and the
+// informational fields are only set once the configuration is stored
+func validateConfiguration(config *v2.Configuration) error {
+	if config.Metric == `` {
+		return fmt.Errorf("Configuration %s: missing metric", config.ID)
+	}
+	for _, data := range config.Data {
+		if !govalidator.IsHost(data.Targethost) {
+			return fmt.Errorf("Invalid targethost: %s", data.Targethost)
+		}
+		if data.Monitoring == `` || data.Team == `` {
+			return fmt.Errorf("Configuration %s: missing monitoring or team", config.ID)
+		}
+	}
+	return nil
+}
+
+// deploymentFromDetails returns the deployment record for SOMA
+// deployment details. The SOMA deployment ID is the ID of the check
+// instance configuration that is deployed, which is rolled out again
+// with a new version whenever it changes
+func deploymentFromDetails(details *proto.Deployment) v2.Deployment {
+	return v2.Deployment{
+		ID:              details.CheckInstance.InstanceConfigID,
+		Version:         details.CheckInstance.Version,
+		Task:            details.Task,
+		CheckInstanceID: details.CheckInstance.InstanceID,
+	}
+}
+
 // calculateLookupID returns the lookupID hash for a given (id,metric)
 // tuple
 func calculateLookupID(id uint64, metric string) string {
@@ -146,40 +168,20 @@ func getServiceAttributeValue(details *proto.Deployment, attribute string) strin
 	return ``
 }
 
-// getTargetHost returns the hostname of the deployment target
-func getTargetHost(details *proto.Deployment) string {
-	var fqdn, dnsZone string
-
+// getSystemPropertyValue returns the value of the requested system
+// property or the empty string otherwise
+func getSystemPropertyValue(details *proto.Deployment, property string) string {
 	// details.Properties contains only system properties which are
 	// guaranteed to be unique by the SOMA data model
-	if details.Properties != nil {
-		for _, prop := range *details.Properties {
-			switch prop.Name {
-			case `fqdn`:
-				fqdn = prop.Value
-			case `dns_zone`:
-				dnsZone = prop.Value
-			}
-		}
+	if details.Properties == nil {
+		return ``
 	}
-
-	switch {
-	// specified fqdn has the highest priority
-	case fqdn != ``:
-		return fqdn
-
-		// trailing dot prevents appending the configured zone
-	case strings.HasSuffix(details.Node.Name, `.`):
-		return details.Node.Name
-
-		// configured zone is appended to the hostname
-	case dnsZone != ``:
-		return fmt.Sprintf("%s.%s", details.Node.Name, dnsZone)
-
-		// no better data available
-	default:
-		return details.Node.Name
+	for _, prop := range *details.Properties {
+		if prop.Name == property {
+			return prop.Value
+		}
 	}
+	return ``
 }
 
 // resolveFlags sets the request flags of rqInternal based on the user
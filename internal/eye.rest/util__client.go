@@ -0,0 +1,81 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty"
+	"github.com/mjolnir42/limit"
+)
+
+// httpClient returns a resty client for outgoing requests
+func (x *Rest) httpClient() *resty.Client {
+	return x.limitedClient(x.transport)
+}
+
+// limitedClient returns a resty client for outgoing requests that
+// are performed via transport
+func (x *Rest) limitedClient(transport *limitTransport) *resty.Client {
+	return resty.New().
+		// enforce the concurrency limit within the transport
+		SetTransport(transport).
+		// set generic client options
+		SetDisableWarn(true).
+		SetHeader(`Content-Type`, `application/json`).
+		SetContentLength(true).
+		// follow redirects
+		SetRedirectPolicy(resty.FlexibleRedirectPolicy(5)).
+		// configure request retry
+		SetRetryCount(x.conf.Eye.RetryCount).
+		SetRetryWaitTime(time.Duration(x.conf.Eye.RetryMinWaitTime) * time.Millisecond).
+		SetRetryMaxWaitTime(time.Duration(x.conf.Eye.RetryMaxWaitTime) * time.Millisecond).
+		// every client is used for a single request, the http.Client
+		// timeout also covers reading the response body
+		SetTimeout(time.Duration(x.conf.Eye.RequestTimeout) * time.Millisecond)
+}
+
+// limitTransport is the http.RoundTripper used for outgoing requests.
+// It enforces a concurrency limit, also releasing it if the request
+// fails. resty skips OnAfterResponse hooks for failed requests, which
+// is why the limit can not be handled within the request hooks.
+type limitTransport struct {
+	limit *limit.Limit
+	base  *http.Transport
+}
+
+// newLimitTransport returns a limitTransport that enforces lim
+func newLimitTransport(lim *limit.Limit) *limitTransport {
+	return &limitTransport{
+		limit: lim,
+		base: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.limit.Start()
+	defer t.limit.Done()
+
+	return t.base.RoundTrip(req)
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
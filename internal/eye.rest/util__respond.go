@@ -115,8 +115,13 @@ func (x *Rest) respondV1(w *http.ResponseWriter, r *msg.Result) {
 		// v1 Deployment API uses: 204, 400,      410, 412, 422, 500
 		// v2 Deployment API uses:      400, 403, 410,      422, 500, 501, 502, 504
 		// only failed requests return in SectionDeployment before being
-		// mapped to SectionConfiguration
+		// mapped to SectionConfiguration, except for repeated deliveries
+		// of already processed deployments
 		switch r.Code {
+		case msg.ResultOK:
+			// v1 API uses 204/NoContent
+			sendV1Result(w, msg.ResultNoContent, ``, nil)
+
 		case msg.ResultForbidden, msg.ResultNotImplemented:
 			// v1 API has no 403/Forbidden or 501/NotImplemented
 			sendV1Result(w, msg.ResultServerError, r.Error.Error(), nil)
@@ -144,6 +149,9 @@ func (x *Rest) respondV1(w *http.ResponseWriter, r *msg.Result) {
 	if r.Flags.SendDeploymentFeedback {
 		go x.somaStatusUpdate(r)
 	}
+
+	// changes made via the v1 API are emitted as events as well
+	go x.webhookDispatch(r)
 }
 
 // respondV2 is the output function emitting API version 2 results
@@ -154,12 +162,41 @@ func (x *Rest) respondV2(w *http.ResponseWriter, r *msg.Result) {
 		protoRes v2.Result
 	)
 
+	protoRes = x.exportV2(r)
+
+	// results of batched requests are exported and processed per
+	// contained request
+	if len(r.Batch) > 0 {
+		results := make([]v2.Result, len(r.Batch))
+		for i := range r.Batch {
+			results[i] = x.exportV2(&r.Batch[i])
+			x.postProcess(&r.Batch[i])
+		}
+		protoRes.Results = &results
+	}
+
+	x.postProcess(r)
+
+	if bjson, err = json.Marshal(&protoRes); err != nil {
+		hardInternalError(w)
+		return
+	}
+
+	sendJSONReply(w, &bjson)
+	return
+}
+
+// exportV2 converts the internal result r into an API version 2
+// protocol result. Flags of failed results are reset on r
+func (x *Rest) exportV2(r *msg.Result) v2.Result {
+	var protoRes v2.Result
+
 	// create external protocol result
 	switch r.Section {
 	case msg.SectionConfiguration:
 		protoRes = v2.NewConfigurationResult()
 	case msg.SectionDeployment:
-		protoRes = v2.NewConfigurationResult()
+		protoRes = v2.NewDeploymentResult()
 	case msg.SectionLookup:
 		switch r.Action {
 		case msg.ActionConfiguration, msg.ActionActivation, msg.ActionPending:
@@ -169,10 +206,16 @@ func (x *Rest) respondV2(w *http.ResponseWriter, r *msg.Result) {
 		}
 	case msg.SectionRegistration:
 		protoRes = v2.NewRegistrationResult()
-
-		// update cache registry
-		x.eyewallCacheUnregister(r)
-		x.eyewallCacheRegister(r)
+	case msg.SectionFeedback:
+		protoRes = v2.NewFeedbackResult()
+	case msg.SectionSubscription:
+		protoRes = v2.NewSubscriptionResult()
+	case msg.SectionChange:
+		protoRes = v2.NewChangeResult()
+	case msg.SectionReconciliation:
+		protoRes = v2.NewReconciliationResult()
+	case msg.SectionSync:
+		protoRes = v2.NewSyncResult()
 	}
 	// record what was performed
 	protoRes.Section = r.Section
@@ -189,6 +232,7 @@ func (x *Rest) respondV2(w *http.ResponseWriter, r *msg.Result) {
 		*protoRes.Configurations = append(*protoRes.Configurations, r.Configuration...)
 	case msg.SectionDeployment:
 		*protoRes.Configurations = append(*protoRes.Configurations, r.Configuration...)
+		*protoRes.Deployments = append(*protoRes.Deployments, r.Deployment...)
 	case msg.SectionLookup:
 		switch r.Action {
 		case msg.ActionConfiguration, msg.ActionActivation, msg.ActionPending:
@@ -198,6 +242,17 @@ func (x *Rest) respondV2(w *http.ResponseWriter, r *msg.Result) {
 		}
 	case msg.SectionRegistration:
 		*protoRes.Registrations = append(*protoRes.Registrations, r.Registration...)
+	case msg.SectionFeedback:
+		*protoRes.Feedbacks = append(*protoRes.Feedbacks, r.Feedback...)
+	case msg.SectionSubscription:
+		*protoRes.Subscriptions = append(*protoRes.Subscriptions, r.Subscription...)
+		*protoRes.Deliveries = append(*protoRes.Deliveries, r.Delivery...)
+	case msg.SectionChange:
+		*protoRes.Changes = append(*protoRes.Changes, r.Change...)
+	case msg.SectionReconciliation:
+		*protoRes.Reconciliations = append(*protoRes.Reconciliations, r.Reconciliation...)
+	case msg.SectionSync:
+		*protoRes.Syncs = append(*protoRes.Syncs, r.Sync...)
 	}
 
 	// trigger omitempty JSON encoding conditions if applicable
@@ -207,6 +262,27 @@ func (x *Rest) respondV2(w *http.ResponseWriter, r *msg.Result) {
 	if protoRes.Registrations != nil && len(*protoRes.Registrations) == 0 {
 		*protoRes.Registrations = nil
 	}
+	if protoRes.Feedbacks != nil && len(*protoRes.Feedbacks) == 0 {
+		*protoRes.Feedbacks = nil
+	}
+	if protoRes.Subscriptions != nil && len(*protoRes.Subscriptions) == 0 {
+		*protoRes.Subscriptions = nil
+	}
+	if protoRes.Deliveries != nil && len(*protoRes.Deliveries) == 0 {
+		*protoRes.Deliveries = nil
+	}
+	if protoRes.Changes != nil && len(*protoRes.Changes) == 0 {
+		*protoRes.Changes = nil
+	}
+	if protoRes.Deployments != nil && len(*protoRes.Deployments) == 0 {
+		*protoRes.Deployments = nil
+	}
+	if protoRes.Reconciliations != nil && len(*protoRes.Reconciliations) == 0 {
+		*protoRes.Reconciliations = nil
+	}
+	if protoRes.Syncs != nil && len(*protoRes.Syncs) == 0 {
+		*protoRes.Syncs = nil
+	}
 
 	// set protocol result status
 	protoRes.SetStatus(r.Code)
@@ -216,11 +292,47 @@ func (x *Rest) respondV2(w *http.ResponseWriter, r *msg.Result) {
 	// no cache invalidation for failed requests
 	// no alarm clearing for failed requests
 	case r.Code >= 400:
-		*protoRes.Configurations = nil
-		*protoRes.Registrations = nil
+		if protoRes.Configurations != nil {
+			*protoRes.Configurations = nil
+		}
+		if protoRes.Registrations != nil {
+			*protoRes.Registrations = nil
+		}
+		if protoRes.Feedbacks != nil {
+			*protoRes.Feedbacks = nil
+		}
+		if protoRes.Subscriptions != nil {
+			*protoRes.Subscriptions = nil
+		}
+		if protoRes.Deliveries != nil {
+			*protoRes.Deliveries = nil
+		}
+		if protoRes.Changes != nil {
+			*protoRes.Changes = nil
+		}
+		if protoRes.Deployments != nil {
+			*protoRes.Deployments = nil
+		}
+		if protoRes.Reconciliations != nil {
+			*protoRes.Reconciliations = nil
+		}
+		if protoRes.Syncs != nil {
+			*protoRes.Syncs = nil
+		}
 		r.Flags.CacheInvalidation = false
 		r.Flags.AlarmClearing = false
 	}
+	return protoRes
+}
+
+// postProcess performs the side effects of the result r after it was
+// exported via exportV2
+func (x *Rest) postProcess(r *msg.Result) {
+	// update cache registry
+	if r.Section == msg.SectionRegistration {
+		x.eyewallCacheUnregister(r)
+		x.eyewallCacheRegister(r)
+	}
 
 	// send deployment feedback to SOMA
 	if r.Flags.SendDeploymentFeedback {
@@ -235,13 +347,8 @@ func (x *Rest) respondV2(w *http.ResponseWriter, r *msg.Result) {
 		go x.alarmSend(r)
 	}
 
-	if bjson, err = json.Marshal(&protoRes); err != nil {
-		hardInternalError(w)
-		return
-	}
-
-	sendJSONReply(w, &bjson)
-	return
+	// notify subscribed webhooks
+	go x.webhookDispatch(r)
 }
 
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,295 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package rest // import "github.com/solnx/eye/internal/eye.rest"
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// webhookRetryInterval is the interval at which outstanding webhook
+// event deliveries are checked for redelivery
+const webhookRetryInterval = 10 * time.Second
+
+// webhookEventTypePrefix is the CloudEvents type prefix of all events
+// emitted by eye
+const webhookEventTypePrefix = `io.solnx.eye`
+
+// webhookSignatureHeader carries the HMAC-SHA256 signature of the
+// request body, keyed with the subscription secret
+const webhookSignatureHeader = `X-Eye-Signature`
+
+// webhookEvent is a change event along with the attributes that are
+// used to match it against subscription filters
+type webhookEvent struct {
+	filter v2.SubscriptionFilter
+	event  v2.Event
+}
+
+// webhookValidAction returns true if events of action are emitted for
+// section. An empty action subscribes to all actions of section
+func webhookValidAction(section, action string) bool {
+	switch section {
+	case msg.SectionConfiguration:
+		switch action {
+		case ``, msg.ActionAdd, msg.ActionUpdate, msg.ActionRemove,
+			msg.ActionActivate:
+			return true
+		}
+	case msg.SectionRegistration:
+		switch action {
+		case ``, msg.ActionAdd, msg.ActionUpdate, msg.ActionRemove:
+			return true
+		}
+	}
+	return false
+}
+
+// webhookDispatch records the change events of r for all matching
+// subscriptions and attempts their delivery
+func (x *Rest) webhookDispatch(r *msg.Result) {
+	if r.Code >= 400 || !webhookValidAction(r.Section, r.Action) {
+		return
+	}
+
+	events, err := webhookEvents(r)
+	if err != nil {
+		log.Println(`RequestID`, r.ID.String(), `Error`, err.Error())
+		return
+	}
+
+	queued := 0
+	for _, ev := range events {
+		request := msg.NewInternal()
+		request.Section = msg.SectionSubscription
+		request.Action = msg.ActionSearch
+		request.Search.Subscription.Filter = ev.filter
+
+		x.handlerMap.Get(`subscription_r`).Intake() <- request
+		result := <-request.Reply
+		if result.Error != nil {
+			log.Println(`RequestID`, r.ID.String(), `EventID`, ev.event.ID,
+				`Error`, result.Error.Error())
+			continue
+		}
+
+		payload, err := json.Marshal(&ev.event)
+		if err != nil {
+			log.Println(`RequestID`, r.ID.String(), `EventID`, ev.event.ID,
+				`Error`, err.Error())
+			continue
+		}
+
+		for _, sub := range result.Subscription {
+			dlv := v2.Delivery{
+				SubscriptionID: sub.ID,
+				EventID:        ev.event.ID,
+				EventType:      ev.event.Type,
+				Payload:        payload,
+			}
+			res := x.webhookDeliveryUpdate(msg.ActionNotification, dlv)
+			if res.Error != nil || len(res.Delivery) == 0 {
+				log.Println(`RequestID`, r.ID.String(), `SubscriptionID`,
+					sub.ID, `Error`, `failed to persist delivery`, res.Error)
+				continue
+			}
+			queued++
+		}
+	}
+
+	// the match result does not contain the subscription secrets,
+	// the first delivery attempt is performed by the delivery worker
+	if queued > 0 {
+		x.webhookWakeup()
+	}
+}
+
+// webhookWakeup triggers a delivery run of x.webhookRetry. Wakeups
+// that arrive while a run is already pending are merged into it
+func (x *Rest) webhookWakeup() {
+	select {
+	case x.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// webhookEvents returns the change events contained in r
+func webhookEvents(r *msg.Result) ([]webhookEvent, error) {
+	events := []webhookEvent{}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	newEvent := func(subject string, data interface{}) (v2.Event, error) {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return v2.Event{}, err
+		}
+		return v2.Event{
+			SpecVersion: v2.CloudEventsSpecVersion,
+			Type: fmt.Sprintf("%s.%s.%s", webhookEventTypePrefix,
+				r.Section, r.Action),
+			Source:          fmt.Sprintf("/api/v2/%s", r.Section),
+			ID:              uuid.Must(uuid.NewV4()).String(),
+			Time:            now,
+			Subject:         subject,
+			DataContentType: `application/json`,
+			Data:            raw,
+		}, nil
+	}
+
+	switch r.Section {
+	case msg.SectionConfiguration:
+		for i := range r.Configuration {
+			ev, err := newEvent(r.Configuration[i].ID, r.Configuration[i])
+			if err != nil {
+				return nil, err
+			}
+			filter := v2.SubscriptionFilter{
+				Section: r.Section,
+				Action:  r.Action,
+				HostID:  r.Configuration[i].HostID,
+			}
+			if len(r.Configuration[i].Data) > 0 {
+				filter.Team = r.Configuration[i].Data[0].Team
+			}
+			events = append(events, webhookEvent{filter: filter, event: ev})
+		}
+	case msg.SectionRegistration:
+		for i := range r.Registration {
+			ev, err := newEvent(r.Registration[i].ID, r.Registration[i])
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, webhookEvent{
+				filter: v2.SubscriptionFilter{
+					Section: r.Section,
+					Action:  r.Action,
+				},
+				event: ev,
+			})
+		}
+	}
+	return events, nil
+}
+
+// webhookRetry is the single delivery worker for webhook events. It
+// delivers all events that are not yet acknowledged and due for
+// another attempt, periodically and whenever it is woken up by
+// x.webhookDispatch. Since runs never overlap, every due event is
+// posted once per run
+func (x *Rest) webhookRetry() {
+	ticker := time.NewTicker(webhookRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-x.webhookWake:
+		}
+		if ShutdownInProgress {
+			return
+		}
+		x.webhookRedeliver()
+	}
+}
+
+// webhookRedeliver performs one delivery run for all due webhook
+// events. It must only be called by x.webhookRetry. The due events
+// are claimed, so that other eye instances on the same database do not
+// deliver them concurrently
+func (x *Rest) webhookRedeliver() {
+	request := msg.NewInternal()
+	request.Section = msg.SectionSubscription
+	request.Action = msg.ActionPending
+
+	x.handlerMap.Get(`subscription_w`).Intake() <- request
+	result := <-request.Reply
+	if result.Error != nil {
+		log.Println(`Webhook redelivery`, `Error`, result.Error.Error())
+		return
+	}
+
+	// concurrency of the deliveries is capped by the webhook
+	// transport
+	wg := sync.WaitGroup{}
+	for i := range result.Delivery {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			x.webhookAttempt(&result.Delivery[i])
+		}(i)
+	}
+	wg.Wait()
+}
+
+// webhookAttempt delivers an event to its subscription and records the
+// outcome of the delivery attempt
+func (x *Rest) webhookAttempt(dlv *v2.Delivery) {
+	action := msg.ActionAcknowledge
+	if err := x.webhookDeliver(dlv); err != nil {
+		action = msg.ActionReschedule
+		dlv.LastError = err.Error()
+	}
+
+	res := x.webhookDeliveryUpdate(action, *dlv)
+	if res.Error != nil {
+		log.Println(`DeliveryID`, dlv.ID, `Error`, res.Error.Error())
+	}
+}
+
+// webhookDeliveryUpdate sends dlv to the subscription_w handler with
+// action and returns the result
+func (x *Rest) webhookDeliveryUpdate(action string, dlv v2.Delivery) msg.Result {
+	request := msg.NewInternal()
+	request.Section = msg.SectionSubscription
+	request.Action = action
+	request.Delivery = dlv
+
+	x.handlerMap.Get(`subscription_w`).Intake() <- request
+	return <-request.Reply
+}
+
+// webhookDeliver posts the signed event of dlv to the webhook URL
+func (x *Rest) webhookDeliver(dlv *v2.Delivery) error {
+	mac := hmac.New(sha256.New, []byte(dlv.Secret))
+	mac.Write(dlv.Payload)
+	signature := `sha256=` + hex.EncodeToString(mac.Sum(nil))
+
+	// subscriber URLs are arbitrary, deliveries use a separate
+	// concurrency limit so that unresponsive subscribers can not
+	// block the requests to SOMA
+	res, err := x.limitedClient(x.webhookTransport).R().
+		SetHeader(`Content-Type`, `application/cloudevents+json`).
+		SetHeader(webhookSignatureHeader, signature).
+		SetHeader(`X-Eye-Delivery`, dlv.ID).
+		SetBody([]byte(dlv.Payload)).
+		Post(dlv.URL)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case res.StatusCode() >= http.StatusOK && res.StatusCode() < http.StatusMultipleChoices:
+		return nil
+	default:
+		return fmt.Errorf("Webhook responded with: %s", res.Status())
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
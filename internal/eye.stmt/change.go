@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package stmt // import "github.com/solnx/eye/internal/eye.stmt"
+
+// ChangeStatements contains the SQL statements related to the feed of
+// configuration changes
+const (
+	ChangeStatements = ``
+
+	// ChangeAdd acquires a transaction level advisory lock before
+	// assigning the changeID. All transactions recording changes are
+	// thereby serialized from this point until they commit, which
+	// guarantees that a reader that has seen changeID N will never
+	// later observe a new change with a changeID smaller than N. The
+	// changeID also versions the write-through cache updates of the
+	// change
+	ChangeAdd = `
+WITH serialize AS (
+     SELECT pg_advisory_xact_lock(hashtext('eye.changes'))
+)
+INSERT INTO eye.changes (
+            section,
+            action,
+            configurationID,
+            lookupID)
+SELECT $1::varchar,
+       $2::varchar,
+       ec.configurationID,
+       ec.lookupID
+FROM   eye.configurations AS ec
+CROSS  JOIN serialize
+WHERE  ec.configurationID = $3::uuid
+RETURNING changeID;`
+
+	ChangeSince = `
+SELECT changeID,
+       section,
+       action,
+       configurationID,
+       lookupID,
+       changedAt
+FROM   eye.changes
+WHERE  changeID > $1::bigint
+ORDER  BY changeID
+LIMIT  $2::integer;`
+)
+
+func init() {
+	m[ChangeAdd] = `ChangeAdd`
+	m[ChangeSince] = `ChangeSince`
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
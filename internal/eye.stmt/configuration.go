@@ -18,6 +18,16 @@ SELECT configurationID
 FROM   eye.configurations_data
 WHERE  validity @> NOW()::timestamptz;`
 
+	CfgListSource = `
+SELECT d.configurationID,
+       COALESCE(p.revision, '')
+FROM   eye.configurations_data AS d
+JOIN   eye.provisions AS p
+  ON   d.dataID = p.dataID
+WHERE  d.validity @> NOW()::timestamptz
+  AND  p.provision_period @> NOW()::timestamptz
+  AND  p.source = $1::varchar;`
+
 	CfgExists = `
 SELECT configurationID
 FROM   eye.configurations
@@ -113,6 +123,7 @@ func init() {
 	m[CfgDataUpdateValidity] = `CfgDataUpdateValidity`
 	m[CfgExists] = `CfgExists`
 	m[CfgList] = `CfgList`
+	m[CfgListSource] = `CfgListSource`
 	m[CfgSelectValidForUpdate] = `CfgSelectValidForUpdate`
 	m[CfgSelectValid] = `CfgSelectValid`
 	m[CfgShow] = `CfgShow`
@@ -9,4 +9,98 @@
 
 package stmt // import "github.com/solnx/eye/internal/eye.stmt"
 
+// DeploymentStatements contains the SQL statements related to the
+// tracking of processed SOMA deployments
+const (
+	DeploymentStatements = ``
+
+	// DeploymentClaim records a received deployment as processing. It
+	// only affects a row if the deployment version is new, its previous
+	// processing failed or a previous processing attempt is stuck
+	DeploymentClaim = `
+INSERT INTO eye.deployments (
+            deploymentID,
+            version,
+            task,
+            checkInstanceID,
+            requestID,
+            outcome)
+SELECT $1::uuid,
+       $2::numeric,
+       $3::varchar,
+       $4::uuid,
+       $5::uuid,
+       'processing'::varchar
+ON CONFLICT ( deploymentID, version, task ) DO UPDATE
+SET    checkInstanceID = EXCLUDED.checkInstanceID,
+       requestID = EXCLUDED.requestID,
+       outcome = 'processing'::varchar,
+       statusCode = NULL,
+       receivedAt = NOW()::timestamptz,
+       processedAt = NULL
+WHERE  eye.deployments.outcome = 'failed'::varchar
+   OR  ( eye.deployments.outcome = 'processing'::varchar
+     AND eye.deployments.receivedAt < NOW()::timestamptz - $6::numeric * interval '1 second' );`
+
+	DeploymentFinish = `
+UPDATE eye.deployments
+SET    outcome = $4::varchar,
+       statusCode = $5::numeric,
+       processedAt = NOW()::timestamptz
+WHERE  deploymentID = $1::uuid
+  AND  version = $2::numeric
+  AND  task = $3::varchar
+  AND  requestID = $6::uuid;`
+
+	DeploymentShowTask = `
+SELECT deploymentID,
+       version,
+       task,
+       checkInstanceID,
+       requestID,
+       outcome,
+       statusCode,
+       receivedAt,
+       processedAt
+FROM   eye.deployments
+WHERE  deploymentID = $1::uuid
+  AND  version = $2::numeric
+  AND  task = $3::varchar;`
+
+	DeploymentShow = `
+SELECT deploymentID,
+       version,
+       task,
+       checkInstanceID,
+       requestID,
+       outcome,
+       statusCode,
+       receivedAt,
+       processedAt
+FROM   eye.deployments
+WHERE  deploymentID = $1::uuid
+ORDER  BY receivedAt;`
+
+	DeploymentList = `
+SELECT deploymentID,
+       version,
+       task,
+       checkInstanceID,
+       requestID,
+       outcome,
+       statusCode,
+       receivedAt,
+       processedAt
+FROM   eye.deployments
+ORDER  BY receivedAt DESC;`
+)
+
+func init() {
+	m[DeploymentClaim] = `DeploymentClaim`
+	m[DeploymentFinish] = `DeploymentFinish`
+	m[DeploymentList] = `DeploymentList`
+	m[DeploymentShowTask] = `DeploymentShowTask`
+	m[DeploymentShow] = `DeploymentShow`
+}
+
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package stmt // import "github.com/solnx/eye/internal/eye.stmt"
+
+// FeedbackStatements contains the SQL statements related to the
+// delivery of deployment feedback to SOMA
+const (
+	FeedbackStatements = ``
+
+	FeedbackAdd = `
+INSERT INTO eye.feedback (
+            feedbackID,
+            requestID,
+            configurationID,
+            task,
+            outcome,
+            feedbackURL,
+            status,
+            nextAttemptAt
+)
+SELECT $1::uuid,
+       $2::uuid,
+       $3::uuid,
+       $4::varchar,
+       $5::varchar,
+       $6::text,
+       'pending'::varchar,
+       NOW()::timestamptz + $7::numeric * interval '1 second'
+WHERE  NOT EXISTS (
+       SELECT feedbackID
+       FROM   eye.feedback
+       WHERE  feedbackID = $1::uuid);`
+
+	FeedbackAcknowledge = `
+UPDATE eye.feedback
+SET    status = 'acknowledged'::varchar,
+       attempts = attempts + 1,
+       lastAttemptAt = NOW()::timestamptz,
+       acknowledgedAt = NOW()::timestamptz
+WHERE  feedbackID = $1::uuid;`
+
+	FeedbackReschedule = `
+UPDATE eye.feedback
+SET    status = 'failed'::varchar,
+       attempts = attempts + 1,
+       lastError = $2::text,
+       lastAttemptAt = NOW()::timestamptz,
+       nextAttemptAt = NOW()::timestamptz + LEAST(
+           $3::numeric * power(2, attempts),
+           $4::numeric
+       ) * interval '1 second'
+WHERE  feedbackID = $1::uuid
+  AND  status <> 'acknowledged'::varchar;`
+
+	FeedbackShow = `
+SELECT feedbackID,
+       requestID,
+       configurationID,
+       task,
+       outcome,
+       feedbackURL,
+       status,
+       attempts,
+       lastError,
+       createdAt,
+       lastAttemptAt,
+       nextAttemptAt,
+       acknowledgedAt
+FROM   eye.feedback
+WHERE  feedbackID = $1::uuid;`
+
+	FeedbackSearch = `
+SELECT feedbackID,
+       requestID,
+       configurationID,
+       task,
+       outcome,
+       feedbackURL,
+       status,
+       attempts,
+       lastError,
+       createdAt,
+       lastAttemptAt,
+       nextAttemptAt,
+       acknowledgedAt
+FROM   eye.feedback
+WHERE  (status = $1::varchar OR $1::varchar IS NULL)
+ORDER  BY createdAt;`
+
+	// FeedbackClaim claims the feedbacks that are due by moving their
+	// next attempt past the claim lease. Rows that are claimed
+	// concurrently by another eye instance are skipped
+	FeedbackClaim = `
+UPDATE eye.feedback
+SET    nextAttemptAt = NOW()::timestamptz + $2::numeric * interval '1 second'
+WHERE  feedbackID IN (
+       SELECT feedbackID
+       FROM   eye.feedback
+       WHERE  status <> 'acknowledged'::varchar
+         AND  nextAttemptAt <= NOW()::timestamptz
+       ORDER  BY nextAttemptAt
+       LIMIT  $1::integer
+       FOR    UPDATE SKIP LOCKED)
+RETURNING feedbackID,
+          requestID,
+          configurationID,
+          task,
+          outcome,
+          feedbackURL,
+          status,
+          attempts,
+          lastError,
+          createdAt,
+          lastAttemptAt,
+          nextAttemptAt,
+          acknowledgedAt;`
+)
+
+func init() {
+	m[FeedbackAcknowledge] = `FeedbackAcknowledge`
+	m[FeedbackAdd] = `FeedbackAdd`
+	m[FeedbackClaim] = `FeedbackClaim`
+	m[FeedbackReschedule] = `FeedbackReschedule`
+	m[FeedbackSearch] = `FeedbackSearch`
+	m[FeedbackShow] = `FeedbackShow`
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -18,12 +18,18 @@ INSERT INTO eye.provisions (
             dataID,
             configurationID,
             provision_period,
-            tasks
+            tasks,
+            source,
+            revision,
+            commitID
 )
 SELECT $1::uuid,
        $2::uuid,
        tstzrange($3::timestamptz, 'infinity', '[]'),
-       $4::varchar[];
+       $4::varchar[],
+       $5::varchar,
+       NULLIF($6::varchar, ''),
+       NULLIF($7::varchar, '')
 WHERE  NOT EXISTS (
        SELECT dataID
        FROM   eye.provisions
@@ -47,6 +53,13 @@ SELECT lower(provision_period),
        upper(provision_period),
        tasks
 FROM   eye.provisions
+WHERE  dataID = $1::uuid;`
+
+	ProvSource = `
+SELECT source,
+       COALESCE(revision, ''),
+       COALESCE(commitID, '')
+FROM   eye.provisions
 WHERE  dataID = $1::uuid;`
 )
 
@@ -54,6 +67,7 @@ func init() {
 	m[ProvAdd] = `ProvAdd`
 	m[ProvFinalize] = `ProvFinalize`
 	m[ProvForDataID] = `ProvForDataID`
+	m[ProvSource] = `ProvSource`
 }
 
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -21,7 +21,10 @@ CREATE TABLE IF NOT EXISTS eye.registry (
   port                    numeric(5,0)    NOT NULL CONSTRAINT valid_port CHECK ( port > 0 AND port < 65536 ),
   database                numeric(5,0)    NOT NULL CONSTRAINT valid_db CHECK ( database >= 0 ),
   registeredAt            timestamptz(3)  NOT NULL DEFAULT NOW(),
-  CONSTRAINT registeredAt_utc CHECK( EXTRACT( TIMEZONE FROM registeredAt ) = '0' )
+  leaseTTL                integer         NOT NULL DEFAULT 0 CONSTRAINT valid_lease CHECK ( leaseTTL >= 0 ),
+  renewedAt               timestamptz(3)  NOT NULL DEFAULT NOW(),
+  CONSTRAINT registeredAt_utc CHECK( EXTRACT( TIMEZONE FROM registeredAt ) = '0' ),
+  CONSTRAINT renewedAt_utc CHECK( EXTRACT( TIMEZONE FROM renewedAt ) = '0' )
 );`
 
 	RegistryAdd = `
@@ -30,12 +33,14 @@ INSERT INTO eye.registry (
             application,
             address,
             port,
-            database)
+            database,
+            leaseTTL)
 SELECT $1::uuid,
        $2::varchar,
        $3::inet,
        $4::numeric,
-       $5::numeric;`
+       $5::numeric,
+       $6::integer;`
 
 	RegistryDel = `
 DELETE FROM eye.registry
@@ -47,7 +52,9 @@ SELECT registrationID,
        address,
        port,
        database,
-       registeredAt
+       registeredAt,
+       leaseTTL,
+       renewedAt
 FROM   eye.registry
 WHERE  (application = $1::varchar OR $1::varchar IS NULL)
   AND  (address = $2::inet OR $2::inet IS NULL)
@@ -64,7 +71,9 @@ SELECT registrationID,
        address,
        port,
        database,
-       registeredAt
+       registeredAt,
+       leaseTTL,
+       renewedAt
 FROM   eye.registry
 WHERE  registrationID = $1::uuid;`
 
@@ -74,15 +83,30 @@ SET    application = $2::varchar,
        address = $3::inet,
        port = $4::numeric,
        database = $5::numeric,
-       registeredAt = $6::timestamptz
+       registeredAt = $6::timestamptz,
+       leaseTTL = $7::integer,
+       renewedAt = $6::timestamptz
 WHERE  registrationID = $1::uuid;`
+
+	RegistryRenew = `
+UPDATE eye.registry
+SET    renewedAt = $2::timestamptz
+WHERE  registrationID = $1::uuid;`
+
+	RegistryExpire = `
+DELETE FROM eye.registry
+WHERE  leaseTTL > 0
+  AND  renewedAt + leaseTTL * interval '1 second' < NOW()
+RETURNING registrationID;`
 )
 
 func init() {
 	m[RegistryAdd] = `RegistryAdd`
 	m[RegistryCreateTable] = `RegistryCreateTable`
 	m[RegistryDel] = `RegistryDel`
+	m[RegistryExpire] = `RegistryExpire`
 	m[RegistryList] = `RegistryList`
+	m[RegistryRenew] = `RegistryRenew`
 	m[RegistrySearch] = `RegistrySearch`
 	m[RegistryShow] = `RegistryShow`
 	m[RegistryUpdate] = `RegistryUpdate`
@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package stmt // import "github.com/solnx/eye/internal/eye.stmt"
+
+// SubscriptionStatements contains the SQL statements related to
+// webhook subscriptions and the delivery of events to them
+const (
+	SubscriptionStatements = ``
+
+	SubscriptionAdd = `
+INSERT INTO eye.subscription (
+            subscriptionID,
+            url,
+            secret,
+            section,
+            action,
+            team,
+            hostID
+)
+SELECT $1::uuid,
+       $2::text,
+       $3::varchar,
+       $4::varchar,
+       $5::varchar,
+       $6::varchar,
+       $7::numeric;`
+
+	SubscriptionRemove = `
+DELETE FROM eye.subscription
+WHERE  subscriptionID = $1::uuid;`
+
+	SubscriptionList = `
+SELECT subscriptionID,
+       url,
+       section,
+       action,
+       team,
+       hostID,
+       createdAt
+FROM   eye.subscription
+ORDER  BY createdAt;`
+
+	SubscriptionShow = `
+SELECT subscriptionID,
+       url,
+       section,
+       action,
+       team,
+       hostID,
+       createdAt
+FROM   eye.subscription
+WHERE  subscriptionID = $1::uuid;`
+
+	SubscriptionMatch = `
+SELECT subscriptionID,
+       url,
+       section,
+       action,
+       team,
+       hostID,
+       createdAt
+FROM   eye.subscription
+WHERE  section = $1::varchar
+  AND  (action IS NULL OR action = $2::varchar)
+  AND  (team IS NULL OR team = $3::varchar)
+  AND  (hostID IS NULL OR hostID = $4::numeric);`
+
+	SubscriptionDeliveryAdd = `
+INSERT INTO eye.subscription_delivery (
+            deliveryID,
+            subscriptionID,
+            eventID,
+            eventType,
+            payload,
+            status
+)
+SELECT $1::uuid,
+       $2::uuid,
+       $3::uuid,
+       $4::varchar,
+       $5::jsonb,
+       'pending'::varchar
+WHERE  NOT EXISTS (
+       SELECT deliveryID
+       FROM   eye.subscription_delivery
+       WHERE  subscriptionID = $2::uuid
+         AND  eventID = $3::uuid);`
+
+	SubscriptionDeliveryAcknowledge = `
+UPDATE eye.subscription_delivery
+SET    status = 'acknowledged'::varchar,
+       attempts = attempts + 1,
+       lastAttemptAt = NOW()::timestamptz,
+       deliveredAt = NOW()::timestamptz
+WHERE  deliveryID = $1::uuid;`
+
+	// SubscriptionDeliveryReschedule records a failed delivery attempt.
+	// Deliveries that reached the maximum number of attempts are
+	// failed permanently and not attempted again
+	SubscriptionDeliveryReschedule = `
+UPDATE eye.subscription_delivery
+SET    status = CASE WHEN attempts + 1 >= $5::integer
+                     THEN 'failed'::varchar
+                     ELSE 'pending'::varchar
+                END,
+       attempts = attempts + 1,
+       lastError = $2::text,
+       lastAttemptAt = NOW()::timestamptz,
+       nextAttemptAt = NOW()::timestamptz + LEAST(
+           $3::numeric * power(2, attempts),
+           $4::numeric
+       ) * interval '1 second'
+WHERE  deliveryID = $1::uuid
+  AND  status = 'pending'::varchar
+RETURNING status;`
+
+	SubscriptionDeliveryHistory = `
+SELECT esd.deliveryID,
+       esd.subscriptionID,
+       esd.eventID,
+       esd.eventType,
+       esd.payload,
+       es.url,
+       es.secret,
+       esd.status,
+       esd.attempts,
+       esd.lastError,
+       esd.createdAt,
+       esd.lastAttemptAt,
+       esd.nextAttemptAt,
+       esd.deliveredAt
+FROM   eye.subscription_delivery AS esd
+JOIN   eye.subscription AS es
+  ON   esd.subscriptionID = es.subscriptionID
+WHERE  esd.subscriptionID = $1::uuid
+ORDER  BY esd.createdAt;`
+
+	// SubscriptionDeliveryClaim claims the pending deliveries that are
+	// due by moving their next attempt past the claim lease. Rows that
+	// are claimed concurrently by another eye instance are skipped
+	SubscriptionDeliveryClaim = `
+UPDATE eye.subscription_delivery AS esd
+SET    nextAttemptAt = NOW()::timestamptz + $2::numeric * interval '1 second'
+FROM   eye.subscription AS es
+WHERE  esd.subscriptionID = es.subscriptionID
+  AND  esd.deliveryID IN (
+       SELECT deliveryID
+       FROM   eye.subscription_delivery
+       WHERE  status = 'pending'::varchar
+         AND  nextAttemptAt <= NOW()::timestamptz
+       ORDER  BY nextAttemptAt
+       LIMIT  $1::integer
+       FOR    UPDATE SKIP LOCKED)
+RETURNING esd.deliveryID,
+          esd.subscriptionID,
+          esd.eventID,
+          esd.eventType,
+          esd.payload,
+          es.url,
+          es.secret,
+          esd.status,
+          esd.attempts,
+          esd.lastError,
+          esd.createdAt,
+          esd.lastAttemptAt,
+          esd.nextAttemptAt,
+          esd.deliveredAt;`
+)
+
+func init() {
+	m[SubscriptionAdd] = `SubscriptionAdd`
+	m[SubscriptionDeliveryAcknowledge] = `SubscriptionDeliveryAcknowledge`
+	m[SubscriptionDeliveryAdd] = `SubscriptionDeliveryAdd`
+	m[SubscriptionDeliveryClaim] = `SubscriptionDeliveryClaim`
+	m[SubscriptionDeliveryHistory] = `SubscriptionDeliveryHistory`
+	m[SubscriptionDeliveryReschedule] = `SubscriptionDeliveryReschedule`
+	m[SubscriptionList] = `SubscriptionList`
+	m[SubscriptionMatch] = `SubscriptionMatch`
+	m[SubscriptionRemove] = `SubscriptionRemove`
+	m[SubscriptionShow] = `SubscriptionShow`
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
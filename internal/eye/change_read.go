@@ -0,0 +1,99 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package eye // import "github.com/solnx/eye/internal/eye"
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// changeBatchSize limits how many changes are returned for a single
+// request. Clients resume the feed from the last returned change
+const changeBatchSize = 1000
+
+// ChangeRead handles read requests for the change feed
+type ChangeRead struct {
+	Input     chan msg.Request
+	Shutdown  chan struct{}
+	conn      *sql.DB
+	stmtSince *sql.Stmt
+	appLog    *logrus.Logger
+	reqLog    *logrus.Logger
+	errLog    *logrus.Logger
+}
+
+// newChangeRead return a new ChangeRead handler with input buffer of length
+func newChangeRead(length int) (r *ChangeRead) {
+	r = &ChangeRead{}
+	r.Input = make(chan msg.Request, length)
+	r.Shutdown = make(chan struct{})
+	return
+}
+
+// process is the request dispatcher called by Run
+func (r *ChangeRead) process(q *msg.Request) {
+	result := msg.FromRequest(q)
+
+	switch q.Action {
+	case msg.ActionList, msg.ActionStream:
+		r.since(q, &result)
+	default:
+		result.UnknownRequest(q)
+	}
+	q.Reply <- result
+}
+
+// since returns the changes after the cursor q.Search.Change.ID
+func (r *ChangeRead) since(q *msg.Request, mr *msg.Result) {
+	var (
+		err  error
+		rows *sql.Rows
+	)
+
+	if rows, err = r.stmtSince.Query(
+		q.Search.Change.ID,
+		changeBatchSize,
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	for rows.Next() {
+		var (
+			change    v2.Change
+			changedAt time.Time
+		)
+
+		if err = rows.Scan(
+			&change.ID,
+			&change.Section,
+			&change.Action,
+			&change.ConfigurationID,
+			&change.LookupID,
+			&changedAt,
+		); err != nil {
+			rows.Close()
+			mr.ServerError(err)
+			return
+		}
+		change.ChangedAt = changedAt.Format(RFC3339Milli)
+		mr.Change = append(mr.Change, change)
+	}
+	if err = rows.Err(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	mr.OK()
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -34,6 +34,8 @@ type ConfigurationRead struct {
 	stmtCfgHistory     *sql.Stmt
 	stmtProvInfo       *sql.Stmt
 	stmtCfgVersion     *sql.Stmt
+	stmtCfgListSource  *sql.Stmt
+	stmtProvSource     *sql.Stmt
 	appLog             *logrus.Logger
 	reqLog             *logrus.Logger
 	errLog             *logrus.Logger
@@ -66,8 +68,15 @@ func (r *ConfigurationRead) process(q *msg.Request) {
 	q.Reply <- result
 }
 
-// list returns all configurations by ID
+// list returns all configurations by ID. If a provision source is
+// searched, only configurations provisioned from that source are
+// returned together with their provisioned revision
 func (r *ConfigurationRead) list(q *msg.Request, mr *msg.Result) {
+	if q.Search.Provision.Source != `` {
+		r.listSource(q, mr)
+		return
+	}
+
 	var (
 		configurationID string
 		rows            *sql.Rows
@@ -96,11 +105,54 @@ func (r *ConfigurationRead) list(q *msg.Request, mr *msg.Result) {
 	mr.OK()
 }
 
+// listSource returns all configurations provisioned from the searched
+// source
+func (r *ConfigurationRead) listSource(q *msg.Request, mr *msg.Result) {
+	var (
+		configurationID, revision string
+		rows                      *sql.Rows
+		err                       error
+	)
+
+	if rows, err = r.stmtCfgListSource.Query(
+		q.Search.Provision.Source,
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(
+			&configurationID,
+			&revision,
+		); err != nil {
+			rows.Close()
+			mr.ServerError(err)
+			return
+		}
+		mr.Configuration = append(mr.Configuration, v2.Configuration{
+			ID: configurationID,
+			Data: []v2.Data{{
+				Info: v2.MetaInformation{
+					Source:   q.Search.Provision.Source,
+					Revision: revision,
+				},
+			}},
+		})
+	}
+	if err = rows.Err(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	mr.OK()
+}
+
 // show returns the current version of a specific configuration
 func (r *ConfigurationRead) show(q *msg.Request, mr *msg.Result) {
 	var (
 		err                                     error
 		dataID, confResult                      string
+		source, revision, commit                string
 		tasks                                   []string
 		configuration                           v2.Configuration
 		data                                    v2.Data
@@ -175,6 +227,17 @@ func (r *ConfigurationRead) show(q *msg.Request, mr *msg.Result) {
 		configuration.ActivatedAt = activatedAt.Format(RFC3339Milli)
 	}
 
+	// query the origin of the provisioned data
+	if err = tx.Stmt(r.stmtProvSource).QueryRow(
+		dataID,
+	).Scan(
+		&source,
+		&revision,
+		&commit,
+	); err != nil {
+		goto abort
+	}
+
 	// populate result metadata
 	data = configuration.Data[0]
 	data.Info = v2.MetaInformation{
@@ -183,6 +246,9 @@ func (r *ConfigurationRead) show(q *msg.Request, mr *msg.Result) {
 		ProvisionedAt:   v2.FormatProvision(provisionTS),
 		DeprovisionedAt: v2.FormatProvision(deprovisionTS),
 		Tasks:           tasks,
+		Source:          source,
+		Revision:        revision,
+		Commit:          commit,
 	}
 	configuration.Data = []v2.Data{data}
 	mr.Configuration = append(mr.Configuration, configuration)
@@ -358,6 +424,7 @@ func (r *ConfigurationRead) version(q *msg.Request, mr *msg.Result) {
 	var (
 		err                                     error
 		dataID, confResult                      string
+		source, revision, commit                string
 		tasks                                   []string
 		configuration                           v2.Configuration
 		data                                    v2.Data
@@ -441,6 +508,17 @@ func (r *ConfigurationRead) version(q *msg.Request, mr *msg.Result) {
 		configuration.ActivatedAt = activatedAt.Format(RFC3339Milli)
 	}
 
+	// query the origin of the provisioned data
+	if err = tx.Stmt(r.stmtProvSource).QueryRow(
+		dataID,
+	).Scan(
+		&source,
+		&revision,
+		&commit,
+	); err != nil {
+		goto abort
+	}
+
 	// populate result metadata
 	data = configuration.Data[0]
 	data.Info = v2.MetaInformation{
@@ -449,6 +527,9 @@ func (r *ConfigurationRead) version(q *msg.Request, mr *msg.Result) {
 		ProvisionedAt:   v2.FormatProvision(provisionTS),
 		DeprovisionedAt: v2.FormatProvision(deprovisionTS),
 		Tasks:           tasks,
+		Source:          source,
+		Revision:        revision,
+		Commit:          commit,
 	}
 	configuration.Data = []v2.Data{data}
 	mr.Configuration = append(mr.Configuration, configuration)
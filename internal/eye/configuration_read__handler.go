@@ -39,6 +39,8 @@ func (r *ConfigurationRead) Run() {
 		stmt.CfgDataHistory: r.stmtCfgHistory,
 		stmt.ProvForDataID:  r.stmtProvInfo,
 		stmt.CfgVersion:     r.stmtCfgVersion,
+		stmt.CfgListSource:  r.stmtCfgListSource,
+		stmt.ProvSource:     r.stmtProvSource,
 	} {
 		if prepStmt, err = r.conn.Prepare(statement); err != nil {
 			r.errLog.Fatal(`configuration_r`, err, stmt.Name(statement))
@@ -36,6 +36,7 @@ type ConfigurationWrite struct {
 	stmtActivationDel           *sql.Stmt
 	stmtCfgShow                 *sql.Stmt
 	stmtActivationSet           *sql.Stmt
+	stmtChangeAdd               *sql.Stmt
 	appLog                      *logrus.Logger
 	reqLog                      *logrus.Logger
 	errLog                      *logrus.Logger
@@ -100,7 +101,7 @@ func (w *ConfigurationWrite) add(q *msg.Request, mr *msg.Result) {
 		return
 	}
 
-	if tx, err = w.conn.Begin(); err != nil {
+	if tx, err = w.txBegin(q); err != nil {
 		mr.ServerError(err)
 		return
 	}
@@ -164,6 +165,7 @@ func (w *ConfigurationWrite) add(q *msg.Request, mr *msg.Result) {
 		q.Configuration.ID,
 		rolloutTS,
 		jsonb,
+		q.Provision,
 	); err != nil {
 		goto abort
 	} else if !ok {
@@ -183,7 +185,18 @@ func (w *ConfigurationWrite) add(q *msg.Request, mr *msg.Result) {
 		q.Configuration.ActivatedAt = activatedAt.Format(RFC3339Milli)
 	}
 
-	if err = tx.Commit(); err != nil {
+	// record the change in the change feed
+	if ok, err = w.txRecordChange(tx, mr,
+		msg.SectionConfiguration,
+		msg.ActionAdd,
+		q.Configuration.ID,
+	); err != nil {
+		goto abort
+	} else if !ok {
+		goto rollback
+	}
+
+	if err = w.txCommit(q, tx); err != nil {
 		mr.ServerError(err)
 		return
 	}
@@ -205,7 +218,7 @@ abort:
 	mr.ServerError(err)
 
 rollback:
-	tx.Rollback()
+	w.txRollback(q, tx)
 }
 
 // remove deletes a configuration from the database
@@ -241,7 +254,7 @@ func (w *ConfigurationWrite) remove(q *msg.Request, mr *msg.Result) {
 	}
 
 	// open transaction
-	if tx, err = w.conn.Begin(); err != nil {
+	if tx, err = w.txBegin(q); err != nil {
 		mr.ServerError(err)
 		return
 	}
@@ -314,10 +327,32 @@ func (w *ConfigurationWrite) remove(q *msg.Request, mr *msg.Result) {
 		if !mr.ExpectedRows(&res, 0, 1) {
 			goto rollback
 		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			if ok, err = w.txRecordChange(tx, mr,
+				msg.SectionActivation,
+				msg.ActionRemove,
+				q.Configuration.ID,
+			); err != nil {
+				goto abort
+			} else if !ok {
+				goto rollback
+			}
+		}
+	}
+
+	// record the change in the change feed
+	if ok, err = w.txRecordChange(tx, mr,
+		msg.SectionConfiguration,
+		msg.ActionRemove,
+		q.Configuration.ID,
+	); err != nil {
+		goto abort
+	} else if !ok {
+		goto rollback
 	}
 
 commitTx:
-	if err = tx.Commit(); err != nil {
+	if err = w.txCommit(q, tx); err != nil {
 		mr.ServerError(err)
 		return
 	}
@@ -328,7 +363,7 @@ abort:
 	mr.ServerError(err)
 
 rollback:
-	tx.Rollback()
+	w.txRollback(q, tx)
 }
 
 // update replaces a configuration's data section with a new version
@@ -346,7 +381,7 @@ func (w *ConfigurationWrite) update(q *msg.Request, mr *msg.Result) {
 
 	transactionTS = time.Now().UTC()
 
-	if tx, err = w.conn.Begin(); err != nil {
+	if tx, err = w.txBegin(q); err != nil {
 		mr.ServerError(err)
 		return
 	}
@@ -408,6 +443,18 @@ func (w *ConfigurationWrite) update(q *msg.Request, mr *msg.Result) {
 		q.Configuration.ID,
 		transactionTS,
 		jsonb,
+		q.Provision,
+	); err != nil {
+		goto abort
+	} else if !ok {
+		goto rollback
+	}
+
+	// record the change in the change feed
+	if ok, err = w.txRecordChange(tx, mr,
+		msg.SectionConfiguration,
+		msg.ActionUpdate,
+		q.Configuration.ID,
 	); err != nil {
 		goto abort
 	} else if !ok {
@@ -415,7 +462,7 @@ func (w *ConfigurationWrite) update(q *msg.Request, mr *msg.Result) {
 	}
 
 	// commit transaction
-	if err = tx.Commit(); err != nil {
+	if err = w.txCommit(q, tx); err != nil {
 		mr.ServerError(err)
 		return
 	}
@@ -443,24 +490,61 @@ abort:
 	mr.ServerError(err)
 
 rollback:
-	tx.Rollback()
+	w.txRollback(q, tx)
 	return
 }
 
 // activate records a configuration activation
 func (w *ConfigurationWrite) activate(q *msg.Request, mr *msg.Result) {
-	var err error
-	var res sql.Result
+	var (
+		err error
+		ok  bool
+		tx  *sql.Tx
+		res sql.Result
+		n   int64
+	)
 
-	if res, err = w.stmtActivationSet.Exec(
+	if tx, err = w.txBegin(q); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	if res, err = tx.Stmt(w.stmtActivationSet).Exec(
 		q.Configuration.ID,
 	); err != nil {
+		goto abort
+	}
+	if n, err = res.RowsAffected(); err != nil {
+		goto abort
+	}
+
+	// 0: configuration was already activated, which is not a change
+	if n == 1 {
+		if ok, err = w.txRecordChange(tx, mr,
+			msg.SectionActivation,
+			msg.ActionAdd,
+			q.Configuration.ID,
+		); err != nil {
+			goto abort
+		} else if !ok {
+			goto rollback
+		}
+	}
+
+	if err = w.txCommit(q, tx); err != nil {
 		mr.ServerError(err)
 		return
 	}
-	if mr.RowCnt(res.RowsAffected()) {
+	if mr.RowCnt(n, nil) {
 		mr.Configuration = append(mr.Configuration, q.Configuration)
 	}
+	return
+
+abort:
+	mr.ServerError(err)
+
+rollback:
+	w.txRollback(q, tx)
 }
 
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -43,6 +43,7 @@ func (w *ConfigurationWrite) Run() {
 		stmt.ActivationDel:           w.stmtActivationDel,
 		stmt.CfgShow:                 w.stmtCfgShow,
 		stmt.ActivationSet:           w.stmtActivationSet,
+		stmt.ChangeAdd:               w.stmtChangeAdd,
 	} {
 		if prepStmt, err = w.conn.Prepare(statement); err != nil {
 			w.errLog.Fatal(`lookup`, err, stmt.Name(statement))
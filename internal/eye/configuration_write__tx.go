@@ -111,9 +111,10 @@ func (w *ConfigurationWrite) txCfgLoadActive(tx *sql.Tx, q *msg.Request,
 }
 
 // txInsertCfgData adds data for configurationID and starts a
-// provisioning period
+// provisioning period with origin prov
 func (w *ConfigurationWrite) txInsertCfgData(tx *sql.Tx, mr *msg.Result,
-	dataID, configurationID string, from time.Time, data []byte) (ok bool, err error) {
+	dataID, configurationID string, from time.Time, data []byte,
+	prov msg.Provision) (ok bool, err error) {
 
 	var res sql.Result
 
@@ -130,7 +131,7 @@ func (w *ConfigurationWrite) txInsertCfgData(tx *sql.Tx, mr *msg.Result,
 		ok = false
 		return
 	}
-	ok, err = w.txStartProvision(tx, mr, from, dataID, configurationID)
+	ok, err = w.txStartProvision(tx, mr, from, dataID, configurationID, prov)
 	return
 }
 
@@ -158,16 +159,25 @@ func (w *ConfigurationWrite) txSetDataValidity(tx *sql.Tx, mr *msg.Result,
 
 // txStartProvision starts a provisioning period for dataID
 func (w *ConfigurationWrite) txStartProvision(tx *sql.Tx, mr *msg.Result,
-	from time.Time, dataID, configurationID string) (ok bool, err error) {
+	from time.Time, dataID, configurationID string,
+	prov msg.Provision) (ok bool, err error) {
 
 	var res sql.Result
 	ok = true
 
+	// provisions without recorded origin were requested via the API
+	if prov.Source == `` {
+		prov.Source = msg.SourceAPI
+	}
+
 	if res, err = tx.Stmt(w.stmtProvAdd).Exec(
 		dataID,
 		configurationID,
 		from,
 		pq.Array([]string{msg.TaskRollout}),
+		prov.Source,
+		prov.Revision,
+		prov.Commit,
 	); err != nil {
 		ok = false
 		return
@@ -201,4 +211,64 @@ func (w *ConfigurationWrite) txFinalizeProvision(tx *sql.Tx, mr *msg.Result,
 	return
 }
 
+// txRecordChange records a change of configurationID in the change
+// feed. It should be called as late as possible within the transaction,
+// since it serializes all transactions recording changes until they
+// commit. mr carries the highest changeID recorded.
+func (w *ConfigurationWrite) txRecordChange(tx *sql.Tx, mr *msg.Result,
+	section, action, configurationID string) (ok bool, err error) {
+
+	var changeID int64
+	ok = true
+
+	if err = tx.Stmt(w.stmtChangeAdd).QueryRow(
+		section,
+		action,
+		configurationID,
+	).Scan(
+		&changeID,
+	); err == sql.ErrNoRows {
+		mr.ServerError(fmt.Errorf(
+			"Invalid number of rows affected: 0 - expected: [1]"))
+		ok, err = false, nil
+		return
+	} else if err != nil {
+		ok = false
+		return
+	}
+	if changeID > mr.ChangeID {
+		mr.ChangeID = changeID
+	}
+	return
+}
+
+// txBegin returns the transaction q is applied in. Requests that are
+// part of a batch chunk are applied in the transaction of the chunk,
+// all other requests in a new transaction.
+func (w *ConfigurationWrite) txBegin(q *msg.Request) (*sql.Tx, error) {
+	if q.Tx != nil {
+		return q.Tx, nil
+	}
+	return w.conn.Begin()
+}
+
+// txCommit commits tx, unless it is the transaction of a batch chunk
+// which is committed by the batch
+func (w *ConfigurationWrite) txCommit(q *msg.Request, tx *sql.Tx) error {
+	if q.Tx != nil {
+		return nil
+	}
+	return tx.Commit()
+}
+
+// txRollback rolls back tx, unless it is the transaction of a batch
+// chunk which is rolled back by the batch once it sees the failed
+// result
+func (w *ConfigurationWrite) txRollback(q *msg.Request, tx *sql.Tx) {
+	if q.Tx != nil {
+		return
+	}
+	tx.Rollback()
+}
+
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
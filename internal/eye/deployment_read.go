@@ -0,0 +1,151 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package eye // import "github.com/solnx/eye/internal/eye"
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/lib/pq"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// DeploymentRead handles read requests for processed deployments
+type DeploymentRead struct {
+	Input    chan msg.Request
+	Shutdown chan struct{}
+	conn     *sql.DB
+	stmtList *sql.Stmt
+	stmtShow *sql.Stmt
+	appLog   *logrus.Logger
+	reqLog   *logrus.Logger
+	errLog   *logrus.Logger
+}
+
+// newDeploymentRead return a new DeploymentRead handler with input buffer of length
+func newDeploymentRead(length int) (r *DeploymentRead) {
+	r = &DeploymentRead{}
+	r.Input = make(chan msg.Request, length)
+	r.Shutdown = make(chan struct{})
+	return
+}
+
+// process is the request dispatcher called by Run
+func (r *DeploymentRead) process(q *msg.Request) {
+	result := msg.FromRequest(q)
+
+	switch q.Action {
+	case msg.ActionList:
+		r.list(q, &result)
+	case msg.ActionShow:
+		r.show(q, &result)
+	default:
+		result.UnknownRequest(q)
+	}
+	q.Reply <- result
+}
+
+// list returns all processed deployments
+func (r *DeploymentRead) list(q *msg.Request, mr *msg.Result) {
+	var (
+		err  error
+		rows *sql.Rows
+	)
+
+	if rows, err = r.stmtList.Query(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	if r.collect(rows, mr) {
+		mr.OK()
+	}
+}
+
+// show returns the processing records of all versions and tasks of a
+// specific deployment
+func (r *DeploymentRead) show(q *msg.Request, mr *msg.Result) {
+	var (
+		err  error
+		rows *sql.Rows
+	)
+
+	if rows, err = r.stmtShow.Query(
+		q.Deployment.ID,
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	if !r.collect(rows, mr) {
+		return
+	}
+	if len(mr.Deployment) == 0 {
+		mr.NotFound(sql.ErrNoRows)
+		return
+	}
+	mr.OK()
+}
+
+// collect appends all deployments from rows to mr. It returns false
+// if mr was set to an error
+func (r *DeploymentRead) collect(rows *sql.Rows, mr *msg.Result) bool {
+	for rows.Next() {
+		dpl, err := scanDeployment(rows)
+		if err != nil {
+			rows.Close()
+			mr.ServerError(err)
+			return false
+		}
+		mr.Deployment = append(mr.Deployment, dpl)
+	}
+	if err := rows.Err(); err != nil {
+		mr.ServerError(err)
+		return false
+	}
+	return true
+}
+
+// scanDeployment reads a single deployment row via s
+func scanDeployment(s interface {
+	Scan(...interface{}) error
+}) (dpl v2.Deployment, err error) {
+	var (
+		statusCode  sql.NullInt64
+		receivedAt  time.Time
+		processedAt pq.NullTime
+	)
+
+	if err = s.Scan(
+		&dpl.ID,
+		&dpl.Version,
+		&dpl.Task,
+		&dpl.CheckInstanceID,
+		&dpl.RequestID,
+		&dpl.Outcome,
+		&statusCode,
+		&receivedAt,
+		&processedAt,
+	); err != nil {
+		return
+	}
+
+	dpl.ReceivedAt = receivedAt.Format(RFC3339Milli)
+	if statusCode.Valid {
+		dpl.StatusCode = uint16(statusCode.Int64)
+	}
+	if processedAt.Valid {
+		dpl.ProcessedAt = processedAt.Time.Format(RFC3339Milli)
+	} else {
+		dpl.ProcessedAt = `never`
+	}
+	return
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -12,20 +12,34 @@ package eye // import "github.com/solnx/eye/internal/eye"
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	msg "github.com/solnx/eye/internal/eye.msg"
+	"github.com/solnx/eye/lib/eye.proto/v2"
 )
 
+// deploymentClaimTimeout is the time after which a deployment that is
+// still recorded as processing may be claimed again, ie. because the
+// eye instance processing it was terminated
+const deploymentClaimTimeout = 10 * time.Minute
+
+// deploymentBatchChunkSize is the number of deployments of a batch
+// that are applied within one transaction
+const deploymentBatchChunkSize = 32
+
 // DeploymentWrite handles deployment requests
 type DeploymentWrite struct {
-	Input      chan msg.Request
-	Shutdown   chan struct{}
-	conn       *sql.DB
-	stmtExists *sql.Stmt
-	appLog     *logrus.Logger
-	reqLog     *logrus.Logger
-	errLog     *logrus.Logger
+	Input        chan msg.Request
+	Shutdown     chan struct{}
+	conn         *sql.DB
+	stmtExists   *sql.Stmt
+	stmtClaim    *sql.Stmt
+	stmtFinish   *sql.Stmt
+	stmtShowTask *sql.Stmt
+	appLog       *logrus.Logger
+	reqLog       *logrus.Logger
+	errLog       *logrus.Logger
 }
 
 // newDeploymentWrite return a new DeploymentWrite handler with input buffer of length
@@ -40,63 +54,234 @@ func newDeploymentWrite(length int) (r *DeploymentWrite) {
 func (w *DeploymentWrite) process(q *msg.Request) {
 	result := msg.FromRequest(q)
 
-	// this function only sends the result in error cases. otherwise
-	// w.notification() forwards the request to the configuration
-	// handler which will send the result
 	switch q.Action {
 	case msg.ActionProcess:
-		w.notification(q, &result)
+		w.deploy(q, &result)
 	case msg.ActionNotification:
-		w.notification(q, &result)
+		w.deploy(q, &result)
+	case msg.ActionBatch:
+		w.batch(q, &result)
 	default:
 		result.UnknownRequest(q)
-		q.Reply <- result
 	}
+	q.Reply <- result
 }
 
-//
-func (w *DeploymentWrite) notification(q *msg.Request, mr *msg.Result) {
+// deploy processes a SOMA deployment. Deployments with a SOMA
+// deployment ID are only processed once per version and task, repeated
+// deliveries return the recorded outcome of the deployment
+func (w *DeploymentWrite) deploy(q *msg.Request, mr *msg.Result) {
+	if q.Deployment.ID == `` {
+		*mr = w.notification(q)
+		return
+	}
+
+	var (
+		err error
+		res sql.Result
+		n   int64
+	)
+
+	if res, err = w.stmt(q, w.stmtClaim).Exec(
+		q.Deployment.ID,
+		q.Deployment.Version,
+		q.ConfigurationTask,
+		q.Deployment.CheckInstanceID,
+		q.ID.String(),
+		deploymentClaimTimeout.Seconds(),
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	if n, err = res.RowsAffected(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	// 0: deployment was already processed or is being processed
+	if n == 0 {
+		w.duplicate(q, mr)
+		return
+	}
+
+	*mr = w.notification(q)
+	w.finish(q, mr)
+}
+
+// batch processes all deployments contained in q.Batch. The batch is
+// split into chunks which are applied one after the other, each within
+// its own transaction
+func (w *DeploymentWrite) batch(q *msg.Request, mr *msg.Result) {
+	mr.Batch = make([]msg.Result, len(q.Batch))
+
+	for start := 0; start < len(q.Batch); start += deploymentBatchChunkSize {
+		end := start + deploymentBatchChunkSize
+		if end > len(q.Batch) {
+			end = len(q.Batch)
+		}
+		w.batchChunk(q.Batch[start:end], mr.Batch[start:end])
+	}
+
+	for i := range mr.Batch {
+		if mr.Batch[i].HasFailed() {
+			mr.Partial()
+			return
+		}
+	}
+	mr.OK()
+}
+
+// batchChunk applies the deployments of chunk in payload order within
+// one transaction and records their results in results. If a deployment
+// fails, the transaction is rolled back and all deployments of chunk
+// are reported as failed.
+func (w *DeploymentWrite) batchChunk(chunk []msg.Request, results []msg.Result) {
+	var (
+		err error
+		tx  *sql.Tx
+	)
+
+	if tx, err = w.conn.Begin(); err != nil {
+		goto abort
+	}
+
+	for i := range chunk {
+		chunk[i].Tx = tx
+		results[i] = msg.FromRequest(&chunk[i])
+		w.deploy(&chunk[i], &results[i])
+
+		if results[i].HasFailed() {
+			tx.Rollback()
+			err = fmt.Errorf("Rolled back with failed deployment %s"+
+				" of the same batch chunk: %s",
+				chunk[i].Deployment.ID,
+				results[i].Error,
+			)
+			w.batchChunkFailed(chunk, results, i, err)
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		goto abort
+	}
+	return
+
+abort:
+	w.batchChunkFailed(chunk, results, -1, err)
+}
+
+// batchChunkFailed reports all deployments of chunk as failed with err,
+// except the deployment at index failed which keeps its own result
+func (w *DeploymentWrite) batchChunkFailed(chunk []msg.Request,
+	results []msg.Result, failed int, err error) {
+
+	for i := range chunk {
+		if i == failed {
+			continue
+		}
+		results[i] = msg.FromRequest(&chunk[i])
+		results[i].ServerError(err)
+	}
+}
+
+// stmt returns s bound to the transaction of the batch chunk q is part
+// of, or s itself if q is not part of a batch
+func (w *DeploymentWrite) stmt(q *msg.Request, s *sql.Stmt) *sql.Stmt {
+	if q.Tx != nil {
+		return q.Tx.Stmt(s)
+	}
+	return s
+}
+
+// duplicate returns the recorded outcome of an already received
+// deployment
+func (w *DeploymentWrite) duplicate(q *msg.Request, mr *msg.Result) {
+	var (
+		err error
+		dpl v2.Deployment
+	)
+
+	if dpl, err = scanDeployment(w.stmt(q, w.stmtShowTask).QueryRow(
+		q.Deployment.ID,
+		q.Deployment.Version,
+		q.ConfigurationTask,
+	)); err != nil {
+		// sql.ErrNoRows is also a server error, since the claim
+		// just found the deployment
+		mr.ServerError(err)
+		return
+	}
+
+	// nothing was processed, there is nothing to invalidate, clear
+	// or report
+	mr.Flags.AlarmClearing = false
+	mr.Flags.CacheInvalidation = false
+	mr.Flags.SendDeploymentFeedback = false
+	mr.Deployment = append(mr.Deployment, dpl)
+	mr.OK()
+}
+
+// finish records the outcome of a processed deployment
+func (w *DeploymentWrite) finish(q *msg.Request, mr *msg.Result) {
+	outcome := msg.OutcomeSuccess
+	if mr.Error != nil || mr.Code >= 400 {
+		outcome = msg.OutcomeFailed
+	}
+
+	if _, err := w.stmt(q, w.stmtFinish).Exec(
+		q.Deployment.ID,
+		q.Deployment.Version,
+		q.ConfigurationTask,
+		outcome,
+		mr.Code,
+		q.ID.String(),
+	); err != nil {
+		// the deployment was processed, the recording failure only
+		// affects the detection of repeated deliveries
+		w.errLog.Errorln(`deployment_w`, q.ID.String(), err)
+	}
+}
+
+// notification forwards the deployment to the configuration handler
+// and returns its result
+func (w *DeploymentWrite) notification(q *msg.Request) msg.Result {
 	var err error
 	var configurationID string
 
-	if err = w.stmtExists.QueryRow(
+	mr := msg.FromRequest(q)
+
+	if err = w.stmt(q, w.stmtExists).QueryRow(
 		q.Configuration.ID,
 	).Scan(
 		&configurationID,
-	); err != nil {
+	); err != nil && err != sql.ErrNoRows {
 		mr.ServerError(err)
-		q.Reply <- *mr
-		return
+		return mr
 	}
 
 	// get the configuration update handler
 	handler := handlerLookup.Get(`configuration_w`)
+	fwd := *q
+	fwd.Reply = make(chan msg.Result, 1)
 
 	// check if we have the configuration
 	switch q.ConfigurationTask {
 	case msg.TaskRollout:
 		// rollout + configuration does not exist -> ConfigurationAdd
 		if err == sql.ErrNoRows {
-			q.Section = msg.SectionConfiguration
-			q.Action = msg.ActionAdd
-			handler.Intake() <- *q
-			return
-		} else if err != nil {
-			mr.ServerError(err)
-			q.Reply <- *mr
-			return
+			fwd.Section = msg.SectionConfiguration
+			fwd.Action = msg.ActionAdd
+			handler.Intake() <- fwd
+			return <-fwd.Reply
 		}
 	case msg.TaskDelete, msg.TaskDeprovision:
 		// deprovision|delete + configuration does not exist -> no-op
 		if err == sql.ErrNoRows {
-			q.Section = msg.SectionConfiguration
-			q.Action = msg.ActionNop
-			handler.Intake() <- *q
-			return
-		} else if err != nil {
-			mr.ServerError(err)
-			q.Reply <- *mr
-			return
+			fwd.Section = msg.SectionConfiguration
+			fwd.Action = msg.ActionNop
+			handler.Intake() <- fwd
+			return <-fwd.Reply
 		}
 	}
 
@@ -108,14 +293,15 @@ func (w *DeploymentWrite) notification(q *msg.Request, mr *msg.Result) {
 		))
 	}
 
-	q.Section = msg.SectionConfiguration
+	fwd.Section = msg.SectionConfiguration
 	switch q.ConfigurationTask {
 	case msg.TaskRollout:
-		q.Action = msg.ActionUpdate
+		fwd.Action = msg.ActionUpdate
 	case msg.TaskDelete, msg.TaskDeprovision:
-		q.Action = msg.ActionRemove
+		fwd.Action = msg.ActionRemove
 	}
-	handler.Intake() <- *q
+	handler.Intake() <- fwd
+	return <-fwd.Reply
 }
 
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
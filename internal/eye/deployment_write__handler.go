@@ -32,7 +32,10 @@ func (w *DeploymentWrite) Run() {
 	var err error
 
 	for statement, prepStmt := range map[string]*sql.Stmt{
-		stmt.CfgExists: w.stmtExists,
+		stmt.CfgExists:          w.stmtExists,
+		stmt.DeploymentClaim:    w.stmtClaim,
+		stmt.DeploymentFinish:   w.stmtFinish,
+		stmt.DeploymentShowTask: w.stmtShowTask,
 	} {
 		if prepStmt, err = w.conn.Prepare(statement); err != nil {
 			w.errLog.Fatal(`deployment`, err, stmt.Name(statement))
@@ -26,6 +26,9 @@ type HandlerMap struct {
 func (h *HandlerMap) Add(key string, value Handler) {
 	h.Lock()
 	defer h.Unlock()
+	if h.hmap == nil {
+		h.hmap = make(map[string]Handler)
+	}
 	h.hmap[key] = value
 }
 
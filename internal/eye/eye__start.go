@@ -23,7 +23,13 @@ func (e *Eye) Start() {
 	// start regular handlers
 	e.handlerMap.Add(`configuration_r`, newConfigurationRead(e.conf.Eye.QueueLen))
 	e.handlerMap.Add(`configuration_w`, newConfigurationWrite(e.conf.Eye.QueueLen))
+	e.handlerMap.Add(`deployment_r`, newDeploymentRead(e.conf.Eye.QueueLen))
 	e.handlerMap.Add(`deployment_w`, newDeploymentWrite(e.conf.Eye.QueueLen))
+	e.handlerMap.Add(`change_r`, newChangeRead(e.conf.Eye.QueueLen))
+	e.handlerMap.Add(`feedback_r`, newFeedbackRead(e.conf.Eye.QueueLen))
+	e.handlerMap.Add(`feedback_w`, newFeedbackWrite(e.conf.Eye.QueueLen))
+	e.handlerMap.Add(`subscription_r`, newSubscriptionRead(e.conf.Eye.QueueLen))
+	e.handlerMap.Add(`subscription_w`, newSubscriptionWrite(e.conf.Eye.QueueLen))
 	e.handlerMap.Add(`lookup_r`, newLookupRead(e.conf.Eye.QueueLen))
 	e.handlerMap.Add(`registration_r`, newRegistrationRead(e.conf.Eye.QueueLen))
 	e.handlerMap.Add(`registration_w`, newRegistrationWrite(e.conf.Eye.QueueLen))
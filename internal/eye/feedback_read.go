@@ -0,0 +1,179 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package eye // import "github.com/solnx/eye/internal/eye"
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/lib/pq"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// FeedbackRead handles read requests for deployment feedback
+type FeedbackRead struct {
+	Input      chan msg.Request
+	Shutdown   chan struct{}
+	conn       *sql.DB
+	stmtShow   *sql.Stmt
+	stmtSearch *sql.Stmt
+	appLog     *logrus.Logger
+	reqLog     *logrus.Logger
+	errLog     *logrus.Logger
+}
+
+// newFeedbackRead return a new FeedbackRead handler with input buffer of length
+func newFeedbackRead(length int) (r *FeedbackRead) {
+	r = &FeedbackRead{}
+	r.Input = make(chan msg.Request, length)
+	r.Shutdown = make(chan struct{})
+	return
+}
+
+// process is the request dispatcher called by Run
+func (r *FeedbackRead) process(q *msg.Request) {
+	result := msg.FromRequest(q)
+
+	switch q.Action {
+	case msg.ActionList, msg.ActionSearch:
+		r.search(q, &result)
+	case msg.ActionShow:
+		r.show(q, &result)
+	default:
+		result.UnknownRequest(q)
+	}
+	q.Reply <- result
+}
+
+// show returns a specific feedback
+func (r *FeedbackRead) show(q *msg.Request, mr *msg.Result) {
+	var (
+		err error
+		fb  v2.Feedback
+	)
+
+	if fb, err = scanFeedback(r.stmtShow.QueryRow(
+		q.Feedback.ID,
+	)); err == sql.ErrNoRows {
+		mr.NotFound(err)
+		return
+	} else if err != nil {
+		mr.ServerError(err)
+		return
+	}
+	mr.Feedback = append(mr.Feedback, fb)
+	mr.OK()
+}
+
+// search returns all feedbacks, optionally filtered by their delivery
+// status
+func (r *FeedbackRead) search(q *msg.Request, mr *msg.Result) {
+	var (
+		err          error
+		rows         *sql.Rows
+		searchStatus sql.NullString
+	)
+
+	// set NULL-able query conditions
+	if q.Search.Feedback.Status != `` {
+		searchStatus.String = q.Search.Feedback.Status
+		searchStatus.Valid = true
+	}
+
+	if rows, err = r.stmtSearch.Query(
+		searchStatus,
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	collectFeedbacks(rows, mr)
+}
+
+// collectFeedbacks appends all feedbacks from rows to mr
+func collectFeedbacks(rows *sql.Rows, mr *msg.Result) {
+	for rows.Next() {
+		fb, err := scanFeedback(rows)
+		if err != nil {
+			rows.Close()
+			mr.ServerError(err)
+			return
+		}
+		mr.Feedback = append(mr.Feedback, fb)
+	}
+	if err := rows.Err(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	mr.OK()
+}
+
+// scanFeedback reads a single feedback row via s
+func scanFeedback(s interface {
+	Scan(...interface{}) error
+}) (fb v2.Feedback, err error) {
+	var (
+		feedbackID, requestID, task   string
+		outcome, feedbackURL, status  string
+		attempts                      int64
+		configurationID, lastError    sql.NullString
+		createdAt, nextAttemptAt      time.Time
+		lastAttemptAt, acknowledgedAt pq.NullTime
+	)
+
+	if err = s.Scan(
+		&feedbackID,
+		&requestID,
+		&configurationID,
+		&task,
+		&outcome,
+		&feedbackURL,
+		&status,
+		&attempts,
+		&lastError,
+		&createdAt,
+		&lastAttemptAt,
+		&nextAttemptAt,
+		&acknowledgedAt,
+	); err != nil {
+		return
+	}
+
+	fb = v2.Feedback{
+		ID:            feedbackID,
+		RequestID:     requestID,
+		Task:          task,
+		Outcome:       outcome,
+		URL:           feedbackURL,
+		Status:        status,
+		Attempts:      attempts,
+		CreatedAt:     createdAt.Format(RFC3339Milli),
+		NextAttemptAt: nextAttemptAt.Format(RFC3339Milli),
+	}
+	if configurationID.Valid {
+		fb.ConfigurationID = configurationID.String
+	}
+	if lastError.Valid {
+		fb.LastError = lastError.String
+	}
+	if lastAttemptAt.Valid {
+		fb.LastAttemptAt = lastAttemptAt.Time.Format(RFC3339Milli)
+	} else {
+		fb.LastAttemptAt = `never`
+	}
+	if acknowledgedAt.Valid {
+		fb.AcknowledgedAt = acknowledgedAt.Time.Format(RFC3339Milli)
+	} else {
+		fb.AcknowledgedAt = `never`
+	}
+	return
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
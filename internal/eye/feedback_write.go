@@ -0,0 +1,180 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package eye // import "github.com/solnx/eye/internal/eye"
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	uuid "github.com/satori/go.uuid"
+	msg "github.com/solnx/eye/internal/eye.msg"
+)
+
+// Redelivery of failed feedback backs off exponentially, starting at
+// feedbackMinBackoff up to feedbackMaxBackoff between attempts. New
+// feedback becomes due for redelivery after feedbackMinBackoff as
+// well, its first delivery attempt is performed by the request that
+// added it.
+const (
+	feedbackMinBackoff = 30 * time.Second
+	feedbackMaxBackoff = 1 * time.Hour
+)
+
+// feedbackBatchSize limits how many outstanding feedbacks are claimed
+// for a single redelivery run
+const feedbackBatchSize = 256
+
+// feedbackClaimLease is the time after which a claimed feedback whose
+// delivery attempt was never recorded is due again, ie. because the
+// eye instance performing it was terminated
+const feedbackClaimLease = 5 * time.Minute
+
+// FeedbackWrite handles write requests for deployment feedback
+type FeedbackWrite struct {
+	Input           chan msg.Request
+	Shutdown        chan struct{}
+	conn            *sql.DB
+	stmtAdd         *sql.Stmt
+	stmtClaim       *sql.Stmt
+	stmtAcknowledge *sql.Stmt
+	stmtReschedule  *sql.Stmt
+	appLog          *logrus.Logger
+	reqLog          *logrus.Logger
+	errLog          *logrus.Logger
+}
+
+// newFeedbackWrite return a new FeedbackWrite handler with input buffer of length
+func newFeedbackWrite(length int) (w *FeedbackWrite) {
+	w = &FeedbackWrite{}
+	w.Input = make(chan msg.Request, length)
+	w.Shutdown = make(chan struct{})
+	return
+}
+
+// process is the request dispatcher called by Run
+func (w *FeedbackWrite) process(q *msg.Request) {
+	result := msg.FromRequest(q)
+
+	switch q.Action {
+	case msg.ActionAdd:
+		w.add(q, &result)
+	case msg.ActionPending:
+		w.claim(q, &result)
+	case msg.ActionAcknowledge:
+		w.acknowledge(q, &result)
+	case msg.ActionReschedule:
+		w.reschedule(q, &result)
+	default:
+		result.UnknownRequest(q)
+	}
+	q.Reply <- result
+}
+
+// add records a new feedback that has to be delivered to SOMA
+func (w *FeedbackWrite) add(q *msg.Request, mr *msg.Result) {
+	var (
+		err             error
+		res             sql.Result
+		configurationID sql.NullString
+	)
+
+	// generate FeedbackID
+	q.Feedback.ID = uuid.Must(uuid.NewV4()).String()
+	q.Feedback.Status = msg.FeedbackPending
+
+	// failed requests might not have a known configuration
+	if q.Feedback.ConfigurationID != `` {
+		configurationID.String = q.Feedback.ConfigurationID
+		configurationID.Valid = true
+	}
+
+	if res, err = w.stmtAdd.Exec(
+		q.Feedback.ID,
+		q.Feedback.RequestID,
+		configurationID,
+		q.Feedback.Task,
+		q.Feedback.Outcome,
+		q.Feedback.URL,
+		feedbackMinBackoff.Seconds(),
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	if mr.ExpectedRows(&res, 1) {
+		mr.Feedback = append(mr.Feedback, q.Feedback)
+	}
+}
+
+// claim returns all feedbacks that are not yet acknowledged and are
+// due for redelivery. They are claimed for this eye instance, so that
+// concurrent redelivery runs of other instances skip them
+func (w *FeedbackWrite) claim(q *msg.Request, mr *msg.Result) {
+	var (
+		err  error
+		rows *sql.Rows
+	)
+
+	if rows, err = w.stmtClaim.Query(
+		feedbackBatchSize,
+		feedbackClaimLease.Seconds(),
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	collectFeedbacks(rows, mr)
+}
+
+// acknowledge records the successful delivery of a feedback
+func (w *FeedbackWrite) acknowledge(q *msg.Request, mr *msg.Result) {
+	var (
+		err error
+		res sql.Result
+	)
+
+	if res, err = w.stmtAcknowledge.Exec(
+		q.Feedback.ID,
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	if mr.ExpectedRows(&res, 1) {
+		q.Feedback.Status = msg.FeedbackAcknowledged
+		mr.Feedback = append(mr.Feedback, q.Feedback)
+	}
+}
+
+// reschedule records a failed delivery attempt of a feedback and
+// schedules its next delivery attempt
+func (w *FeedbackWrite) reschedule(q *msg.Request, mr *msg.Result) {
+	var (
+		err error
+		res sql.Result
+	)
+
+	if res, err = w.stmtReschedule.Exec(
+		q.Feedback.ID,
+		q.Feedback.LastError,
+		feedbackMinBackoff.Seconds(),
+		feedbackMaxBackoff.Seconds(),
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	// 0: feedback was acknowledged by a concurrent delivery
+	if mr.ExpectedRows(&res, 0, 1) {
+		q.Feedback.Status = msg.FeedbackFailed
+		mr.Feedback = append(mr.Feedback, q.Feedback)
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,68 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package eye // import "github.com/solnx/eye/internal/eye"
+
+import (
+	"database/sql"
+
+	"github.com/Sirupsen/logrus"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	stmt "github.com/solnx/eye/internal/eye.stmt"
+)
+
+// Implementation of the Handler interface
+
+// Register initializes resources provided by the eye application
+func (w *FeedbackWrite) Register(c *sql.DB, l ...*logrus.Logger) {
+	w.conn = c
+	w.appLog = l[0]
+	w.reqLog = l[1]
+	w.errLog = l[2]
+}
+
+// Run is the event loop for FeedbackWrite
+func (w *FeedbackWrite) Run() {
+	var err error
+
+	for statement, prepStmt := range map[string]*sql.Stmt{
+		stmt.FeedbackAdd:         w.stmtAdd,
+		stmt.FeedbackClaim:       w.stmtClaim,
+		stmt.FeedbackAcknowledge: w.stmtAcknowledge,
+		stmt.FeedbackReschedule:  w.stmtReschedule,
+	} {
+		if prepStmt, err = w.conn.Prepare(statement); err != nil {
+			w.errLog.Fatal(`feedback_w`, err, stmt.Name(statement))
+		}
+		defer prepStmt.Close()
+	}
+
+runloop:
+	for {
+		select {
+		case <-w.Shutdown:
+			break runloop
+		case req := <-w.Input:
+			go func() {
+				w.process(&req)
+			}()
+		}
+	}
+}
+
+// ShutdownNow signals the handler to shut down
+func (w *FeedbackWrite) ShutdownNow() {
+	close(w.Shutdown)
+}
+
+// Intake exposes the Input channel as part of the handler interface
+func (w *FeedbackWrite) Intake() chan msg.Request {
+	return w.Input
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -90,8 +90,8 @@ func (r *RegistrationRead) show(q *msg.Request, mr *msg.Result) {
 	var (
 		err                                  error
 		registrationID, application, address string
-		port, database                       int64
-		registeredAt                         time.Time
+		port, database, leaseTTL             int64
+		registeredAt, renewedAt              time.Time
 	)
 
 	if err = r.stmtShow.QueryRow(
@@ -103,6 +103,8 @@ func (r *RegistrationRead) show(q *msg.Request, mr *msg.Result) {
 		&port,
 		&database,
 		&registeredAt,
+		&leaseTTL,
+		&renewedAt,
 	); err == sql.ErrNoRows {
 		mr.NotFound(err)
 		return
@@ -117,6 +119,8 @@ func (r *RegistrationRead) show(q *msg.Request, mr *msg.Result) {
 		Port:         port,
 		Database:     database,
 		RegisteredAt: registeredAt,
+		LeaseTTL:     leaseTTL,
+		RenewedAt:    renewedAt,
 	})
 	mr.OK()
 }
@@ -130,8 +134,8 @@ func (r *RegistrationRead) search(q *msg.Request, mr *msg.Result) {
 		searchApp, searchAddr                sql.NullString
 		searchPort, searchDB                 sql.NullInt64
 		registrationID, application, address string
-		port, database                       int64
-		registeredAt                         time.Time
+		port, database, leaseTTL             int64
+		registeredAt, renewedAt              time.Time
 	)
 
 	// set NULL-able query conditions
@@ -174,6 +178,8 @@ func (r *RegistrationRead) search(q *msg.Request, mr *msg.Result) {
 			&port,
 			&database,
 			&registeredAt,
+			&leaseTTL,
+			&renewedAt,
 		); err != nil {
 			rows.Close()
 			mr.ServerError(err)
@@ -187,6 +193,8 @@ func (r *RegistrationRead) search(q *msg.Request, mr *msg.Result) {
 			Port:         port,
 			Database:     database,
 			RegisteredAt: registeredAt,
+			LeaseTTL:     leaseTTL,
+			RenewedAt:    renewedAt,
 		})
 	}
 
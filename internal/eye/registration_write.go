@@ -11,11 +11,13 @@ package eye // import "github.com/solnx/eye/internal/eye"
 
 import (
 	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	msg "github.com/solnx/eye/internal/eye.msg"
 	uuid "github.com/satori/go.uuid"
+	"github.com/solnx/eye/lib/eye.proto/v2"
 )
 
 // RegistrationWrite handles read requests for hash lookups
@@ -24,7 +26,9 @@ type RegistrationWrite struct {
 	Shutdown   chan struct{}
 	conn       *sql.DB
 	stmtAdd    *sql.Stmt
+	stmtExpire *sql.Stmt
 	stmtRemove *sql.Stmt
+	stmtRenew  *sql.Stmt
 	stmtShow   *sql.Stmt
 	stmtUpdate *sql.Stmt
 	appLog     *logrus.Logger
@@ -51,6 +55,10 @@ func (w *RegistrationWrite) process(q *msg.Request) {
 		w.remove(q, &result)
 	case msg.ActionUpdate:
 		w.update(q, &result)
+	case msg.ActionRenew:
+		w.renew(q, &result)
+	case msg.ActionExpire:
+		w.expire(q, &result)
 	default:
 		result.UnknownRequest(q)
 	}
@@ -81,6 +89,7 @@ func (w *RegistrationWrite) add(q *msg.Request, mr *msg.Result) {
 		q.Registration.Address,
 		q.Registration.Port,
 		q.Registration.Database,
+		q.Registration.LeaseTTL,
 	); err != nil {
 		mr.ServerError(err)
 		return
@@ -99,8 +108,8 @@ func (w *RegistrationWrite) remove(q *msg.Request, mr *msg.Result) {
 		res                                  sql.Result
 		err                                  error
 		registrationID, application, address string
-		port, database                       int64
-		registeredAt                         time.Time
+		port, database, leaseTTL             int64
+		registeredAt, renewedAt              time.Time
 	)
 
 	// open transaction
@@ -119,6 +128,8 @@ func (w *RegistrationWrite) remove(q *msg.Request, mr *msg.Result) {
 		&port,
 		&database,
 		&registeredAt,
+		&leaseTTL,
+		&renewedAt,
 	); err == sql.ErrNoRows {
 		mr.NotFound(err)
 		tx.Rollback()
@@ -139,6 +150,8 @@ func (w *RegistrationWrite) remove(q *msg.Request, mr *msg.Result) {
 	q.Registration.Port = port
 	q.Registration.Database = database
 	q.Registration.RegisteredAt = registeredAt
+	q.Registration.LeaseTTL = leaseTTL
+	q.Registration.RenewedAt = renewedAt
 
 	// delete registration
 	if res, err = tx.Stmt(w.stmtRemove).Exec(
@@ -177,6 +190,7 @@ func (w *RegistrationWrite) update(q *msg.Request, mr *msg.Result) {
 
 	// update registration
 	q.Registration.RegisteredAt = time.Now().UTC()
+	q.Registration.RenewedAt = q.Registration.RegisteredAt
 	if res, err = tx.Stmt(w.stmtUpdate).Exec(
 		q.Registration.ID,
 		q.Registration.Application,
@@ -184,6 +198,7 @@ func (w *RegistrationWrite) update(q *msg.Request, mr *msg.Result) {
 		q.Registration.Port,
 		q.Registration.Database,
 		q.Registration.RegisteredAt,
+		q.Registration.LeaseTTL,
 	); err != nil {
 		mr.ServerError(err)
 		tx.Rollback()
@@ -202,4 +217,108 @@ func (w *RegistrationWrite) update(q *msg.Request, mr *msg.Result) {
 	tx.Rollback()
 }
 
+// renew extends the lease of a registration
+func (w *RegistrationWrite) renew(q *msg.Request, mr *msg.Result) {
+	var (
+		tx                                   *sql.Tx
+		res                                  sql.Result
+		err                                  error
+		registrationID, application, address string
+		port, database, leaseTTL             int64
+		registeredAt, renewedAt              time.Time
+	)
+
+	// open transaction
+	if tx, err = w.conn.Begin(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	// renew lease
+	if res, err = tx.Stmt(w.stmtRenew).Exec(
+		q.Registration.ID,
+		time.Now().UTC(),
+	); err != nil {
+		mr.ServerError(err)
+		tx.Rollback()
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		// lease expired or never existed, the client must register
+		// again
+		mr.NotFound(fmt.Errorf("Registration %s not found",
+			q.Registration.ID))
+		tx.Rollback()
+		return
+	}
+	if !mr.ExpectedRows(&res, 1) {
+		tx.Rollback()
+		return
+	}
+
+	// retrieve full registration to return the renewed lease
+	if err = tx.Stmt(w.stmtShow).QueryRow(
+		q.Registration.ID,
+	).Scan(
+		&registrationID,
+		&application,
+		&address,
+		&port,
+		&database,
+		&registeredAt,
+		&leaseTTL,
+		&renewedAt,
+	); err != nil {
+		mr.ServerError(err)
+		tx.Rollback()
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	mr.Registration = append(mr.Registration, v2.Registration{
+		ID:           registrationID,
+		Application:  application,
+		Address:      address,
+		Port:         port,
+		Database:     database,
+		RegisteredAt: registeredAt,
+		LeaseTTL:     leaseTTL,
+		RenewedAt:    renewedAt,
+	})
+}
+
+// expire deletes all registrations whose lease has not been renewed
+// in time and returns their IDs
+func (w *RegistrationWrite) expire(q *msg.Request, mr *msg.Result) {
+	var (
+		registrationID string
+		rows           *sql.Rows
+		err            error
+	)
+
+	if rows, err = w.stmtExpire.Query(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&registrationID); err != nil {
+			rows.Close()
+			mr.ServerError(err)
+			return
+		}
+		mr.Registration = append(mr.Registration, v2.Registration{
+			ID: registrationID,
+		})
+	}
+	if err = rows.Err(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	mr.OK()
+}
+
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
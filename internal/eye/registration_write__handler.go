@@ -34,6 +34,8 @@ func (w *RegistrationWrite) Run() {
 	for statement, prepStmt := range map[string]*sql.Stmt{
 		stmt.RegistryAdd:    w.stmtAdd,
 		stmt.RegistryDel:    w.stmtRemove,
+		stmt.RegistryExpire: w.stmtExpire,
+		stmt.RegistryRenew:  w.stmtRenew,
 		stmt.RegistryShow:   w.stmtShow,
 		stmt.RegistryUpdate: w.stmtUpdate,
 	} {
@@ -0,0 +1,278 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package eye // import "github.com/solnx/eye/internal/eye"
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/lib/pq"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// SubscriptionRead handles read requests for webhook subscriptions
+type SubscriptionRead struct {
+	Input       chan msg.Request
+	Shutdown    chan struct{}
+	conn        *sql.DB
+	stmtList    *sql.Stmt
+	stmtShow    *sql.Stmt
+	stmtMatch   *sql.Stmt
+	stmtHistory *sql.Stmt
+	appLog      *logrus.Logger
+	reqLog      *logrus.Logger
+	errLog      *logrus.Logger
+}
+
+// newSubscriptionRead return a new SubscriptionRead handler with input buffer of length
+func newSubscriptionRead(length int) (r *SubscriptionRead) {
+	r = &SubscriptionRead{}
+	r.Input = make(chan msg.Request, length)
+	r.Shutdown = make(chan struct{})
+	return
+}
+
+// process is the request dispatcher called by Run
+func (r *SubscriptionRead) process(q *msg.Request) {
+	result := msg.FromRequest(q)
+
+	switch q.Action {
+	case msg.ActionList:
+		r.list(q, &result)
+	case msg.ActionShow:
+		r.show(q, &result)
+	case msg.ActionSearch:
+		r.match(q, &result)
+	case msg.ActionHistory:
+		r.history(q, &result)
+	default:
+		result.UnknownRequest(q)
+	}
+	q.Reply <- result
+}
+
+// list returns all subscriptions
+func (r *SubscriptionRead) list(q *msg.Request, mr *msg.Result) {
+	var (
+		err  error
+		rows *sql.Rows
+	)
+
+	if rows, err = r.stmtList.Query(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	for rows.Next() {
+		sub, err := r.scan(rows)
+		if err != nil {
+			rows.Close()
+			mr.ServerError(err)
+			return
+		}
+		mr.Subscription = append(mr.Subscription, sub)
+	}
+	if err = rows.Err(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	mr.OK()
+}
+
+// show returns a specific subscription
+func (r *SubscriptionRead) show(q *msg.Request, mr *msg.Result) {
+	var (
+		err error
+		sub v2.Subscription
+	)
+
+	if sub, err = r.scan(r.stmtShow.QueryRow(
+		q.Subscription.ID,
+	)); err == sql.ErrNoRows {
+		mr.NotFound(err)
+		return
+	} else if err != nil {
+		mr.ServerError(err)
+		return
+	}
+	mr.Subscription = append(mr.Subscription, sub)
+	mr.OK()
+}
+
+// match returns all subscriptions whose filter matches the event
+// described by q.Search.Subscription.Filter
+func (r *SubscriptionRead) match(q *msg.Request, mr *msg.Result) {
+	var (
+		err    error
+		rows   *sql.Rows
+		team   sql.NullString
+		hostID sql.NullInt64
+		filter = q.Search.Subscription.Filter
+	)
+
+	// events without team or host information only match
+	// subscriptions that do not filter on them
+	if filter.Team != `` {
+		team.String = filter.Team
+		team.Valid = true
+	}
+	if filter.HostID != 0 {
+		hostID.Int64 = int64(filter.HostID)
+		hostID.Valid = true
+	}
+
+	if rows, err = r.stmtMatch.Query(
+		filter.Section,
+		filter.Action,
+		team,
+		hostID,
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	for rows.Next() {
+		sub, err := r.scan(rows)
+		if err != nil {
+			rows.Close()
+			mr.ServerError(err)
+			return
+		}
+		mr.Subscription = append(mr.Subscription, sub)
+	}
+	if err = rows.Err(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	mr.OK()
+}
+
+// history returns all event deliveries for a subscription
+func (r *SubscriptionRead) history(q *msg.Request, mr *msg.Result) {
+	var (
+		err  error
+		rows *sql.Rows
+	)
+
+	if rows, err = r.stmtHistory.Query(
+		q.Subscription.ID,
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	collectDeliveries(rows, mr)
+}
+
+// scan reads a single subscription row via s
+func (r *SubscriptionRead) scan(s interface {
+	Scan(...interface{}) error
+}) (sub v2.Subscription, err error) {
+	var (
+		subscriptionID, url, section string
+		action, team, hostID         sql.NullString
+		createdAt                    time.Time
+	)
+
+	if err = s.Scan(
+		&subscriptionID,
+		&url,
+		&section,
+		&action,
+		&team,
+		&hostID,
+		&createdAt,
+	); err != nil {
+		return
+	}
+
+	sub = v2.Subscription{
+		ID:        subscriptionID,
+		URL:       url,
+		CreatedAt: createdAt.Format(RFC3339Milli),
+		Filter: v2.SubscriptionFilter{
+			Section: section,
+		},
+	}
+	if action.Valid {
+		sub.Filter.Action = action.String
+	}
+	if team.Valid {
+		sub.Filter.Team = team.String
+	}
+	if hostID.Valid {
+		if sub.Filter.HostID, err = strconv.ParseUint(
+			hostID.String, 10, 64,
+		); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// collectDeliveries appends all deliveries from rows to mr
+func collectDeliveries(rows *sql.Rows, mr *msg.Result) {
+	for rows.Next() {
+		var (
+			dlv                        v2.Delivery
+			payload                    []byte
+			lastError                  sql.NullString
+			createdAt, nextAttemptAt   time.Time
+			lastAttemptAt, deliveredAt pq.NullTime
+		)
+
+		if err := rows.Scan(
+			&dlv.ID,
+			&dlv.SubscriptionID,
+			&dlv.EventID,
+			&dlv.EventType,
+			&payload,
+			&dlv.URL,
+			&dlv.Secret,
+			&dlv.Status,
+			&dlv.Attempts,
+			&lastError,
+			&createdAt,
+			&lastAttemptAt,
+			&nextAttemptAt,
+			&deliveredAt,
+		); err != nil {
+			rows.Close()
+			mr.ServerError(err)
+			return
+		}
+
+		dlv.Payload = payload
+		dlv.CreatedAt = createdAt.Format(RFC3339Milli)
+		dlv.NextAttemptAt = nextAttemptAt.Format(RFC3339Milli)
+		if lastError.Valid {
+			dlv.LastError = lastError.String
+		}
+		if lastAttemptAt.Valid {
+			dlv.LastAttemptAt = lastAttemptAt.Time.Format(RFC3339Milli)
+		} else {
+			dlv.LastAttemptAt = `never`
+		}
+		if deliveredAt.Valid {
+			dlv.DeliveredAt = deliveredAt.Time.Format(RFC3339Milli)
+		} else {
+			dlv.DeliveredAt = `never`
+		}
+		mr.Delivery = append(mr.Delivery, dlv)
+	}
+	if err := rows.Err(); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	mr.OK()
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,259 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package eye // import "github.com/solnx/eye/internal/eye"
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	uuid "github.com/satori/go.uuid"
+	msg "github.com/solnx/eye/internal/eye.msg"
+)
+
+// Redelivery of failed webhook events backs off exponentially, starting
+// at deliveryMinBackoff up to deliveryMaxBackoff between attempts.
+// Events that could not be delivered within deliveryMaxAttempts are
+// failed permanently.
+const (
+	deliveryMinBackoff  = 10 * time.Second
+	deliveryMaxBackoff  = 1 * time.Hour
+	deliveryMaxAttempts = 24
+)
+
+// deliveryBatchSize limits how many outstanding event deliveries are
+// claimed for a single delivery run
+const deliveryBatchSize = 256
+
+// deliveryClaimLease is the time after which a claimed delivery whose
+// attempt was never recorded is due again, ie. because the eye
+// instance performing it was terminated
+const deliveryClaimLease = 5 * time.Minute
+
+// SubscriptionWrite handles write requests for webhook subscriptions
+type SubscriptionWrite struct {
+	Input           chan msg.Request
+	Shutdown        chan struct{}
+	conn            *sql.DB
+	stmtAdd         *sql.Stmt
+	stmtRemove      *sql.Stmt
+	stmtDeliver     *sql.Stmt
+	stmtClaim       *sql.Stmt
+	stmtAcknowledge *sql.Stmt
+	stmtReschedule  *sql.Stmt
+	appLog          *logrus.Logger
+	reqLog          *logrus.Logger
+	errLog          *logrus.Logger
+}
+
+// newSubscriptionWrite return a new SubscriptionWrite handler with input buffer of length
+func newSubscriptionWrite(length int) (w *SubscriptionWrite) {
+	w = &SubscriptionWrite{}
+	w.Input = make(chan msg.Request, length)
+	w.Shutdown = make(chan struct{})
+	return
+}
+
+// process is the request dispatcher called by Run
+func (w *SubscriptionWrite) process(q *msg.Request) {
+	result := msg.FromRequest(q)
+
+	switch q.Action {
+	case msg.ActionAdd:
+		w.add(q, &result)
+	case msg.ActionRemove:
+		w.remove(q, &result)
+	case msg.ActionNotification:
+		w.deliver(q, &result)
+	case msg.ActionPending:
+		w.claim(q, &result)
+	case msg.ActionAcknowledge:
+		w.acknowledge(q, &result)
+	case msg.ActionReschedule:
+		w.reschedule(q, &result)
+	default:
+		result.UnknownRequest(q)
+	}
+	q.Reply <- result
+}
+
+// add inserts a new webhook subscription
+func (w *SubscriptionWrite) add(q *msg.Request, mr *msg.Result) {
+	var (
+		err          error
+		res          sql.Result
+		action, team sql.NullString
+		hostID       sql.NullInt64
+	)
+
+	// generate SubscriptionID
+	q.Subscription.ID = uuid.Must(uuid.NewV4()).String()
+
+	// generate a signing secret if the client did not provide one
+	if q.Subscription.Secret == `` {
+		secret := make([]byte, 32)
+		if _, err = rand.Read(secret); err != nil {
+			mr.ServerError(err)
+			return
+		}
+		q.Subscription.Secret = hex.EncodeToString(secret)
+	}
+
+	// set NULL-able filter conditions
+	if q.Subscription.Filter.Action != `` {
+		action.String = q.Subscription.Filter.Action
+		action.Valid = true
+	}
+	if q.Subscription.Filter.Team != `` {
+		team.String = q.Subscription.Filter.Team
+		team.Valid = true
+	}
+	if q.Subscription.Filter.HostID != 0 {
+		hostID.Int64 = int64(q.Subscription.Filter.HostID)
+		hostID.Valid = true
+	}
+
+	if res, err = w.stmtAdd.Exec(
+		q.Subscription.ID,
+		q.Subscription.URL,
+		q.Subscription.Secret,
+		q.Subscription.Filter.Section,
+		action,
+		team,
+		hostID,
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	// the secret is only returned to the client once, at creation
+	if mr.ExpectedRows(&res, 1) {
+		mr.Subscription = append(mr.Subscription, q.Subscription)
+	}
+}
+
+// remove deletes a webhook subscription and its delivery history
+func (w *SubscriptionWrite) remove(q *msg.Request, mr *msg.Result) {
+	var (
+		err error
+		res sql.Result
+	)
+
+	if res, err = w.stmtRemove.Exec(
+		q.Subscription.ID,
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	if mr.ExpectedRows(&res, 1) {
+		mr.Subscription = append(mr.Subscription, q.Subscription)
+	}
+}
+
+// deliver records a new event that has to be delivered to a
+// subscription
+func (w *SubscriptionWrite) deliver(q *msg.Request, mr *msg.Result) {
+	var (
+		err error
+		res sql.Result
+	)
+
+	// generate DeliveryID
+	q.Delivery.ID = uuid.Must(uuid.NewV4()).String()
+	q.Delivery.Status = msg.DeliveryPending
+
+	if res, err = w.stmtDeliver.Exec(
+		q.Delivery.ID,
+		q.Delivery.SubscriptionID,
+		q.Delivery.EventID,
+		q.Delivery.EventType,
+		[]byte(q.Delivery.Payload),
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	// 0: the event was already recorded for this subscription
+	if mr.ExpectedRows(&res, 0, 1) {
+		if n, _ := res.RowsAffected(); n == 1 {
+			mr.Delivery = append(mr.Delivery, q.Delivery)
+		}
+	}
+}
+
+// claim returns the event deliveries that are not yet acknowledged and
+// due for another attempt. They are claimed for this eye instance,
+// so that concurrent delivery runs of other instances skip them
+func (w *SubscriptionWrite) claim(q *msg.Request, mr *msg.Result) {
+	var (
+		err  error
+		rows *sql.Rows
+	)
+
+	if rows, err = w.stmtClaim.Query(
+		deliveryBatchSize,
+		deliveryClaimLease.Seconds(),
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+	collectDeliveries(rows, mr)
+}
+
+// acknowledge records the successful delivery of an event
+func (w *SubscriptionWrite) acknowledge(q *msg.Request, mr *msg.Result) {
+	var (
+		err error
+		res sql.Result
+	)
+
+	if res, err = w.stmtAcknowledge.Exec(
+		q.Delivery.ID,
+	); err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	if mr.ExpectedRows(&res, 1) {
+		q.Delivery.Status = msg.DeliveryAcknowledged
+		mr.Delivery = append(mr.Delivery, q.Delivery)
+	}
+}
+
+// reschedule records a failed delivery attempt of an event and
+// schedules its next delivery attempt, unless the event reached the
+// maximum number of attempts
+func (w *SubscriptionWrite) reschedule(q *msg.Request, mr *msg.Result) {
+	var err error
+
+	if err = w.stmtReschedule.QueryRow(
+		q.Delivery.ID,
+		q.Delivery.LastError,
+		deliveryMinBackoff.Seconds(),
+		deliveryMaxBackoff.Seconds(),
+		deliveryMaxAttempts,
+	).Scan(
+		&q.Delivery.Status,
+	); err == sql.ErrNoRows {
+		// delivery was acknowledged by a concurrent attempt
+		mr.OK()
+		return
+	} else if err != nil {
+		mr.ServerError(err)
+		return
+	}
+
+	mr.Delivery = append(mr.Delivery, q.Delivery)
+	mr.OK()
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,70 @@
+/*-
+ * Copyright (c) 2018, 1&1 Internet SE
+ * All rights reserved
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package eye // import "github.com/solnx/eye/internal/eye"
+
+import (
+	"database/sql"
+
+	"github.com/Sirupsen/logrus"
+	msg "github.com/solnx/eye/internal/eye.msg"
+	stmt "github.com/solnx/eye/internal/eye.stmt"
+)
+
+// Implementation of the Handler interface
+
+// Register initializes resources provided by the eye application
+func (w *SubscriptionWrite) Register(c *sql.DB, l ...*logrus.Logger) {
+	w.conn = c
+	w.appLog = l[0]
+	w.reqLog = l[1]
+	w.errLog = l[2]
+}
+
+// Run is the event loop for SubscriptionWrite
+func (w *SubscriptionWrite) Run() {
+	var err error
+
+	for statement, prepStmt := range map[string]*sql.Stmt{
+		stmt.SubscriptionAdd:                 w.stmtAdd,
+		stmt.SubscriptionRemove:              w.stmtRemove,
+		stmt.SubscriptionDeliveryAdd:         w.stmtDeliver,
+		stmt.SubscriptionDeliveryClaim:       w.stmtClaim,
+		stmt.SubscriptionDeliveryAcknowledge: w.stmtAcknowledge,
+		stmt.SubscriptionDeliveryReschedule:  w.stmtReschedule,
+	} {
+		if prepStmt, err = w.conn.Prepare(statement); err != nil {
+			w.errLog.Fatal(`subscription_w`, err, stmt.Name(statement))
+		}
+		defer prepStmt.Close()
+	}
+
+runloop:
+	for {
+		select {
+		case <-w.Shutdown:
+			break runloop
+		case req := <-w.Input:
+			go func() {
+				w.process(&req)
+			}()
+		}
+	}
+}
+
+// ShutdownNow signals the handler to shut down
+func (w *SubscriptionWrite) ShutdownNow() {
+	close(w.Shutdown)
+}
+
+// Intake exposes the Input channel as part of the handler interface
+func (w *SubscriptionWrite) Intake() chan msg.Request {
+	return w.Input
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
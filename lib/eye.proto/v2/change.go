@@ -0,0 +1,31 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package v2 // import "github.com/solnx/eye/lib/eye.proto/v2"
+
+// Change is an entry of the ordered feed of configuration and
+// activation changes. Its ID is the cursor to resume the feed after
+// this change
+type Change struct {
+	ID              int64  `json:"changeID,string"`
+	Section         string `json:"section"`
+	Action          string `json:"action"`
+	ConfigurationID string `json:"configurationID"`
+	LookupID        string `json:"lookupID"`
+	ChangedAt       string `json:"changedAt"`
+}
+
+// NewChangeResult returns a new result
+func NewChangeResult() Result {
+	return Result{
+		Errors:  &[]string{},
+		Changes: &[]Change{},
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
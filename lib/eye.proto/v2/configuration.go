@@ -54,6 +54,9 @@ type MetaInformation struct {
 	ProvisionedAt   string   `json:"provisionedAt"`
 	DeprovisionedAt string   `json:"deprovisionedAt"`
 	Tasks           []string `json:"tasks"`
+	Source          string   `json:"source,omitempty"`
+	Revision        string   `json:"revision,omitempty"`
+	Commit          string   `json:"commit,omitempty"`
 }
 
 // Snapshot is a Configuration at a specific point in time
@@ -0,0 +1,33 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package v2 // import "github.com/solnx/eye/lib/eye.proto/v2"
+
+// Deployment holds the processing record of a SOMA deployment
+type Deployment struct {
+	ID              string `json:"deploymentID" valid:"uuidv4"`
+	Version         uint64 `json:"version,string"`
+	Task            string `json:"task"`
+	CheckInstanceID string `json:"checkInstanceID"`
+	RequestID       string `json:"requestID"`
+	Outcome         string `json:"outcome"`
+	StatusCode      uint16 `json:"statusCode,string,omitempty"`
+	ReceivedAt      string `json:"receivedAt"`
+	ProcessedAt     string `json:"processedAt"`
+}
+
+// NewDeploymentResult returns a new result
+func NewDeploymentResult() Result {
+	return Result{
+		Errors:         &[]string{},
+		Configurations: &[]Configuration{},
+		Deployments:    &[]Deployment{},
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,37 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package v2 // import "github.com/solnx/eye/lib/eye.proto/v2"
+
+// Feedback holds the deployment feedback that eye delivers to SOMA
+// for a processed deployment
+type Feedback struct {
+	ID              string `json:"feedbackID" valid:"uuidv4"`
+	RequestID       string `json:"requestID"`
+	ConfigurationID string `json:"configurationID,omitempty"`
+	Task            string `json:"task"`
+	Outcome         string `json:"outcome"`
+	URL             string `json:"feedbackURL"`
+	Status          string `json:"status"`
+	Attempts        int64  `json:"attempts,string"`
+	LastError       string `json:"lastError,omitempty"`
+	CreatedAt       string `json:"createdAt"`
+	LastAttemptAt   string `json:"lastAttemptAt"`
+	NextAttemptAt   string `json:"nextAttemptAt"`
+	AcknowledgedAt  string `json:"acknowledgedAt"`
+}
+
+// NewFeedbackResult returns a new result
+func NewFeedbackResult() Result {
+	return Result{
+		Errors:    &[]string{},
+		Feedbacks: &[]Feedback{},
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
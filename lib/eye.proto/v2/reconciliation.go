@@ -0,0 +1,59 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package v2 // import "github.com/solnx/eye/lib/eye.proto/v2"
+
+// Reconciliation is the report of a comparison between the
+// deployments known to SOMA and the configurations valid in eye
+type Reconciliation struct {
+	ID                 string   `json:"reconciliationID" valid:"uuidv4"`
+	MonitoringID       string   `json:"monitoringID"`
+	StartedAt          string   `json:"startedAt"`
+	FinishedAt         string   `json:"finishedAt"`
+	Apply              bool     `json:"apply"`
+	DeploymentCount    int      `json:"deploymentCount"`
+	ConfigurationCount int      `json:"configurationCount"`
+	Drift              []Drift  `json:"drift"`
+	Errors             []string `json:"errors,omitempty"`
+}
+
+// Drift is a single difference found during reconciliation
+type Drift struct {
+	Kind            string `json:"kind"`
+	ConfigurationID string `json:"configurationID"`
+	DeploymentID    string `json:"deploymentID,omitempty"`
+	Task            string `json:"task,omitempty"`
+	LookupID        string `json:"lookupID,omitempty"`
+	Applied         bool   `json:"applied"`
+	Error           string `json:"error,omitempty"`
+}
+
+// Kinds of reconciliation drift
+const (
+	// DriftMissing is a SOMA rollout without valid eye configuration
+	DriftMissing = `missing`
+	// DriftOutdated is a valid eye configuration that differs from
+	// its SOMA rollout
+	DriftOutdated = `outdated`
+	// DriftStale is a valid eye configuration for a deprovisioned
+	// SOMA deployment
+	DriftStale = `stale`
+	// DriftUnknown is a valid eye configuration without SOMA
+	// deployment
+	DriftUnknown = `unknown`
+)
+
+// NewReconciliationResult returns a new result
+func NewReconciliationResult() Result {
+	return Result{
+		Errors:          &[]string{},
+		Reconciliations: &[]Reconciliation{},
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -19,6 +19,31 @@ type Registration struct {
 	Port         int64     `json:"port,string"`
 	Database     int64     `json:"database,string"`
 	RegisteredAt time.Time `json:"registeredAt,string"`
+	// LeaseTTL is the number of seconds the registration stays valid
+	// without being renewed, 0 never expires
+	LeaseTTL  int64     `json:"leaseTTL,string,omitempty"`
+	RenewedAt time.Time `json:"renewedAt,string"`
+	// Status is the cache invalidation delivery status, only set
+	// when explicitly requested
+	Status *RegistrationStatus `json:"status,omitempty"`
+}
+
+// RegistrationStatus is the state of cache invalidation delivery to a
+// registered cache, as seen by the answering eye instance
+type RegistrationStatus struct {
+	// Registered is false if the answering eye instance does not
+	// deliver invalidations to the cache
+	Registered  bool   `json:"registered"`
+	Reachable   bool   `json:"reachable"`
+	LastSuccess string `json:"lastSuccess,omitempty"`
+	LastError   string `json:"lastError,omitempty"`
+	LastErrorAt string `json:"lastErrorAt,omitempty"`
+	// Backlog lists the lookupIDs with failed invalidations that are
+	// pending retry
+	Backlog []string `json:"backlog"`
+	// Flushes counts the threshold flushes performed after retries for
+	// an invalidation were exhausted
+	Flushes int `json:"flushes"`
 }
 
 // NewRegistrationRequest returns a new request
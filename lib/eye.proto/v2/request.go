@@ -13,6 +13,7 @@ type Request struct {
 	Flags         *Flags         `json:"flags,omitempty"`
 	Configuration *Configuration `json:"configuration,omitempty"`
 	Registration  *Registration  `json:"registration,omitempty"`
+	Subscription  *Subscription  `json:"subscription,omitempty"`
 }
 
 // Flags contains the flags that a v2 API request can contain
@@ -29,13 +29,21 @@ const (
 
 // Result ...
 type Result struct {
-	StatusCode     uint16           `json:"statusCode"`
-	StatusText     string           `json:"statusText"`
-	Section        string           `json:"section"`
-	Action         string           `json:"action"`
-	Errors         *[]string        `json:"errors,omitempty"`
-	Configurations *[]Configuration `json:"configurations,omitempty"`
-	Registrations  *[]Registration  `json:"registrations,omitempty"`
+	StatusCode      uint16            `json:"statusCode"`
+	StatusText      string            `json:"statusText"`
+	Section         string            `json:"section"`
+	Action          string            `json:"action"`
+	Errors          *[]string         `json:"errors,omitempty"`
+	Configurations  *[]Configuration  `json:"configurations,omitempty"`
+	Registrations   *[]Registration   `json:"registrations,omitempty"`
+	Feedbacks       *[]Feedback       `json:"feedbacks,omitempty"`
+	Subscriptions   *[]Subscription   `json:"subscriptions,omitempty"`
+	Deliveries      *[]Delivery       `json:"deliveries,omitempty"`
+	Changes         *[]Change         `json:"changes,omitempty"`
+	Deployments     *[]Deployment     `json:"deployments,omitempty"`
+	Reconciliations *[]Reconciliation `json:"reconciliations,omitempty"`
+	Syncs           *[]Sync           `json:"syncs,omitempty"`
+	Results         *[]Result         `json:"results,omitempty"`
 }
 
 // SetStatus sets the status code
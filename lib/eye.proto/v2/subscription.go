@@ -0,0 +1,83 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package v2 // import "github.com/solnx/eye/lib/eye.proto/v2"
+
+import "encoding/json"
+
+// CloudEvents specification version used for webhook events
+const CloudEventsSpecVersion = `1.0`
+
+// Subscription holds a webhook that is notified about changes inside
+// eye that match its filter
+type Subscription struct {
+	ID        string             `json:"subscriptionID" valid:"uuidv4,optional"`
+	URL       string             `json:"url" valid:"url"`
+	Secret    string             `json:"secret,omitempty" valid:"optional"`
+	Filter    SubscriptionFilter `json:"filter"`
+	CreatedAt string             `json:"createdAt" valid:"optional"`
+}
+
+// SubscriptionFilter selects the events a Subscription is notified
+// about. Empty fields match every value
+type SubscriptionFilter struct {
+	Section string `json:"section" valid:"in(configuration|registration)"`
+	Action  string `json:"action,omitempty" valid:"optional"`
+	Team    string `json:"team,omitempty" valid:"optional"`
+	HostID  uint64 `json:"hostID,string,omitempty" valid:"optional"`
+}
+
+// Delivery holds the delivery state of an event to a Subscription
+type Delivery struct {
+	ID             string `json:"deliveryID" valid:"uuidv4"`
+	SubscriptionID string `json:"subscriptionID"`
+	EventID        string `json:"eventID"`
+	EventType      string `json:"eventType"`
+	Status         string `json:"status"`
+	Attempts       int64  `json:"attempts,string"`
+	LastError      string `json:"lastError,omitempty"`
+	CreatedAt      string `json:"createdAt"`
+	LastAttemptAt  string `json:"lastAttemptAt"`
+	NextAttemptAt  string `json:"nextAttemptAt"`
+	DeliveredAt    string `json:"deliveredAt"`
+	// fields required for performing the delivery, never exported
+	Payload json.RawMessage `json:"-"`
+	URL     string          `json:"-"`
+	Secret  string          `json:"-"`
+}
+
+// Event is a CloudEvents formatted change notification, using the
+// structured JSON content mode
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	ID              string          `json:"id"`
+	Time            string          `json:"time"`
+	Subject         string          `json:"subject,omitempty"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// NewSubscriptionRequest returns a new request
+func NewSubscriptionRequest() Request {
+	return Request{
+		Subscription: &Subscription{},
+	}
+}
+
+// NewSubscriptionResult returns a new result
+func NewSubscriptionResult() Result {
+	return Result{
+		Errors:        &[]string{},
+		Subscriptions: &[]Subscription{},
+		Deliveries:    &[]Delivery{},
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,41 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package v2 // import "github.com/solnx/eye/lib/eye.proto/v2"
+
+// Sync is the status of the last synchronization of the file based
+// configuration source
+type Sync struct {
+	Directory  string     `json:"directory"`
+	Commit     string     `json:"commit,omitempty"`
+	StartedAt  string     `json:"startedAt"`
+	FinishedAt string     `json:"finishedAt"`
+	Pruned     bool       `json:"pruned"`
+	Files      []SyncFile `json:"files"`
+	Errors     []string   `json:"errors,omitempty"`
+}
+
+// SyncFile is the synchronization status of a single profile file.
+// Removed configurations whose file no longer exists have no Path.
+type SyncFile struct {
+	Path            string `json:"path,omitempty"`
+	ConfigurationID string `json:"configurationID,omitempty"`
+	Revision        string `json:"revision,omitempty"`
+	Action          string `json:"action"`
+	Error           string `json:"error,omitempty"`
+}
+
+// NewSyncResult returns a new result
+func NewSyncResult() Result {
+	return Result{
+		Errors: &[]string{},
+		Syncs:  &[]Sync{},
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -8,19 +8,31 @@
 
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
-import proto "github.com/solnx/eye/lib/eye.proto"
+import (
+	"context"
+
+	proto "github.com/solnx/eye/lib/eye.proto"
+)
 
 // Activate marks a profile as active if l detected an API version that
 // supports profile Activation
 func (l *Lookup) Activate(profileID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return l.ActivateContext(ctx, profileID)
+}
+
+// ActivateContext is Activate with a caller provided context
+func (l *Lookup) ActivateContext(ctx context.Context, profileID string) error {
 	// apiVersion is not initialized, run a quick tasting
-	if l.apiVersion == proto.ProtocolInvalid {
+	if l.APIVersion() == proto.ProtocolInvalid {
 		l.taste(true)
 	}
 
-	switch l.apiVersion {
+	switch l.APIVersion() {
 	case proto.ProtocolTwo:
-		return l.v2ActivateProfile(profileID)
+		return l.v2ActivateProfile(ctx, profileID)
 	}
 
 	return ErrProtocol
@@ -28,14 +40,23 @@ func (l *Lookup) Activate(profileID string) error {
 
 // PendingActivation returns the currently pending activations
 func (l *Lookup) PendingActivation() (*proto.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return l.PendingActivationContext(ctx)
+}
+
+// PendingActivationContext is PendingActivation with a caller provided
+// context
+func (l *Lookup) PendingActivationContext(ctx context.Context) (*proto.Result, error) {
 	// apiVersion is not initialized, run a quick tasting
-	if l.apiVersion == proto.ProtocolInvalid {
+	if l.APIVersion() == proto.ProtocolInvalid {
 		l.taste(true)
 	}
 
-	switch l.apiVersion {
+	switch l.APIVersion() {
 	case proto.ProtocolTwo:
-		return l.v2PendingActivation()
+		return l.v2PendingActivation(ctx)
 	}
 
 	return nil, ErrProtocol
@@ -9,6 +9,7 @@
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -19,31 +20,34 @@ import (
 
 // v2ActivateProfile implements the activation of profileID for API
 // version 2
-func (l *Lookup) v2ActivateProfile(profileID string) error {
+func (l *Lookup) v2ActivateProfile(ctx context.Context, profileID string) error {
 	// check if the profile is already known activated
-	if val, err := l.redis.HGet(
-		`activation`,
-		profileID,
-	).Result(); err != nil {
+	if val, err := l.cache.Activation(ctx, profileID); err != nil && err != ErrNotFound {
 		if l.log != nil {
 			l.log.Errorf("eyewall/cache: %s", err.Error())
 		}
 		return err
-	} else if val != `never` {
+	} else if err == nil && val != `never` {
 		// profile is already marked activated inside the Cache
 		return nil
 	}
 
 	// activate profile in Eye
-	var err error
 	var resp *resty.Response
 
+	ep, err := l.v2Endpoint()
+	if err != nil {
+		return err
+	}
+
 	if resp, err = l.client.R().
+		SetContext(ctx).
 		SetPathParams(map[string]string{
 			`profileID`: profileID,
 		}).Patch(
-		l.eyeActiveURL.String(),
+		ep.activeURL.String(),
 	); err != nil {
+		l.endpointFailed(ctx, ep, err)
 		return fmt.Errorf("eyewall/cache: %s", err.Error())
 	}
 
@@ -52,7 +56,7 @@ func (l *Lookup) v2ActivateProfile(profileID string) error {
 	}
 
 	// update activation in cache
-	return l.v2UpdateCachedActivation(
+	return l.v2UpdateCachedActivation(ctx,
 		profileID,
 		time.Now().UTC().Format(time.RFC3339Nano),
 	)
@@ -61,12 +65,8 @@ func (l *Lookup) v2ActivateProfile(profileID string) error {
 // v2UpdateCachedActivation writes profile activation information into
 // redis. It is intended to be used with information loaded from eye and
 // updates the cache unconditionally.
-func (l *Lookup) v2UpdateCachedActivation(profileID, ts string) error {
-	if _, err := l.redis.HSet(
-		`activation`,
-		profileID,
-		ts,
-	).Result(); err != nil {
+func (l *Lookup) v2UpdateCachedActivation(ctx context.Context, profileID, ts string) error {
+	if err := l.cache.SetActivation(ctx, profileID, ts); err != nil {
 		if l.log != nil {
 			l.log.Errorf("eyewall/cache: %s", err.Error())
 		}
@@ -79,16 +79,22 @@ func (l *Lookup) v2UpdateCachedActivation(profileID, ts string) error {
 // been provisioned but not yet activated by a data receiving component.
 // In this state the server-side of the monitoring profile is ready, but
 // the client-side is still missing.
-func (l *Lookup) v2PendingActivation() (*proto.Result, error) {
-	var err error
+func (l *Lookup) v2PendingActivation(ctx context.Context) (*proto.Result, error) {
 	var resp *resty.Response
 	var r *v2.Result
 
+	ep, err := l.v2Endpoint()
+	if err != nil {
+		return nil, err
+	}
+
 	if resp, err = l.client.R().
+		SetContext(ctx).
 		SetQueryParam(`pending`, `true`).
 		Get(
-			l.eyeActPndURL.String(),
+			ep.actPndURL.String(),
 		); err != nil {
+		l.endpointFailed(ctx, ep, err)
 		return nil, fmt.Errorf("eyewall.v2PendingActivation: %s", err.Error())
 	}
 
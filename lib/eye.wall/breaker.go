@@ -0,0 +1,130 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerFailures is the default number of consecutive
+	// failed requests to eye that open the circuit breaker
+	DefaultBreakerFailures = 5
+	// DefaultBreakerCooldown is the default time the circuit breaker
+	// stays open before a trial request to eye is made
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// breakerState is the state of a circuitBreaker
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker stops requests to eye after consecutive failures.
+// Once the cooldown has passed, a single trial request is let through
+// and its outcome decides whether the breaker closes again.
+type circuitBreaker struct {
+	lock      sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     breakerState
+	openedAt  time.Time
+}
+
+// newCircuitBreaker returns a circuitBreaker that opens after
+// threshold consecutive failures. A threshold of 0 disables it.
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow reports whether a request to eye may be made
+func (b *circuitBreaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		// let a single trial request through
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// trial request is in flight
+		return false
+	default:
+		return true
+	}
+}
+
+// success records a successful request to eye and reports whether it
+// closed the breaker
+func (b *circuitBreaker) success() (recovered bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	recovered = b.state != breakerClosed
+	b.state = breakerClosed
+	b.failures = 0
+	return
+}
+
+// failure records a failed request to eye and reports whether it
+// opened the breaker
+func (b *circuitBreaker) failure() (opened bool) {
+	if b.threshold <= 0 {
+		return false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures++
+	switch b.state {
+	case breakerHalfOpen:
+		// trial request failed
+	case breakerClosed:
+		if b.failures < b.threshold {
+			return false
+		}
+	default:
+		return false
+	}
+	b.state = breakerOpen
+	b.openedAt = time.Now()
+	return true
+}
+
+// release records a request to eye that neither proved eye available
+// nor unavailable. A pending trial request is given up, the next
+// request becomes the new trial.
+func (b *circuitBreaker) release() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,104 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"time"
+)
+
+// Cache is the local storage backend of Lookup. Implementations must
+// return once ctx is done.
+type Cache interface {
+	// Lookup returns the thresholds cached for lookID. It returns
+	// ErrNotFound if lookID is not cached and ErrUnconfigured if
+	// lookID has a negative cache entry.
+	Lookup(ctx context.Context, lookID string) (map[string]Threshold, error)
+	// Version returns the version of the last write-through update
+	// of lookID by eye, or 0
+	Version(ctx context.Context, lookID string) (int64, error)
+	// StoreThreshold adds t to the thresholds cached for lookID. The
+	// write is skipped if the version of lookID is no longer version,
+	// since eye wrote a newer update.
+	StoreThreshold(ctx context.Context, lookID string, t *Threshold, version int64) error
+	// SetUnconfigured writes a negative cache entry for lookID. The
+	// write is skipped if the version of lookID is no longer version.
+	SetUnconfigured(ctx context.Context, lookID string, version int64) error
+	// Invalidate removes lookID and its thresholds from the cache
+	Invalidate(ctx context.Context, lookID string) error
+	// Flush removes all lookIDs and their thresholds from the cache
+	Flush(ctx context.Context) error
+	// Activation returns the cached activation timestamp of
+	// profileID, or ErrNotFound
+	Activation(ctx context.Context, profileID string) (string, error)
+	// SetActivation records the activation timestamp of profileID
+	SetActivation(ctx context.Context, profileID, ts string) error
+	// Heartbeat records the heartbeat ts of key
+	Heartbeat(ctx context.Context, key string, ts time.Time) error
+	// Evaluated records the evaluation of profileID at ts
+	Evaluated(ctx context.Context, profileID string, ts time.Time) error
+	// IncrReceived increments the counter of received metrics
+	IncrReceived(ctx context.Context) error
+	// ResetReceived resets the counter of received metrics
+	ResetReceived(ctx context.Context) error
+	// Close releases the resources held by the cache
+	Close() error
+}
+
+// noopCache is a Cache that stores nothing
+type noopCache struct{}
+
+// NewNoopCache returns a Cache that stores nothing, every lookup is
+// answered by eye
+func NewNoopCache() Cache {
+	return noopCache{}
+}
+
+// Lookup implements Cache
+func (noopCache) Lookup(context.Context, string) (map[string]Threshold, error) {
+	return nil, ErrNotFound
+}
+
+// Version implements Cache
+func (noopCache) Version(context.Context, string) (int64, error) { return 0, nil }
+
+// StoreThreshold implements Cache
+func (noopCache) StoreThreshold(context.Context, string, *Threshold, int64) error { return nil }
+
+// SetUnconfigured implements Cache
+func (noopCache) SetUnconfigured(context.Context, string, int64) error { return nil }
+
+// Invalidate implements Cache
+func (noopCache) Invalidate(context.Context, string) error { return nil }
+
+// Flush implements Cache
+func (noopCache) Flush(context.Context) error { return nil }
+
+// Activation implements Cache
+func (noopCache) Activation(context.Context, string) (string, error) { return ``, ErrNotFound }
+
+// SetActivation implements Cache
+func (noopCache) SetActivation(context.Context, string, string) error { return nil }
+
+// Heartbeat implements Cache
+func (noopCache) Heartbeat(context.Context, string, time.Time) error { return nil }
+
+// Evaluated implements Cache
+func (noopCache) Evaluated(context.Context, string, time.Time) error { return nil }
+
+// IncrReceived implements Cache
+func (noopCache) IncrReceived(context.Context) error { return nil }
+
+// ResetReceived implements Cache
+func (noopCache) ResetReceived(context.Context) error { return nil }
+
+// Close implements Cache
+func (noopCache) Close() error { return nil }
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
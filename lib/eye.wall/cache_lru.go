@@ -0,0 +1,243 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// lruCache is an in-process Cache that holds up to size lookIDs and
+// evicts the least recently used one
+type lruCache struct {
+	lock     sync.Mutex
+	size     int
+	timeout  time.Duration
+	order    *list.List
+	entries  map[string]*list.Element
+	activate map[string]string
+	beats    map[string]time.Time
+	evals    map[string]time.Time
+	received uint64
+}
+
+// lruEntry is the cached state of a single lookID
+type lruEntry struct {
+	lookID       string
+	unconfigured bool
+	thresholds   map[string]Threshold
+	expires      time.Time
+}
+
+// NewLRUCache returns an in-process Cache that holds the thresholds
+// of up to size lookIDs, which expire after timeout
+func NewLRUCache(size int, timeout time.Duration) Cache {
+	if size <= 0 {
+		size = 1
+	}
+	return &lruCache{
+		size:     size,
+		timeout:  timeout,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+		activate: map[string]string{},
+		beats:    map[string]time.Time{},
+		evals:    map[string]time.Time{},
+	}
+}
+
+// Lookup implements Cache
+func (c *lruCache) Lookup(ctx context.Context, lookID string) (map[string]Threshold, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.entries[lookID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, ErrNotFound
+	}
+	c.order.MoveToFront(elem)
+
+	if len(entry.thresholds) == 0 {
+		if entry.unconfigured {
+			return nil, ErrUnconfigured
+		}
+		return nil, ErrNotFound
+	}
+	res := make(map[string]Threshold, len(entry.thresholds))
+	for id, t := range entry.thresholds {
+		res[id] = copyThreshold(t)
+	}
+	return res, nil
+}
+
+// Version implements Cache. Eye does not write into an in-process
+// cache, its version is always 0.
+func (c *lruCache) Version(ctx context.Context, lookID string) (int64, error) {
+	return 0, nil
+}
+
+// StoreThreshold implements Cache
+func (c *lruCache) StoreThreshold(ctx context.Context, lookID string, t *Threshold, version int64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry := c.entry(lookID)
+	entry.thresholds[t.ID] = copyThreshold(*t)
+	return nil
+}
+
+// SetUnconfigured implements Cache
+func (c *lruCache) SetUnconfigured(ctx context.Context, lookID string, version int64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entry(lookID).unconfigured = true
+	return nil
+}
+
+// Invalidate implements Cache
+func (c *lruCache) Invalidate(ctx context.Context, lookID string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[lookID]; ok {
+		c.remove(elem)
+	}
+	return nil
+}
+
+// Flush implements Cache
+func (c *lruCache) Flush(ctx context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.order.Init()
+	c.entries = map[string]*list.Element{}
+	return nil
+}
+
+// Activation implements Cache
+func (c *lruCache) Activation(ctx context.Context, profileID string) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ts, ok := c.activate[profileID]
+	if !ok {
+		return ``, ErrNotFound
+	}
+	return ts, nil
+}
+
+// SetActivation implements Cache
+func (c *lruCache) SetActivation(ctx context.Context, profileID, ts string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.activate[profileID] = ts
+	return nil
+}
+
+// Heartbeat implements Cache
+func (c *lruCache) Heartbeat(ctx context.Context, key string, ts time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.beats[key] = ts
+	return nil
+}
+
+// Evaluated implements Cache
+func (c *lruCache) Evaluated(ctx context.Context, profileID string, ts time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.evals[profileID] = ts
+	return nil
+}
+
+// IncrReceived implements Cache
+func (c *lruCache) IncrReceived(ctx context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.received++
+	return nil
+}
+
+// ResetReceived implements Cache
+func (c *lruCache) ResetReceived(ctx context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.received = 0
+	return nil
+}
+
+// Close implements Cache
+func (c *lruCache) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.order.Init()
+	c.entries = map[string]*list.Element{}
+	return nil
+}
+
+// entry returns the entry for lookID, creating it and evicting the
+// least recently used entry if required. The expiry of the entry is
+// refreshed. The caller must hold c.lock.
+func (c *lruCache) entry(lookID string) *lruEntry {
+	if elem, ok := c.entries[lookID]; ok {
+		entry := elem.Value.(*lruEntry)
+		if time.Now().After(entry.expires) {
+			entry.unconfigured = false
+			entry.thresholds = map[string]Threshold{}
+		}
+		entry.expires = time.Now().Add(c.timeout)
+		c.order.MoveToFront(elem)
+		return entry
+	}
+
+	for c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+	entry := &lruEntry{
+		lookID:     lookID,
+		thresholds: map[string]Threshold{},
+		expires:    time.Now().Add(c.timeout),
+	}
+	c.entries[lookID] = c.order.PushFront(entry)
+	return entry
+}
+
+// remove deletes elem from the cache. The caller must hold c.lock.
+func (c *lruCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*lruEntry).lookID)
+}
+
+// copyThreshold returns a copy of t that does not share its
+// Thresholds map
+func copyThreshold(t Threshold) Threshold {
+	levels := make(map[string]int64, len(t.Thresholds))
+	for lvl, val := range t.Thresholds {
+		levels[lvl] = val
+	}
+	t.Thresholds = levels
+	return t
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,303 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// redisCache is a Cache stored in Redis. Its layout is shared with
+// Invalidation, which modifies it from within eye.
+type redisCache struct {
+	client  *redis.Client
+	timeout time.Duration
+	// calls limits the calls to Redis in progress, including those
+	// whose caller already returned since its ctx was done
+	calls chan struct{}
+}
+
+// redisCallTimeout bounds the network I/O of every call to Redis if
+// opts do not set a read or write timeout
+const redisCallTimeout = 500 * time.Millisecond
+
+// NewRedisCache returns a Cache stored in the Redis described by opts.
+// Cached thresholds expire after timeout.
+func NewRedisCache(opts *redis.Options, timeout time.Duration) (Cache, error) {
+	o := *opts
+	if o.ReadTimeout == 0 {
+		o.ReadTimeout = redisCallTimeout
+	}
+	if o.WriteTimeout == 0 {
+		o.WriteTimeout = redisCallTimeout
+	}
+	if o.PoolSize == 0 {
+		// default of the Redis client
+		o.PoolSize = 10 * runtime.NumCPU()
+	}
+	c := &redisCache{
+		client:  redis.NewClient(&o),
+		timeout: timeout,
+		calls:   make(chan struct{}, o.PoolSize),
+	}
+	if _, err := c.client.Ping().Result(); err != nil {
+		c.client.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Lookup implements Cache
+func (c *redisCache) Lookup(ctx context.Context, lookID string) (map[string]Threshold, error) {
+	res := make(map[string]Threshold)
+	err := c.withContext(ctx, func() error {
+		data, err := c.client.HGetAll(lookID).Result()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return ErrNotFound
+		}
+	dataloop:
+		for key := range data {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if key == `unconfigured` {
+				if len(data) == 1 {
+					return ErrUnconfigured
+				}
+				continue dataloop
+			}
+			val, err := c.client.Get(key).Result()
+			if err != nil {
+				return err
+			}
+
+			t := Threshold{}
+			err = json.Unmarshal([]byte(val), &t)
+			if err != nil {
+				return err
+			}
+			res[t.ID] = t
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Version implements Cache
+func (c *redisCache) Version(ctx context.Context, lookID string) (int64, error) {
+	var version int64
+	err := c.withContext(ctx, func() (err error) {
+		version, err = c.client.Get(versionKey(lookID)).Int64()
+		if err == redis.Nil {
+			version, err = 0, nil
+		}
+		return
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// StoreThreshold implements Cache
+func (c *redisCache) StoreThreshold(ctx context.Context, lookID string, t *Threshold, version int64) error {
+	buf, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	return c.withContext(ctx, func() error {
+		return c.versioned(lookID, version, func(pipe redis.Pipeliner) {
+			pipe.Set(t.ID, string(buf), c.timeout)
+			pipe.HSet(lookID, t.ID, time.Now().UTC().Format(time.RFC3339))
+			pipe.Expire(lookID, c.timeout)
+		})
+	})
+}
+
+// SetUnconfigured implements Cache
+func (c *redisCache) SetUnconfigured(ctx context.Context, lookID string, version int64) error {
+	return c.withContext(ctx, func() error {
+		return c.versioned(lookID, version, func(pipe redis.Pipeliner) {
+			pipe.HSet(lookID, `unconfigured`, time.Now().UTC().Format(time.RFC3339))
+			pipe.Expire(lookID, c.timeout)
+		})
+	})
+}
+
+// versioned runs the writes queued by fn in a transaction unless the
+// version of lookID is no longer version, since the write-through of
+// a newer update by eye must not be overwritten
+func (c *redisCache) versioned(lookID string, version int64, fn func(redis.Pipeliner)) error {
+	err := c.client.Watch(
+		func(tx *redis.Tx) error {
+			current, err := tx.Get(versionKey(lookID)).Int64()
+			if err == redis.Nil {
+				current, err = 0, nil
+			}
+			if err != nil {
+				return err
+			}
+			if current != version {
+				// eye wrote a newer update
+				return nil
+			}
+
+			_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+				fn(pipe)
+				return nil
+			})
+			return err
+		},
+		versionKey(lookID),
+	)
+
+	if err == redis.TxFailedErr {
+		return c.versioned(lookID, version, fn)
+	}
+	return err
+}
+
+// Invalidate implements Cache
+func (c *redisCache) Invalidate(ctx context.Context, lookID string) error {
+	return c.withContext(ctx, func() error {
+		return clearLookup(c.client, lookID)
+	})
+}
+
+// Flush implements Cache
+func (c *redisCache) Flush(ctx context.Context) error {
+	return c.withContext(ctx, func() error {
+		return flushThresholds(c.client)
+	})
+}
+
+// Activation implements Cache
+func (c *redisCache) Activation(ctx context.Context, profileID string) (string, error) {
+	var val string
+	err := c.withContext(ctx, func() (err error) {
+		val, err = c.client.HGet(
+			`activation`,
+			profileID,
+		).Result()
+		return
+	})
+	switch err {
+	case nil:
+		return val, nil
+	case redis.Nil:
+		return ``, ErrNotFound
+	default:
+		return ``, err
+	}
+}
+
+// SetActivation implements Cache
+func (c *redisCache) SetActivation(ctx context.Context, profileID, ts string) error {
+	return c.withContext(ctx, func() error {
+		return c.client.HSet(
+			`activation`,
+			profileID,
+			ts,
+		).Err()
+	})
+}
+
+// Heartbeat implements Cache
+func (c *redisCache) Heartbeat(ctx context.Context, key string, ts time.Time) error {
+	return c.withContext(ctx, func() error {
+		return c.client.HSet(
+			`heartbeat`,
+			key,
+			ts.UTC().Format(time.RFC3339),
+		).Err()
+	})
+}
+
+// Evaluated implements Cache
+func (c *redisCache) Evaluated(ctx context.Context, profileID string, ts time.Time) error {
+	return c.withContext(ctx, func() error {
+		return c.client.HSet(
+			`evaluation`,
+			profileID,
+			ts.UTC().Format(time.RFC3339),
+		).Err()
+	})
+}
+
+// IncrReceived implements Cache
+func (c *redisCache) IncrReceived(ctx context.Context) error {
+	return c.withContext(ctx, func() error {
+		return c.client.Incr(
+			`received_metrics`,
+		).Err()
+	})
+}
+
+// ResetReceived implements Cache
+func (c *redisCache) ResetReceived(ctx context.Context) error {
+	return c.withContext(ctx, func() error {
+		return c.client.Set(
+			`received_metrics`,
+			`0`,
+			0,
+		).Err()
+	})
+}
+
+// Close implements Cache
+func (c *redisCache) Close() error {
+	return c.client.Close()
+}
+
+// withContext runs fn and returns its error, or the error of ctx if
+// ctx is done first. The Redis client can not cancel a call in
+// progress, fn then completes in the background within the timeouts
+// of the client. Calls in progress are limited to the size of the
+// connection pool, so abandoned calls can not pile up under a slow
+// Redis.
+func (c *redisCache) withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	select {
+	case c.calls <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		defer func() { <-c.calls }()
+		errs <- fn()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,64 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/go-resty/resty"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// v2ChangesSince queries the change feed of eye for the changes after
+// the changeID cursor. Eye returns a limited number of changes per
+// request, the feed has to be resumed from the last returned change.
+func (l *Lookup) v2ChangesSince(ctx context.Context, cursor int64) ([]v2.Change, error) {
+	var resp *resty.Response
+	var r *v2.Result
+
+	ep, err := l.v2Endpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	if resp, err = l.client.R().
+		SetContext(ctx).
+		SetQueryParam(`since`, strconv.FormatInt(cursor, 10)).
+		Get(
+			ep.chgURL.String(),
+		); err != nil {
+		l.endpointFailed(ctx, ep, err)
+		return nil, fmt.Errorf("eyewall.v2ChangesSince: %s", err.Error())
+	}
+
+	// error responses must not be mistaken for the end of the feed,
+	// which would skip the sync of the missing changes
+	if resp.StatusCode() < 200 || resp.StatusCode() > 299 {
+		return nil, fmt.Errorf("eyewall.v2ChangesSince: %s",
+			resp.Status())
+	}
+
+	switch r, err = v2Result(resp.Body()); err {
+	case nil:
+	case ErrUnconfigured:
+		// no changes since cursor
+		return []v2.Change{}, nil
+	default:
+		return nil, fmt.Errorf("eyewall.v2ChangesSince: %s", err.Error())
+	}
+
+	if r.Changes == nil {
+		return []v2.Change{}, nil
+	}
+	return *r.Changes, nil
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -8,15 +8,28 @@
 
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
-import proto "github.com/solnx/eye/lib/eye.proto"
+import (
+	"context"
+
+	proto "github.com/solnx/eye/lib/eye.proto"
+)
 
 // ConfigurationShow ...
 func (l *Lookup) ConfigurationShow(profileID string) (*proto.Result, error) {
-	switch l.apiVersion {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return l.ConfigurationShowContext(ctx, profileID)
+}
+
+// ConfigurationShowContext is ConfigurationShow with a caller provided
+// context
+func (l *Lookup) ConfigurationShowContext(ctx context.Context, profileID string) (*proto.Result, error) {
+	switch l.APIVersion() {
 	case proto.ProtocolOne:
-		return l.v1ConfigurationShow(profileID)
+		return l.v1ConfigurationShow(ctx, profileID)
 	case proto.ProtocolTwo:
-		return l.v2ConfigurationShow(profileID)
+		return l.v2ConfigurationShow(ctx, profileID)
 	}
 
 	return nil, ErrProtocol
@@ -9,6 +9,7 @@
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/go-resty/resty"
@@ -17,17 +18,21 @@ import (
 )
 
 // v1ConfigurationShow ...
-func (l *Lookup) v1ConfigurationShow(profileID string) (*proto.Result, error) {
+func (l *Lookup) v1ConfigurationShow(ctx context.Context, profileID string) (*proto.Result, error) {
 	var err error
 	var resp *resty.Response
 	var r *v1.ConfigurationData
 
+	ep := l.endpoint()
+
 	if resp, err = l.client.R().
+		SetContext(ctx).
 		SetPathParams(map[string]string{
 			`profileID`: profileID,
 		}).Get(
-		l.eyeCfgGetURL.String(),
+		ep.cfgGetURL.String(),
 	); err != nil {
+		l.endpointFailed(ctx, ep, err)
 		return nil, fmt.Errorf("eyewall.v1ConfigurationShow: %s", err.Error())
 	}
 
@@ -9,6 +9,7 @@
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/go-resty/resty"
@@ -17,17 +18,23 @@ import (
 )
 
 // v2ConfigurationShow ...
-func (l *Lookup) v2ConfigurationShow(profileID string) (*proto.Result, error) {
-	var err error
+func (l *Lookup) v2ConfigurationShow(ctx context.Context, profileID string) (*proto.Result, error) {
 	var resp *resty.Response
 	var r *v2.Result
 
+	ep, err := l.v2Endpoint()
+	if err != nil {
+		return nil, err
+	}
+
 	if resp, err = l.client.R().
+		SetContext(ctx).
 		SetPathParams(map[string]string{
 			`profileID`: profileID,
 		}).Get(
-		l.eyeCfgGetURL.String(),
+		ep.cfgGetURL.String(),
 	); err != nil {
+		l.endpointFailed(ctx, ep, err)
 		return nil, fmt.Errorf("eyewall.v2ConfigurationShow: %s", err.Error())
 	}
 
@@ -0,0 +1,319 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	proto "github.com/solnx/eye/lib/eye.proto"
+)
+
+const (
+	// endpointBackoff is the time an eye instance is skipped after
+	// its first failure, it doubles with every further failure
+	endpointBackoff = 5 * time.Second
+	// endpointMaxBackoff is the maximum time an eye instance is
+	// skipped after failures
+	endpointMaxBackoff = 5 * time.Minute
+	// endpointTimeout is the maximum time a single request to an eye
+	// instance may take before the instance is considered failed
+	endpointTimeout = 5 * time.Second
+)
+
+// endpoint is an eye instance queried by Lookup. The API version and
+// URLs are set by tasting and not modified afterwards, tasting again
+// replaces the endpoint.
+type endpoint struct {
+	scheme     string
+	host       string
+	port       string
+	apiVersion int
+	lookupURL  *url.URL
+	activeURL  *url.URL
+	regAddURL  *url.URL
+	regDelURL  *url.URL
+	regRenURL  *url.URL
+	regGetURL  *url.URL
+	cfgGetURL  *url.URL
+	actPndURL  *url.URL
+	chgURL     *url.URL
+	// health is shared between all tasted versions of the endpoint
+	// and protected by Lookup.epLock
+	health *endpointHealth
+}
+
+// endpointHealth tracks the failures of an eye instance
+type endpointHealth struct {
+	failures  int
+	downUntil time.Time
+}
+
+// newEndpoint returns an untasted endpoint for the eye instance at
+// host:port, which is reached via scheme
+func newEndpoint(scheme, host, port string) *endpoint {
+	return &endpoint{
+		scheme:     scheme,
+		host:       host,
+		port:       port,
+		apiVersion: proto.ProtocolInvalid,
+		health:     &endpointHealth{},
+	}
+}
+
+// addr returns the address of the eye instance
+func (ep *endpoint) addr() string {
+	return net.JoinHostPort(ep.host, ep.port)
+}
+
+// url returns the URL of path on the eye instance
+func (ep *endpoint) url(path string) *url.URL {
+	u, _ := url.Parse(fmt.Sprintf("%s://%s/%s", ep.scheme, ep.addr(), path))
+	foldSlashes(u)
+	return u
+}
+
+// tasted returns a copy of ep that uses apiVersion
+func (ep *endpoint) tasted(apiVersion int) *endpoint {
+	t := &endpoint{
+		scheme:     ep.scheme,
+		host:       ep.host,
+		port:       ep.port,
+		apiVersion: apiVersion,
+		health:     ep.health,
+	}
+
+	switch apiVersion {
+	case proto.ProtocolOne:
+		t.lookupURL = t.url(`/api/v1/configuration/{lookID}`)
+		t.cfgGetURL = t.url(`/api/v1/item/{profileID}`)
+	case proto.ProtocolTwo:
+		t.lookupURL = t.url(`/api/v2/lookup/configuration/{lookID}`)
+		t.activeURL = t.url(`/api/v2/configuration/{profileID}/active`)
+		t.regAddURL = t.url(`/api/v2/registration/`)
+		t.regDelURL = t.url(`/api/v2/registration/{registrationID}`)
+		t.regRenURL = t.url(`/api/v2/registration/{registrationID}/renew`)
+		t.regGetURL = t.url(`/api/v2/lookup/registration/{app}`)
+		t.cfgGetURL = t.url(`/api/v2/configuration/{profileID}`)
+		t.actPndURL = t.url(`/api/v2/lookup/activation/`)
+		t.chgURL = t.url(`/api/v2/changes`)
+	}
+	return t
+}
+
+// connError wraps errors of requests that did not reach an eye
+// instance
+type connError struct {
+	err error
+}
+
+// Error implements error
+func (e *connError) Error() string {
+	return e.err.Error()
+}
+
+// SetEndpoints sets the eye instances queried by Lookup as list of
+// host:port addresses in order of preference. It replaces the instance
+// from the configuration and must be called before Start.
+func (l *Lookup) SetEndpoints(addrs ...string) error {
+	endpoints := make([]*endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("eyewall.SetEndpoints: %s", err.Error())
+		}
+		endpoints = append(endpoints, newEndpoint(l.scheme, host, port))
+	}
+	if len(endpoints) == 0 {
+		return fmt.Errorf(`eyewall.SetEndpoints: no endpoints`)
+	}
+
+	l.epLock.Lock()
+	l.endpoints = endpoints
+	l.current = endpoints[0]
+	l.epLock.Unlock()
+	return nil
+}
+
+// SetSRV configures Lookup to discover the eye instances via the DNS
+// SRV record name, ie. _eye._tcp.example.com. Instances are ordered by
+// priority and weight of the records. The record is resolved by Start
+// and again if no known instance is available. It must be called
+// before Start.
+func (l *Lookup) SetSRV(name string) {
+	l.srvName = name
+}
+
+// endpoint returns the eye instance requests are sent to
+func (l *Lookup) endpoint() *endpoint {
+	l.epLock.RLock()
+	defer l.epLock.RUnlock()
+
+	return l.current
+}
+
+// v2Endpoint returns the eye instance requests are sent to if it
+// supports API version 2
+func (l *Lookup) v2Endpoint() (*endpoint, error) {
+	ep := l.endpoint()
+	if ep.apiVersion != proto.ProtocolTwo {
+		return nil, ErrProtocol
+	}
+	return ep, nil
+}
+
+// candidates returns the eye instances in order of preference. Unless
+// all is set, instances skipped due to recent failures are omitted.
+func (l *Lookup) candidates(all bool) []*endpoint {
+	l.epLock.RLock()
+	defer l.epLock.RUnlock()
+
+	now := time.Now()
+	res := []*endpoint{}
+	for _, ep := range l.endpoints {
+		if all || !now.Before(ep.health.downUntil) {
+			res = append(res, ep)
+		}
+	}
+	return res
+}
+
+// use records the successful tasting of ep and makes it the instance
+// requests are sent to
+func (l *Lookup) use(ep *endpoint) {
+	l.epLock.Lock()
+	defer l.epLock.Unlock()
+
+	ep.health.failures = 0
+	ep.health.downUntil = time.Time{}
+	for i := range l.endpoints {
+		if l.endpoints[i].addr() == ep.addr() {
+			l.endpoints[i] = ep
+		}
+	}
+	l.current = ep
+}
+
+// markDown records a failure of ep, which is then skipped for an
+// exponentially growing time
+func (l *Lookup) markDown(ep *endpoint) {
+	l.epLock.Lock()
+	defer l.epLock.Unlock()
+
+	backoff := endpointBackoff << uint(ep.health.failures)
+	if backoff > endpointMaxBackoff || backoff <= 0 {
+		backoff = endpointMaxBackoff
+	}
+	ep.health.failures++
+	ep.health.downUntil = time.Now().Add(backoff)
+}
+
+// attemptContext returns the context for a single request to an eye
+// instance, which may take its share of the time left in ctx for the
+// given number of attempts, but no longer than endpointTimeout
+func attemptContext(ctx context.Context, attempts int) (context.Context, context.CancelFunc) {
+	timeout := endpointTimeout
+	if deadline, ok := ctx.Deadline(); ok && attempts > 0 {
+		if share := time.Until(deadline) / time.Duration(attempts); share < timeout {
+			timeout = share
+		}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// endpointFailed records a connection error to ep and fails over to
+// the next available eye instance if ep was in use. Errors caused by
+// the caller canceling ctx are ignored, since they are not a failure
+// of ep. A request that timed out is counted as failure of ep.
+func (l *Lookup) endpointFailed(ctx context.Context, ep *endpoint, err error) {
+	if ctx.Err() == context.Canceled {
+		return
+	}
+
+	l.markDown(ep)
+	if l.log != nil {
+		l.log.Warnf("eyewall/endpoint: %s failed: %s", ep.addr(), err.Error())
+	}
+
+	if l.endpoint().addr() != ep.addr() {
+		// already failed over
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&l.failingOver, 0, 1) {
+		// failover is in progress
+		return
+	}
+	defer atomic.StoreInt32(&l.failingOver, 0)
+
+	candidates := l.candidates(false)
+	if len(candidates) == 0 && l.srvName != `` {
+		l.resolve()
+		candidates = l.candidates(false)
+	}
+	for _, c := range candidates {
+		if l.tasteEndpoint(c, true) {
+			if l.log != nil {
+				l.log.Infof("eyewall/endpoint: failed over to %s", c.addr())
+			}
+			return
+		}
+	}
+}
+
+// resolve updates the eye instances from the DNS SRV record. The
+// health of already known instances is kept.
+func (l *Lookup) resolve() {
+	_, records, err := net.LookupSRV(``, ``, l.srvName)
+	if err != nil {
+		if l.log != nil {
+			l.log.Errorf("eyewall/endpoint: %s", err.Error())
+		}
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	l.epLock.Lock()
+	defer l.epLock.Unlock()
+
+	known := map[string]*endpoint{}
+	for _, ep := range l.endpoints {
+		known[ep.addr()] = ep
+	}
+
+	endpoints := make([]*endpoint, 0, len(records))
+	for _, rec := range records {
+		ep := newEndpoint(
+			l.scheme,
+			strings.TrimSuffix(rec.Target, `.`),
+			strconv.Itoa(int(rec.Port)),
+		)
+		if prev, ok := known[ep.addr()]; ok {
+			ep = prev
+		}
+		endpoints = append(endpoints, ep)
+	}
+	l.endpoints = endpoints
+
+	for _, ep := range endpoints {
+		if ep.addr() == l.current.addr() {
+			return
+		}
+	}
+	l.current = endpoints[0]
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
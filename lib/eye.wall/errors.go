@@ -28,6 +28,15 @@ var (
 	// ErrProtocol is returned if the application attempted an action
 	// that is not supported by the used protocol version
 	ErrProtocol = errors.New(`eyewall.Lookup: request unsupported by protocol version`)
+	// ErrMalformed is returned if Eye rejected the requested LookupID
+	// as malformed
+	ErrMalformed = errors.New(`eyewall.Lookup: malformed LookupID`)
+	// ErrPredicate is returned if a Threshold is evaluated that uses
+	// a predicate not supported by Eye
+	ErrPredicate = errors.New(`eyewall.Evaluate: unsupported predicate`)
+	// ErrLevel is returned if a Threshold is evaluated that contains a
+	// level which is not a number
+	ErrLevel = errors.New(`eyewall.Evaluate: malformed threshold level`)
 )
 
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,127 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Evaluation is the result of evaluating a metric value against a
+// Threshold
+type Evaluation struct {
+	// ID is the ConfigurationID of the evaluated Threshold
+	ID string
+	// Value and Timestamp are the evaluated metric value and the time
+	// it was measured at
+	Value     float64
+	Timestamp time.Time
+	// Predicate is the comparison used for the evaluation
+	Predicate string
+	// Breached is set if value breached at least one level
+	Breached bool
+	// Level is the highest breached level and Threshold the value
+	// configured for it. Both are zero if no level was breached.
+	Level     uint16
+	Threshold int64
+	// Stale is set if the evaluated Threshold was served stale
+	Stale bool
+}
+
+// Evaluate compares value, measured at ts, against all levels of t
+// and returns the highest breached level. The value is the left hand
+// side of the comparison, ie. predicate > with a level value of 90
+// is breached by a value of 95.
+func (t Threshold) Evaluate(value float64, ts time.Time) (Evaluation, error) {
+	ev := Evaluation{
+		ID:        t.ID,
+		Value:     value,
+		Timestamp: ts,
+		Predicate: t.Predicate,
+		Stale:     t.Stale,
+	}
+
+	for lvl, thr := range t.Thresholds {
+		level, err := strconv.ParseUint(lvl, 10, 16)
+		if err != nil {
+			return Evaluation{}, ErrLevel
+		}
+
+		breached, err := compare(t.Predicate, value, thr)
+		if err != nil {
+			return Evaluation{}, err
+		}
+		if !breached || (ev.Breached && uint16(level) <= ev.Level) {
+			continue
+		}
+		ev.Breached = true
+		ev.Level = uint16(level)
+		ev.Threshold = thr
+	}
+	return ev, nil
+}
+
+// compare applies predicate to value and thr
+func compare(predicate string, value float64, thr int64) (bool, error) {
+	v := float64(thr)
+	switch predicate {
+	case `<`:
+		return value < v, nil
+	case `<=`:
+		return value <= v, nil
+	case `==`:
+		return value == v, nil
+	case `>=`:
+		return value >= v, nil
+	case `>`:
+		return value > v, nil
+	case `!=`:
+		return value != v, nil
+	}
+	return false, ErrPredicate
+}
+
+// Evaluate looks up the thresholds for lookID, evaluates value
+// measured at ts against them and records their evaluation. The
+// results are sorted by ConfigurationID.
+func (l *Lookup) Evaluate(lookID string, value float64, ts time.Time) ([]Evaluation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return l.EvaluateContext(ctx, lookID, value, ts)
+}
+
+// EvaluateContext is Evaluate with a caller provided context
+func (l *Lookup) EvaluateContext(ctx context.Context, lookID string, value float64, ts time.Time) ([]Evaluation, error) {
+	thresh, err := l.processRequest(ctx, lookID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Evaluation, 0, len(thresh))
+	for _, t := range thresh {
+		ev, err := t.Evaluate(value, ts)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ev)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	for _, ev := range res {
+		l.EvaluatedContext(ctx, ev.ID)
+	}
+	return res, nil
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,115 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThresholdEvaluate(t *testing.T) {
+	ts := time.Now().UTC()
+	levels := map[string]int64{`1`: 80, `2`: 90, `3`: 95}
+
+	tests := []struct {
+		name      string
+		predicate string
+		value     float64
+		breached  bool
+		level     uint16
+		threshold int64
+	}{
+		{`greater no breach`, `>`, 80, false, 0, 0},
+		{`greater lowest level`, `>`, 85, true, 1, 80},
+		{`greater highest level`, `>`, 99, true, 3, 95},
+		{`greater or equal boundary`, `>=`, 90, true, 2, 90},
+		{`less highest level`, `<`, 50, true, 3, 95},
+		{`less lowest level`, `<`, 94, true, 3, 95},
+		{`less no breach`, `<`, 95, false, 0, 0},
+		{`less or equal boundary`, `<=`, 80, true, 3, 95},
+		{`equal`, `==`, 90, true, 2, 90},
+		{`equal no breach`, `==`, 91, false, 0, 0},
+		{`not equal`, `!=`, 90, true, 3, 95},
+	}
+
+	for _, tc := range tests {
+		thr := Threshold{
+			ID:         `profile`,
+			Predicate:  tc.predicate,
+			Thresholds: levels,
+		}
+		ev, err := thr.Evaluate(tc.value, ts)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err.Error())
+			continue
+		}
+		if ev.Breached != tc.breached || ev.Level != tc.level ||
+			ev.Threshold != tc.threshold {
+			t.Errorf("%s: got breached=%t level=%d threshold=%d, want breached=%t level=%d threshold=%d",
+				tc.name, ev.Breached, ev.Level, ev.Threshold,
+				tc.breached, tc.level, tc.threshold)
+		}
+		if ev.ID != `profile` || ev.Value != tc.value ||
+			!ev.Timestamp.Equal(ts) || ev.Predicate != tc.predicate {
+			t.Errorf("%s: evaluation does not describe its input: %+v",
+				tc.name, ev)
+		}
+	}
+}
+
+func TestThresholdEvaluateStale(t *testing.T) {
+	thr := Threshold{
+		Predicate:  `>`,
+		Thresholds: map[string]int64{`1`: 10},
+		Stale:      true,
+	}
+	ev, err := thr.Evaluate(20, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if !ev.Stale {
+		t.Error("evaluation of a stale threshold is not marked stale")
+	}
+}
+
+func TestThresholdEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		predicate string
+		levels    map[string]int64
+		err       error
+	}{
+		{`unsupported predicate`, `=~`, map[string]int64{`1`: 10}, ErrPredicate},
+		{`non-numeric level`, `>`, map[string]int64{`warning`: 10}, ErrLevel},
+		{`level out of range`, `>`, map[string]int64{`70000`: 10}, ErrLevel},
+	}
+
+	for _, tc := range tests {
+		thr := Threshold{
+			Predicate:  tc.predicate,
+			Thresholds: tc.levels,
+		}
+		if _, err := thr.Evaluate(20, time.Now()); err != tc.err {
+			t.Errorf("%s: got error %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestThresholdEvaluateNoLevels(t *testing.T) {
+	thr := Threshold{Predicate: `>`}
+	ev, err := thr.Evaluate(20, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if ev.Breached {
+		t.Error("threshold without levels was breached")
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
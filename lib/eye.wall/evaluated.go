@@ -9,17 +9,19 @@
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
 import (
+	"context"
 	"time"
 )
 
 // Evaluated updates the timestamp for the evaluation of ID inside
-// the local Redis cache
+// the local cache
 func (l *Lookup) Evaluated(ID string) {
-	if _, err := l.redis.HSet(
-		`evaluation`,
-		ID,
-		time.Now().UTC().Format(time.RFC3339),
-	).Result(); err != nil {
+	l.EvaluatedContext(context.Background(), ID)
+}
+
+// EvaluatedContext is Evaluated with a caller provided context
+func (l *Lookup) EvaluatedContext(ctx context.Context, ID string) {
+	if err := l.cache.Evaluated(ctx, ID, time.Now().UTC()); err != nil {
 		if l.log != nil {
 			l.log.Errorf("eyewall/evaluated: %s", err.Error())
 		}
@@ -0,0 +1,101 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"sync"
+)
+
+// flightGroup deduplicates concurrent requests to eye for the same
+// lookID
+type flightGroup struct {
+	lock  sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is a request to eye in flight
+type flightCall struct {
+	done chan struct{}
+	thr  map[string]Threshold
+	err  error
+	dups int
+}
+
+// do runs fn for lookID unless a call for lookID is already in flight,
+// in which case its result is shared. shared reports whether the
+// result was shared. fn runs with a context that carries the deadline
+// of ctx, but is not canceled with it since other callers may wait for
+// the result. If ctx is done first, its error is returned. A deadline
+// of ctx that runs out is returned as *eyeError since eye failed to
+// answer in time.
+func (g *flightGroup) do(ctx context.Context, lookID string, fn func(context.Context) (map[string]Threshold, error)) (thr map[string]Threshold, err error, shared bool) {
+	g.lock.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	c, shared := g.calls[lookID]
+	if shared {
+		c.dups++
+	} else {
+		c = &flightCall{done: make(chan struct{})}
+		g.calls[lookID] = c
+		go g.run(ctx, lookID, c, fn)
+	}
+	g.lock.Unlock()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, &eyeError{err: ctx.Err()}, shared
+		}
+		return nil, ctx.Err(), shared
+	}
+
+	if c.thr == nil {
+		return nil, c.err, shared
+	}
+	// every caller receives its own copy
+	thr = make(map[string]Threshold, len(c.thr))
+	for id, t := range c.thr {
+		thr[id] = copyThreshold(t)
+	}
+	return thr, c.err, shared
+}
+
+// joined returns the number of callers that joined the call for
+// lookID in flight, or -1 if no call for lookID is in flight
+func (g *flightGroup) joined(lookID string) int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if c, ok := g.calls[lookID]; ok {
+		return c.dups
+	}
+	return -1
+}
+
+// run executes fn for the flightCall c
+func (g *flightGroup) run(ctx context.Context, lookID string, c *flightCall, fn func(context.Context) (map[string]Threshold, error)) {
+	fctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if deadline, ok := ctx.Deadline(); ok {
+		fctx, cancel = context.WithDeadline(fctx, deadline)
+	}
+	defer cancel()
+
+	c.thr, c.err = fn(fctx)
+
+	g.lock.Lock()
+	delete(g.calls, lookID)
+	g.lock.Unlock()
+	close(c.done)
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,238 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightGroupCoalesces(t *testing.T) {
+	g := flightGroup{}
+	release := make(chan struct{})
+	var calls int32
+
+	fn := func(context.Context) (map[string]Threshold, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return map[string]Threshold{
+			`profile`: {ID: `profile`, Thresholds: map[string]int64{`1`: 10}},
+		}, nil
+	}
+
+	const callers = 10
+	var started, wg sync.WaitGroup
+	results := make([]map[string]Threshold, callers)
+	shared := make([]bool, callers)
+	errs := make([]error, callers)
+	started.Add(callers)
+	wg.Add(callers)
+	for i := 0; i < callers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			started.Done()
+			results[i], errs[i], shared[i] = g.do(context.Background(), `lookID`, fn)
+		}(i)
+	}
+	started.Wait()
+	waitJoined(t, &g, `lookID`, callers-1)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("fn was called %d times, want 1", n)
+	}
+	sharedCount := 0
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil {
+			t.Errorf("caller %d: unexpected error: %s", i, errs[i].Error())
+		}
+		if results[i][`profile`].Thresholds[`1`] != 10 {
+			t.Errorf("caller %d: unexpected result %+v", i, results[i])
+		}
+		if shared[i] {
+			sharedCount++
+		}
+	}
+	if sharedCount != callers-1 {
+		t.Errorf("%d callers shared the result, want %d", sharedCount, callers-1)
+	}
+
+	// every caller receives its own copy
+	results[0][`profile`].Thresholds[`1`] = 20
+	if results[1][`profile`].Thresholds[`1`] != 10 {
+		t.Error("callers share the same threshold levels")
+	}
+}
+
+func TestFlightGroupSharesErrors(t *testing.T) {
+	g := flightGroup{}
+	errFailed := errors.New(`failed`)
+	release := make(chan struct{})
+
+	fn := func(context.Context) (map[string]Threshold, error) {
+		<-release
+		return nil, errFailed
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i], _ = g.do(context.Background(), `lookID`, fn)
+		}(i)
+	}
+	waitJoined(t, &g, `lookID`, len(errs)-1)
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != errFailed {
+			t.Errorf("caller %d: got error %v, want %v", i, err, errFailed)
+		}
+	}
+}
+
+func TestFlightGroupSequentialCalls(t *testing.T) {
+	g := flightGroup{}
+	var calls int32
+	fn := func(context.Context) (map[string]Threshold, error) {
+		atomic.AddInt32(&calls, 1)
+		return map[string]Threshold{}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err, shared := g.do(context.Background(), `lookID`, fn); err != nil || shared {
+			t.Fatalf("call %d: got error %v and shared %t", i, err, shared)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("fn was called %d times, want 3", n)
+	}
+}
+
+func TestFlightGroupCallerCancel(t *testing.T) {
+	g := flightGroup{}
+	release := make(chan struct{})
+	finished := make(chan error, 1)
+
+	fn := func(fctx context.Context) (map[string]Threshold, error) {
+		<-release
+		// the flight is not canceled with its first caller
+		finished <- fctx.Err()
+		return map[string]Threshold{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		waitJoined(t, &g, `lookID`, 0)
+		cancel()
+	}()
+	if _, err, _ := g.do(ctx, `lookID`, fn); err != context.Canceled {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+
+	close(release)
+	if err := <-finished; err != nil {
+		t.Errorf("flight was canceled with its caller: %s", err.Error())
+	}
+}
+
+func TestFlightGroupDeadline(t *testing.T) {
+	g := flightGroup{}
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	fn := func(fctx context.Context) (map[string]Threshold, error) {
+		if d, ok := fctx.Deadline(); !ok || !d.Equal(deadline) {
+			t.Errorf("flight deadline is %s, want %s", d, deadline)
+		}
+		return map[string]Threshold{}, nil
+	}
+	if _, err, _ := g.do(ctx, `lookID`, fn); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestFlightGroupCallerTimeout(t *testing.T) {
+	g := flightGroup{}
+	release := make(chan struct{})
+	defer close(release)
+
+	fn := func(context.Context) (map[string]Threshold, error) {
+		<-release
+		return map[string]Threshold{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err, _ := g.do(ctx, `lookID`, fn)
+	e, ok := err.(*eyeError)
+	if !ok {
+		t.Fatalf("got error %v, want *eyeError", err)
+	}
+	if e.err != context.DeadlineExceeded {
+		t.Errorf("got wrapped error %v, want %v", e.err, context.DeadlineExceeded)
+	}
+}
+
+func TestProcessRequestServesStaleOnTimeout(t *testing.T) {
+	l := &Lookup{
+		stale:   newStaleStore(DefaultStaleMaxAge, DefaultStaleSize),
+		breaker: newCircuitBreaker(DefaultBreakerFailures, DefaultBreakerCooldown),
+		stats:   &Statistics{},
+	}
+	l.stale.store(`lookID`, map[string]Threshold{
+		`profile`: {ID: `profile`, Thresholds: map[string]int64{`1`: 10}},
+	})
+
+	// eye hangs on the request for lookID
+	release := make(chan struct{})
+	defer close(release)
+	go l.flights.do(context.Background(), `lookID`, func(context.Context) (map[string]Threshold, error) {
+		<-release
+		return nil, &eyeError{err: ErrUnavailable}
+	})
+	waitJoined(t, &l.flights, `lookID`, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	thr, err := l.processRequest(ctx, `lookID`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if thr[`profile`].Thresholds[`1`] != 10 {
+		t.Errorf("unexpected result %+v", thr)
+	}
+	if n := atomic.LoadUint64(&l.stats.StaleServed); n != 1 {
+		t.Errorf("StaleServed is %d, want 1", n)
+	}
+}
+
+// waitJoined waits until n callers joined the call for lookID in
+// flight in g
+func waitJoined(t *testing.T, g *flightGroup, lookID string, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for g.joined(lookID) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d callers did not join the flight for %s", n, lookID)
+		}
+		runtime.Gosched()
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -9,6 +9,7 @@
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -21,8 +22,13 @@ type heartbeatMap struct {
 }
 
 // Heartbeat updates an application heartbeat message inside the
-// Redis cache. handlerNum -1 is reserved.
+// local cache. handlerNum -1 is reserved.
 func (l *Lookup) Heartbeat(appname string, handlerNum int, binTime []byte) {
+	l.HeartbeatContext(context.Background(), appname, handlerNum, binTime)
+}
+
+// HeartbeatContext is Heartbeat with a caller provided context
+func (l *Lookup) HeartbeatContext(ctx context.Context, appname string, handlerNum int, binTime []byte) {
 	ts := time.Time{}
 	if err := ts.UnmarshalBinary(binTime); err != nil {
 		if l.log != nil {
@@ -36,25 +42,21 @@ func (l *Lookup) Heartbeat(appname string, handlerNum int, binTime []byte) {
 
 	if beats.hb[-1].IsZero() || ts.After(beats.hb[-1]) {
 		beats.hb[-1] = ts
-		l.updateRedisHB(-1, fmt.Sprintf(
+		l.updateRedisHB(ctx, -1, fmt.Sprintf(
 			"%s-alive", appname),
 		)
 	}
 	if ts.After(beats.hb[handlerNum]) {
 		beats.hb[handlerNum] = ts
-		l.updateRedisHB(handlerNum, fmt.Sprintf(
+		l.updateRedisHB(ctx, handlerNum, fmt.Sprintf(
 			"%s-alive-%d", appname, handlerNum),
 		)
 	}
 }
 
-// updateRedisHB performs the heartbeat update in Redis
-func (l *Lookup) updateRedisHB(num int, key string) {
-	if _, err := l.redis.HSet(
-		`heartbeat`,
-		key,
-		beats.hb[num].UTC().Format(time.RFC3339),
-	).Result(); err != nil {
+// updateRedisHB performs the heartbeat update in the local cache
+func (l *Lookup) updateRedisHB(ctx context.Context, num int, key string) {
+	if err := l.cache.Heartbeat(ctx, key, beats.hb[num]); err != nil {
 		if l.log != nil {
 			l.log.Errorf("eyewall/heartbeat: %s", err.Error())
 		}
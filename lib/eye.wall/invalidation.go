@@ -10,57 +10,181 @@ package wall // import "github.com/solnx/eye/lib/eye.wall"
 
 import (
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-redis/redis"
 	"github.com/mjolnir42/erebos"
+	"github.com/solnx/eye/lib/eye.proto/v2"
 )
 
 // Invalidation implements the eyewall cache invalidation used by eye
 type Invalidation struct {
-	Config   *erebos.Config
-	Registry map[string]*redis.Client
+	Config *erebos.Config
+	// RetryLimit is the number of retries for a failed invalidation
+	// before the cache is flushed
+	RetryLimit int
+	// RetryBackoff is the delay before the first retry of a failed
+	// invalidation, it doubles with every further retry
+	RetryBackoff time.Duration
+	// WriteTTL is the cache timeout of entries written by Update
+	WriteTTL time.Duration
+	registry map[string]*cacheTarget
+	// publisher and channel are set if invalidations are published
+	// via Redis pub/sub
+	publisher *redis.Client
+	channel   string
 	sync.RWMutex
 }
 
+// cacheTarget is a cache registered for invalidation
+type cacheTarget struct {
+	client   *redis.Client
+	address  string
+	port     int64
+	database int64
+	// unreachable is set if the cache could not be reached and may
+	// have missed invalidations
+	unreachable bool
+	// delivery tracks the invalidations sent to the cache
+	delivery delivery
+}
+
 // NewInvalidation returns a new Invalidation
-func NewInvalidation(conf *erebos.Config) (iv *Invalidation) {
-	iv.Config = conf
-	iv.Registry = make(map[string]*redis.Client)
-	return
+func NewInvalidation(conf *erebos.Config) *Invalidation {
+	return &Invalidation{
+		Config:       conf,
+		RetryLimit:   5,
+		RetryBackoff: time.Second,
+		WriteTTL:     5 * time.Minute,
+		registry:     make(map[string]*cacheTarget),
+	}
 }
 
-// Register adds a new cache to the registry
+// Register adds a new cache to the registry. Caches that can not be
+// reached are registered as unreachable and flushed by Reconnect once
+// they become reachable.
 func (iv *Invalidation) Register(regID, addr string, port, db int64) (err error) {
-	iv.Lock()
-	defer iv.Unlock()
-
-	cl := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", addr, port),
-		Password: iv.Config.Redis.Password,
-		DB:       int(db),
-	})
-	if _, err = cl.Ping().Result(); err != nil {
+	iv.RLock()
+	target, ok := iv.registry[regID]
+	iv.RUnlock()
+	if ok && target.address == addr && target.port == port &&
+		target.database == db {
+		// already registered with identical settings
 		return
 	}
-	iv.Registry[regID] = cl
+
+	target = &cacheTarget{
+		client: redis.NewClient(&redis.Options{
+			Addr:     fmt.Sprintf("%s:%d", addr, port),
+			Password: iv.Config.Redis.Password,
+			DB:       int(db),
+		}),
+		address:  addr,
+		port:     port,
+		database: db,
+		delivery: newDelivery(),
+	}
+	if _, err = target.client.Ping().Result(); err != nil {
+		target.unreachable = true
+		err = fmt.Errorf("eyewall.Register: cache %s unreachable: %s",
+			regID, err.Error())
+	}
+
+	iv.Lock()
+	if prev, found := iv.registry[regID]; found {
+		prev.client.Close()
+	}
+	iv.registry[regID] = target
+	iv.Unlock()
 	return
 }
 
 // Unregister deletes a cache from the registry
 func (iv *Invalidation) Unregister(regID string) {
 	iv.Lock()
-	iv.Registry[regID].Close()
-	delete(iv.Registry, regID)
+	if target, ok := iv.registry[regID]; ok {
+		target.client.Close()
+		delete(iv.registry, regID)
+	}
 	iv.Unlock()
 }
 
-// CloseAll closes all active redis clients in iv.Registry
+// Sync updates the registry to contain exactly the caches in regs,
+// as stored in eye's database. This restores the registry after a
+// restart and picks up registrations made via other eye instances.
+// Errors for unreachable caches are returned, the caches are
+// registered regardless.
+func (iv *Invalidation) Sync(regs []v2.Registration) (errs []error) {
+	known := map[string]bool{}
+	for _, reg := range regs {
+		known[reg.ID] = true
+		if err := iv.Register(
+			reg.ID,
+			reg.Address,
+			reg.Port,
+			reg.Database,
+		); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	iv.RLock()
+	remove := []string{}
+	for regID := range iv.registry {
+		if !known[regID] {
+			remove = append(remove, regID)
+		}
+	}
+	iv.RUnlock()
+
+	for _, regID := range remove {
+		iv.Unregister(regID)
+	}
+	return
+}
+
+// Reconnect checks all unreachable caches. The thresholds of caches
+// that are reachable again are flushed, since they may have missed
+// invalidations while they were unreachable. This also clears their
+// invalidation backlog.
+func (iv *Invalidation) Reconnect() (errs []error) {
+	iv.RLock()
+	unreachable := map[string]*cacheTarget{}
+	for regID, target := range iv.registry {
+		if target.unreachable {
+			unreachable[regID] = target
+		}
+	}
+	iv.RUnlock()
+
+	for regID, target := range unreachable {
+		if err := flushThresholds(target.client); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"eyewall.Reconnect: cache %s unreachable: %s",
+				regID, err.Error()))
+			continue
+		}
+		iv.Lock()
+		target.unreachable = false
+		target.delivery.flushed()
+		iv.Unlock()
+	}
+	return
+}
+
+// CloseAll closes all active redis clients in the registry and the
+// pub/sub publisher
 func (iv *Invalidation) CloseAll() {
 	iv.Lock()
-	for regID := range iv.Registry {
-		iv.Registry[regID].Close()
-		delete(iv.Registry, regID)
+	for regID := range iv.registry {
+		iv.registry[regID].client.Close()
+		delete(iv.registry, regID)
+	}
+	if iv.publisher != nil {
+		iv.publisher.Close()
+		iv.publisher = nil
 	}
 	iv.Unlock()
 }
@@ -71,15 +195,23 @@ func (iv *Invalidation) CloseAll() {
 func (iv *Invalidation) AsyncInvalidate(lookupID string) {
 	go func() {
 		done, errors := iv.Invalidate(lookupID)
-		for {
-			select {
-			case <-errors:
-			case <-done:
-				break
+		Drain(done, errors)
+	}()
+}
+
+// Drain reads the channels returned by Invalidate until done is
+// closed and returns the encountered errors
+func Drain(done chan struct{}, errors chan error) (errs []error) {
+	for {
+		select {
+		case err := <-errors:
+			if err != nil {
+				errs = append(errs, err)
 			}
+		case <-done:
+			return
 		}
-	}()
-	return
+	}
 }
 
 // Invalidate removes lookupID from all registered caches. Errors
@@ -87,38 +219,101 @@ func (iv *Invalidation) AsyncInvalidate(lookupID string) {
 // have been updated.
 // Both channels must be read.
 func (iv *Invalidation) Invalidate(lookupID string) (done chan struct{}, errors chan error) {
+	return iv.deliver(lookupID, func(t *cacheTarget) error {
+		return iv.invalidateCache(t, lookupID)
+	})
+}
+
+// deliver runs fn for lookupID against all registered caches and
+// records the outcome, and publishes the invalidation of lookupID if
+// enabled. Errors encountered are written to errors and done is
+// closed once all caches have been updated.
+func (iv *Invalidation) deliver(lookupID string, fn func(*cacheTarget) error) (done chan struct{}, errors chan error) {
 	iv.RLock()
+	targets := make(map[string]*cacheTarget, len(iv.registry))
+	for cacheID, target := range iv.registry {
+		targets[cacheID] = target
+	}
+	iv.RUnlock()
 
-	go func(done chan struct{}, errors chan error) {
-		defer iv.RUnlock()
+	done = make(chan struct{})
+	errors = make(chan error, len(targets)+1)
+
+	go func() {
 		wg := sync.WaitGroup{}
 
-		for cacheID := range iv.Registry {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := iv.publish(lookupID); err != nil {
+				errors <- fmt.Errorf("eyewall.Invalidate: publish: %s",
+					err.Error())
+			}
+		}()
+
+		for cacheID, target := range targets {
 			wg.Add(1)
-			go func(c, l string) {
+			go func(c string, t *cacheTarget) {
 				defer wg.Done()
 
-				errors <- iv.invalidateCache(c, l)
-			}(cacheID, lookupID)
+				err := fn(t)
+				iv.Lock()
+				t.delivery.record(lookupID, err, iv.RetryBackoff)
+				iv.Unlock()
+				if err != nil {
+					errors <- fmt.Errorf("eyewall.Invalidate: cache %s: %s",
+						c, err.Error())
+				}
+			}(cacheID, target)
 		}
 		wg.Wait()
 		close(done)
-	}(done, errors)
+	}()
 
 	return
 }
 
 // invalidateCache implements removing lookupID from a single cache
-// registered as cacheID
-func (iv *Invalidation) invalidateCache(cacheID, lookupID string) error {
-	iv.RLock()
-	defer iv.RUnlock()
+// target
+func (iv *Invalidation) invalidateCache(target *cacheTarget, lookupID string) error {
+	return clearLookup(target.client, lookupID)
+}
+
+// lookupIDPattern matches the keys of lookupID hashes, which are hex
+// encoded SHA256 sums
+var lookupIDPattern = strings.Repeat(`[0-9a-f]`, 64)
+
+// flushThresholds removes all lookupIDs and their profiles from the
+// cache behind client. The other state kept in the cache, such as
+// activations, heartbeats and evaluations, is left untouched.
+func flushThresholds(client *redis.Client) error {
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(cursor, lookupIDPattern, 1000).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err = clearLookup(client, key); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
 
+// clearLookup removes lookupID and its profiles from the cache behind
+// client
+func clearLookup(client *redis.Client, lookupID string) error {
 	// declare here to enable recursive definition
-	var clear func(string, string) error
+	var clear func(string) error
 
-	clear = func(cache, key string) error {
-		err := iv.Registry[cache].Watch(
+	clear = func(key string) error {
+		err := client.Watch(
 			func(tx *redis.Tx) error {
 				profiles, err := tx.HGetAll(key).Result()
 				if err != nil && err != redis.Nil {
@@ -139,12 +334,12 @@ func (iv *Invalidation) invalidateCache(cacheID, lookupID string) error {
 		)
 
 		if err == redis.TxFailedErr {
-			return clear(cache, key)
+			return clear(key)
 		}
 		return err
 	}
 
-	return clear(cacheID, lookupID)
+	return clear(lookupID)
 }
 
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
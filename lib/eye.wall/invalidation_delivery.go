@@ -0,0 +1,181 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	proto "github.com/solnx/eye/lib/eye.proto"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// delivery tracks the invalidations sent to a single cache. It is
+// protected by the lock of the Invalidation the cache is registered
+// with.
+type delivery struct {
+	lastSuccess time.Time
+	lastError   string
+	lastErrorAt time.Time
+	flushes     int
+	// backlog holds the failed invalidations pending retry, keyed by
+	// lookupID
+	backlog map[string]*pendingInvalidation
+}
+
+// pendingInvalidation is a failed invalidation pending retry
+type pendingInvalidation struct {
+	attempts    int
+	nextAttempt time.Time
+}
+
+// newDelivery returns an initialized delivery
+func newDelivery() delivery {
+	return delivery{
+		backlog: map[string]*pendingInvalidation{},
+	}
+}
+
+// record updates d with the outcome of invalidating lookupID. Failed
+// invalidations are scheduled for retry with exponential backoff
+// starting at backoff.
+func (d *delivery) record(lookupID string, err error, backoff time.Duration) {
+	now := time.Now().UTC()
+	if err == nil {
+		d.lastSuccess = now
+		delete(d.backlog, lookupID)
+		return
+	}
+
+	d.lastError = err.Error()
+	d.lastErrorAt = now
+	pending, ok := d.backlog[lookupID]
+	if !ok {
+		pending = &pendingInvalidation{}
+		d.backlog[lookupID] = pending
+	}
+	pending.attempts++
+	// cap the backoff at 64 times the initial delay
+	shift := uint(pending.attempts - 1)
+	if shift > 6 {
+		shift = 6
+	}
+	pending.nextAttempt = now.Add(backoff << shift)
+}
+
+// flushed clears the backlog of d after the cache has been flushed
+func (d *delivery) flushed() {
+	d.lastSuccess = time.Now().UTC()
+	d.backlog = map[string]*pendingInvalidation{}
+}
+
+// Retry retries all failed invalidations that are due. Caches with an
+// invalidation that failed more than RetryLimit times are flushed
+// instead. Returned errors are informational, the failed deliveries
+// remain scheduled.
+func (iv *Invalidation) Retry() (errs []error) {
+	type retry struct {
+		target   *cacheTarget
+		lookupID []string
+		exceeded bool
+	}
+
+	// collect due invalidations
+	now := time.Now().UTC()
+	due := map[string]*retry{}
+	iv.RLock()
+	for regID, target := range iv.registry {
+		for lookupID, pending := range target.delivery.backlog {
+			if now.Before(pending.nextAttempt) {
+				continue
+			}
+			if _, ok := due[regID]; !ok {
+				due[regID] = &retry{target: target}
+			}
+			if pending.attempts > iv.RetryLimit {
+				due[regID].exceeded = true
+				continue
+			}
+			due[regID].lookupID = append(due[regID].lookupID, lookupID)
+		}
+	}
+	iv.RUnlock()
+
+	for regID, r := range due {
+		if r.exceeded {
+			// escalate to flushing all thresholds of the cache
+			err := flushThresholds(r.target.client)
+			iv.Lock()
+			if err != nil {
+				r.target.unreachable = true
+				r.target.delivery.lastError = err.Error()
+				r.target.delivery.lastErrorAt = time.Now().UTC()
+			} else {
+				r.target.delivery.flushes++
+				r.target.delivery.flushed()
+			}
+			iv.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf(
+					"eyewall.Retry: flushing cache %s: %s",
+					regID, err.Error()))
+			}
+			continue
+		}
+
+		for _, lookupID := range r.lookupID {
+			err := iv.invalidateCache(r.target, lookupID)
+			iv.Lock()
+			r.target.delivery.record(lookupID, err, iv.RetryBackoff)
+			iv.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf(
+					"eyewall.Retry: cache %s: %s",
+					regID, err.Error()))
+			}
+		}
+	}
+	return
+}
+
+// Status returns the invalidation delivery status of the cache
+// registered as regID
+func (iv *Invalidation) Status(regID string) *v2.RegistrationStatus {
+	iv.RLock()
+	defer iv.RUnlock()
+
+	status := &v2.RegistrationStatus{
+		Backlog: []string{},
+	}
+	target, ok := iv.registry[regID]
+	if !ok {
+		return status
+	}
+
+	status.Registered = true
+	status.Reachable = !target.unreachable
+	status.Flushes = target.delivery.flushes
+	status.LastError = target.delivery.lastError
+	if !target.delivery.lastSuccess.IsZero() {
+		status.LastSuccess = target.delivery.lastSuccess.Format(
+			proto.RFC3339Milli)
+	}
+	if !target.delivery.lastErrorAt.IsZero() {
+		status.LastErrorAt = target.delivery.lastErrorAt.Format(
+			proto.RFC3339Milli)
+	}
+	for lookupID := range target.delivery.backlog {
+		status.Backlog = append(status.Backlog, lookupID)
+	}
+	sort.Strings(status.Backlog)
+	return status
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,75 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+	proto "github.com/solnx/eye/lib/eye.proto"
+)
+
+// DefaultInvalidationChannel is the default Redis pub/sub channel for
+// invalidation messages
+const DefaultInvalidationChannel = `eye.invalidation`
+
+// InvalidationMessage is published by eye for every invalidated
+// lookupID
+type InvalidationMessage struct {
+	LookupID    string `json:"lookupID"`
+	PublishedAt string `json:"publishedAt"`
+}
+
+// EnablePublish configures iv to publish all invalidations on channel
+// of the Redis at connect, in addition to invalidating the registered
+// caches. An error is returned if the Redis is currently unreachable,
+// publishing is enabled regardless.
+func (iv *Invalidation) EnablePublish(connect, password string, db int, channel string) (err error) {
+	if channel == `` {
+		channel = DefaultInvalidationChannel
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     connect,
+		Password: password,
+		DB:       db,
+	})
+	_, err = client.Ping().Result()
+
+	iv.Lock()
+	if iv.publisher != nil {
+		iv.publisher.Close()
+	}
+	iv.publisher = client
+	iv.channel = channel
+	iv.Unlock()
+	return
+}
+
+// publish announces the invalidation of lookupID on the pub/sub
+// channel, if enabled
+func (iv *Invalidation) publish(lookupID string) error {
+	iv.RLock()
+	client, channel := iv.publisher, iv.channel
+	iv.RUnlock()
+	if client == nil {
+		return nil
+	}
+
+	buf, err := json.Marshal(&InvalidationMessage{
+		LookupID:    lookupID,
+		PublishedAt: time.Now().UTC().Format(proto.RFC3339Milli),
+	})
+	if err != nil {
+		return err
+	}
+	return client.Publish(channel, string(buf)).Err()
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
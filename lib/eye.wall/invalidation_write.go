@@ -0,0 +1,127 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// versionKey returns the cache key holding the version stamp of the
+// last write-through update for lookupID
+func versionKey(lookupID string) string {
+	return `version:` + lookupID
+}
+
+// Update writes configurations as the complete set of profiles for
+// lookupID into all registered caches, using the format of
+// Lookup.storeThreshold. An empty set of configurations is written as
+// negative cache entry. Caches that already hold a write with a
+// version stamp equal to or newer than version are left unchanged, eye
+// uses the changeID of the change as version. Lookup only refills an
+// entry if its version did not change while eye was queried.
+// Errors encountered are written to errors and done is closed once all
+// caches have been updated.
+// Both channels must be read.
+func (iv *Invalidation) Update(lookupID string, configurations []v2.Configuration, version int64) (done chan struct{}, errors chan error) {
+	thresholds := make([]Threshold, 0, len(configurations))
+	activations := make(map[string]string, len(configurations))
+	for i := range configurations {
+		thresholds = append(thresholds, v2Threshold(&configurations[i]))
+		activations[configurations[i].ID] = configurations[i].ActivatedAt
+	}
+
+	return iv.deliver(lookupID, func(t *cacheTarget) error {
+		return iv.writeCache(t, lookupID, thresholds, activations, version)
+	})
+}
+
+// AsyncUpdate performs Update(lookupID, configurations, version) and
+// handles the returned channels to avoid blocked resources
+func (iv *Invalidation) AsyncUpdate(lookupID string, configurations []v2.Configuration, version int64) {
+	go func() {
+		done, errors := iv.Update(lookupID, configurations, version)
+		Drain(done, errors)
+	}()
+}
+
+// writeCache implements the versioned write of thresholds for lookupID
+// into a single cache target
+func (iv *Invalidation) writeCache(target *cacheTarget, lookupID string, thresholds []Threshold, activations map[string]string, version int64) error {
+	buffers := make(map[string]string, len(thresholds))
+	for i := range thresholds {
+		buf, err := json.Marshal(&thresholds[i])
+		if err != nil {
+			return err
+		}
+		buffers[thresholds[i].ID] = string(buf)
+	}
+	stamp := strconv.FormatInt(version, 10)
+
+	// declare here to enable recursive definition
+	var write func() error
+
+	write = func() error {
+		err := target.client.Watch(
+			func(tx *redis.Tx) error {
+				current, err := tx.Get(versionKey(lookupID)).Int64()
+				if err != nil && err != redis.Nil {
+					return err
+				}
+				if current >= version {
+					// a newer write already happened
+					return nil
+				}
+
+				profiles, err := tx.HGetAll(lookupID).Result()
+				if err != nil && err != redis.Nil {
+					return err
+				}
+
+				now := time.Now().UTC().Format(time.RFC3339)
+				_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+					for profileID := range profiles {
+						if _, ok := buffers[profileID]; !ok {
+							pipe.Del(profileID)
+						}
+					}
+					pipe.Del(lookupID)
+
+					if len(buffers) == 0 {
+						pipe.HSet(lookupID, `unconfigured`, now)
+					}
+					for profileID, buf := range buffers {
+						pipe.Set(profileID, buf, iv.WriteTTL)
+						pipe.HSet(lookupID, profileID, now)
+						pipe.HSet(`activation`, profileID, activations[profileID])
+					}
+					pipe.Expire(lookupID, iv.WriteTTL)
+					pipe.Set(versionKey(lookupID), stamp, iv.WriteTTL)
+					return nil
+				})
+				return err
+			},
+			versionKey(lookupID),
+			lookupID,
+		)
+
+		if err == redis.TxFailedErr {
+			return write()
+		}
+		return err
+	}
+
+	return write()
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
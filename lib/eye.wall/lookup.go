@@ -12,11 +12,12 @@
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -36,6 +37,10 @@ var (
 	beats heartbeatMap
 )
 
+// requestTimeout is the timeout for requests to eye made by the API
+// variants without a context argument
+const requestTimeout = 150 * time.Millisecond
+
 func init() {
 	beats.hb = make(map[int]time.Time)
 
@@ -50,19 +55,38 @@ type Lookup struct {
 	Config       *erebos.Config
 	limit        *limit.Limit
 	log          *logrus.Logger
-	redis        *redis.Client
-	cacheTimeout time.Duration
-	apiVersion   int
-	eyeLookupURL *url.URL
-	eyeActiveURL *url.URL
-	eyeRegAddURL *url.URL
-	eyeRegDelURL *url.URL
-	eyeRegGetURL *url.URL
-	eyeCfgGetURL *url.URL
-	eyeActPndURL *url.URL
+	cache        Cache
+	ownCache     bool
+	endpoints    []*endpoint
+	current      *endpoint
+	epLock       sync.RWMutex
+	srvName      string
+	failingOver  int32
+	scheme       string
+	transport    *eyeTransport
+	secErr       error
+	syncer       syncer
+	flights      flightGroup
 	client       *resty.Client
 	name         string
 	registration string
+	leaseTTL     time.Duration
+	renewStop    chan struct{}
+	regLock      sync.Mutex
+	subClient    *redis.Client
+	subscription *redis.PubSub
+	subLock      sync.Mutex
+	noRegister   bool
+	stale        *staleStore
+	breaker      *circuitBreaker
+	revalidating int32
+	stats        *Statistics
+	snapPath     string
+	snapInterval time.Duration
+	snapStop     chan struct{}
+	fallback     int32
+	recoverStop  chan struct{}
+	recoverDone  chan struct{}
 }
 
 // NewLookup returns a new *Lookup
@@ -72,7 +96,15 @@ func NewLookup(conf *erebos.Config, appName string) *Lookup {
 		limit:  limit.New(conf.Eyewall.ConcurrencyLimit),
 		log:    nil,
 		name:   appName,
+		stale:  newStaleStore(DefaultStaleMaxAge, DefaultStaleSize),
+		breaker: newCircuitBreaker(
+			DefaultBreakerFailures,
+			DefaultBreakerCooldown,
+		),
+		stats:  &Statistics{},
+		scheme: `http`,
 	}
+	l.transport = newEyeTransport(l.limit)
 	// use configured application name if it was set
 	if l.Config.Eyewall.ApplicationName != `` {
 		l.name = l.Config.Eyewall.ApplicationName
@@ -83,58 +115,117 @@ func NewLookup(conf *erebos.Config, appName string) *Lookup {
 		SetDisableWarn(true).
 		SetRedirectPolicy(resty.NoRedirectPolicy()).
 		SetRetryCount(0).
-		OnBeforeRequest(func(cl *resty.Client, rq *resty.Request) error {
-			cl.SetTimeout(150 * time.Millisecond)
-			return nil
-		}).
-		OnBeforeRequest(func(cl *resty.Client, rq *resty.Request) error {
-			l.limit.Start()
-			return nil
-		}).
-		OnAfterResponse(func(cl *resty.Client, rp *resty.Response) error {
-			l.limit.Done()
-			return nil
-		}).
-		OnAfterResponse(func(cl *resty.Client, rp *resty.Response) error {
-			cl.SetTimeout(0)
-			return nil
-		})
+		SetTransport(l.transport)
+	l.current = newEndpoint(
+		l.scheme,
+		l.Config.Eyewall.Host,
+		l.Config.Eyewall.Port,
+	)
+	l.endpoints = []*endpoint{l.current}
+	// errors of the configured security settings are returned by
+	// Start unless they are overridden via SetSecurity
+	l.secErr = l.SetSecurity(securityFromConfig(conf))
 	return l
 }
 
-// Start sets up Lookup and connects to Redis
+// SetCache sets the Cache used by Lookup. It must be called before
+// Start, which otherwise sets up the local Redis cache from the
+// configuration.
+func (l *Lookup) SetCache(c Cache) {
+	l.cache = c
+	l.ownCache = false
+}
+
+// SetStaleServing sets how long Lookup keeps the last known thresholds
+// of up to size lookIDs to serve them while eye is unavailable. A
+// maxAge or size of 0 disables serving stale thresholds. It must be
+// called before Start.
+func (l *Lookup) SetStaleServing(maxAge time.Duration, size int) {
+	l.stale = newStaleStore(maxAge, size)
+}
+
+// SetCircuitBreaker configures Lookup to stop querying eye for
+// cooldown after failures consecutive failed requests. A failures
+// value of 0 disables the circuit breaker. It must be called before
+// Start.
+func (l *Lookup) SetCircuitBreaker(failures int, cooldown time.Duration) {
+	l.breaker = newCircuitBreaker(failures, cooldown)
+}
+
+// Start sets up Lookup and connects to the local cache
 func (l *Lookup) Start() error {
+	if l.secErr != nil {
+		return l.secErr
+	}
 	l.Taste()
 
-	if l.Config.Eyewall.NoLocalRedis {
-		return nil
+	if l.cache == nil {
+		if l.Config.Eyewall.NoLocalRedis {
+			l.cache = NewNoopCache()
+			l.startSnapshots()
+			return nil
+		}
+
+		cache, err := l.newRedisCache()
+		if err != nil {
+			// serve the snapshot if eye is unavailable as well
+			if l.startFallback() {
+				l.startSnapshots()
+				return nil
+			}
+			return err
+		}
+		l.cache = cache
+		l.ownCache = true
 	}
+	l.startSnapshots()
 
-	l.cacheTimeout = time.Duration(
-		l.Config.Redis.CacheTimeout,
-	) * time.Second
-	l.redis = redis.NewClient(&redis.Options{
+	if err := l.resetReceived(context.Background()); err != nil {
+		return err
+	}
+	return l.register()
+}
+
+// newRedisCache connects to the local Redis cache from the
+// configuration
+func (l *Lookup) newRedisCache() (Cache, error) {
+	return NewRedisCache(&redis.Options{
 		Addr:     l.Config.Redis.Connect,
 		Password: l.Config.Redis.Password,
 		DB:       l.Config.Redis.DB,
-	})
-	if _, err := l.redis.Ping().Result(); err != nil {
-		return err
-	}
-	if err := l.resetReceived(); err != nil {
-		return err
+	}, time.Duration(
+		l.Config.Redis.CacheTimeout,
+	)*time.Second)
+}
+
+// register registers the local cache for invalidation with eye unless
+// registration is disabled
+func (l *Lookup) register() error {
+	// eye can only invalidate the Redis cache from the configuration
+	if !l.ownCache || l.noRegister {
+		return nil
 	}
 	return l.Register()
 }
 
-// Close shuts down the Redis connection
+// Close shuts down the local cache
 func (l *Lookup) Close() {
-	if l.Config.Eyewall.NoLocalRedis {
+	l.stopRecovery()
+	l.stopSnapshots()
+	if l.cache == nil {
 		return
 	}
 
-	l.redis.Close()
-	l.Unregister()
+	l.StopSync()
+
+	l.subLock.Lock()
+	l.unsubscribe()
+	l.subLock.Unlock()
+
+	l.cache.Close()
+	if l.ownCache && !l.noRegister {
+		l.Unregister()
+	}
 }
 
 // Taste connects to Eye and checks supported API versions
@@ -143,9 +234,26 @@ func (l *Lookup) Taste() {
 	l.taste(false)
 }
 
-// taste connects to Eye and checks supported API versions. If quick is
-// set, no retries and shorter timeouts are used.
+// taste connects to the eye instances in order of preference and
+// uses the first one with a supported API version. If quick is set, no
+// retries and shorter timeouts are used and instances that recently
+// failed are skipped.
 func (l *Lookup) taste(quick bool) {
+	if !quick && l.srvName != `` {
+		l.resolve()
+	}
+
+	for _, ep := range l.candidates(!quick) {
+		if l.tasteEndpoint(ep, quick) {
+			return
+		}
+	}
+}
+
+// tasteEndpoint checks the supported API versions of the eye instance
+// ep and reports whether a supported version was found. In that case,
+// ep is used for all further requests.
+func (l *Lookup) tasteEndpoint(ep *endpoint, quick bool) bool {
 	var retryCount, timeoutMS int
 	switch quick {
 	case true:
@@ -156,19 +264,12 @@ func (l *Lookup) taste(quick bool) {
 		timeoutMS = 250
 	}
 
+	apiVersion := proto.ProtocolInvalid
+
 versionloop:
 	// protocol version array is preference sorted, first hit wins
-	for _, apiVersion := range []int{proto.ProtocolTwo, proto.ProtocolOne} {
-		eyeURL, err := url.Parse(fmt.Sprintf("http://%s:%s/api?version=%d",
-			l.Config.Eyewall.Host,
-			l.Config.Eyewall.Port,
-			apiVersion,
-		))
-		if err != nil {
-			l.log.Fatalf("eyewall/cache: malformed eye URL: %s", err.Error())
-		}
-
-		foldSlashes(eyeURL)
+	for _, version := range []int{proto.ProtocolTwo, proto.ProtocolOne} {
+		eyeURL := ep.url(fmt.Sprintf("/api?version=%d", version))
 
 		resp, err := resty.New().
 			// set generic client options
@@ -181,21 +282,13 @@ versionloop:
 			SetRetryCount(retryCount).
 			SetRetryWaitTime(500 * time.Millisecond).
 			SetRetryMaxWaitTime(3000 * time.Millisecond).
+			// enforce the concurrency limit
+			SetTransport(l.transport).
 			// reset timeout deadline before every request
 			OnBeforeRequest(func(cl *resty.Client, rq *resty.Request) error {
 				cl.SetTimeout(time.Duration(timeoutMS) * time.Millisecond)
 				return nil
 			}).
-			// enter concurrency limit before performing request
-			OnBeforeRequest(func(cl *resty.Client, rq *resty.Request) error {
-				l.limit.Start()
-				return nil
-			}).
-			// leave concurrency limit after receiving a response
-			OnAfterResponse(func(cl *resty.Client, rp *resty.Response) error {
-				l.limit.Done()
-				return nil
-			}).
 			// clear timeout deadline after each request (http.Client
 			// timeout also cancels reading the response body)
 			OnAfterResponse(func(cl *resty.Client, rp *resty.Response) error {
@@ -204,10 +297,14 @@ versionloop:
 			}).
 			R().Head(eyeURL.String())
 
-		// connection error to eye is NOT fatal, run against cache instead
+		// connection error to eye is NOT fatal, run against cache or
+		// another eye instance instead
 		if err != nil {
-			l.log.Errorf("eyewall/cache: %s", err.Error())
-			break versionloop // stop trying to find a different api version
+			if l.log != nil {
+				l.log.Errorf("eyewall/cache: %s", err.Error())
+			}
+			l.markDown(ep)
+			return false
 		}
 
 		switch resp.StatusCode() {
@@ -221,70 +318,20 @@ versionloop:
 		case http.StatusNotFound: // 404
 			// eye is so old, it does not have the /api endpoint.
 			// This means it is only able to handle ProtocolOne
-			l.apiVersion = proto.ProtocolOne
+			apiVersion = proto.ProtocolOne
 			break versionloop
 		case http.StatusNoContent: // 204
 			// queried version is supported
-			l.apiVersion = apiVersion
+			apiVersion = version
 			break versionloop
 		}
 	}
-	switch l.apiVersion {
-	case proto.ProtocolOne:
-		l.eyeLookupURL, _ = url.Parse(fmt.Sprintf("http://%s:%s/api/v1/configuration/{lookID}",
-			l.Config.Eyewall.Host,
-			l.Config.Eyewall.Port,
-		))
-		foldSlashes(l.eyeLookupURL)
-
-		l.eyeCfgGetURL, _ = url.Parse(fmt.Sprintf("http://%s:%s/api/v1/item/{profileID}",
-			l.Config.Eyewall.Host,
-			l.Config.Eyewall.Port,
-		))
-		foldSlashes(l.eyeCfgGetURL)
-	case proto.ProtocolTwo:
-		l.eyeLookupURL, _ = url.Parse(fmt.Sprintf("http://%s:%s/api/v2/lookup/configuration/{lookID}",
-			l.Config.Eyewall.Host,
-			l.Config.Eyewall.Port,
-		))
-		foldSlashes(l.eyeLookupURL)
-
-		l.eyeActiveURL, _ = url.Parse(fmt.Sprintf("http://%s:%s/api/v2/configuration/{profileID}/active",
-			l.Config.Eyewall.Host,
-			l.Config.Eyewall.Port,
-		))
-		foldSlashes(l.eyeActiveURL)
-
-		l.eyeRegAddURL, _ = url.Parse(fmt.Sprintf("http://%s:%s/api/v2/registration/",
-			l.Config.Eyewall.Host,
-			l.Config.Eyewall.Port,
-		))
-		foldSlashes(l.eyeRegAddURL)
-
-		l.eyeRegDelURL, _ = url.Parse(fmt.Sprintf("http://%s:%s/api/v2/registration/{registrationID}",
-			l.Config.Eyewall.Host,
-			l.Config.Eyewall.Port,
-		))
-		foldSlashes(l.eyeRegDelURL)
-
-		l.eyeRegGetURL, _ = url.Parse(fmt.Sprintf("http://%s:%s/api/v2/lookup/registration/{app}",
-			l.Config.Eyewall.Host,
-			l.Config.Eyewall.Port,
-		))
-		foldSlashes(l.eyeRegGetURL)
-
-		l.eyeCfgGetURL, _ = url.Parse(fmt.Sprintf("http://%s:%s/api/v2/configuration/{profileID}",
-			l.Config.Eyewall.Host,
-			l.Config.Eyewall.Port,
-		))
-		foldSlashes(l.eyeCfgGetURL)
-
-		l.eyeActPndURL, _ = url.Parse(fmt.Sprintf("http://%s:%s/api/v2/lookup/activation/",
-			l.Config.Eyewall.Host,
-			l.Config.Eyewall.Port,
-		))
-		foldSlashes(l.eyeActPndURL)
+
+	if apiVersion == proto.ProtocolInvalid {
+		return false
 	}
+	l.use(ep.tasted(apiVersion))
+	return true
 }
 
 // SetLogger hands Lookup the logger to use
@@ -295,10 +342,19 @@ func (l *Lookup) SetLogger(logger *logrus.Logger) {
 // GetConfigurationID returns matching monitoring profile ConfigurationIDs
 // if any exist.
 func (l *Lookup) GetConfigurationID(lookID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return l.GetConfigurationIDContext(ctx, lookID)
+}
+
+// GetConfigurationIDContext is GetConfigurationID with a caller
+// provided context
+func (l *Lookup) GetConfigurationIDContext(ctx context.Context, lookID string) ([]string, error) {
 	IDList := []string{}
 
 	// try to serve the request from the local redis cache
-	thresh, err := l.processRequest(lookID)
+	thresh, err := l.processRequest(ctx, lookID)
 	switch err {
 	case nil:
 		// success
@@ -319,7 +375,16 @@ func (l *Lookup) GetConfigurationID(lookID string) ([]string, error) {
 // LookupThreshold queries the full monitoring profile data
 // for lookID
 func (l *Lookup) LookupThreshold(lookID string) (map[string]Threshold, error) {
-	return l.processRequest(lookID)
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return l.LookupThresholdContext(ctx, lookID)
+}
+
+// LookupThresholdContext is LookupThreshold with a caller provided
+// context. The request to eye is aborted once ctx is done.
+func (l *Lookup) LookupThresholdContext(ctx context.Context, lookID string) (map[string]Threshold, error) {
+	return l.processRequest(ctx, lookID)
 }
 
 // WaitEye returns a channel that it closes once Eye returns a
@@ -328,23 +393,22 @@ func (l *Lookup) WaitEye() chan struct{} {
 	ret := make(chan struct{})
 	go func(ret chan struct{}) {
 		retryDelay := 50 * time.Millisecond
-		client := &http.Client{}
+		client := &http.Client{Transport: l.transport}
 		for {
 			<-time.After(retryDelay)
 			retryDelay = 5 * time.Second
 
-			req, err := http.NewRequest(`GET`, fmt.Sprintf(
-				"http://%s:%s/api/v1/item/",
-				l.Config.Eyewall.Host,
-				l.Config.Eyewall.Port,
-			), nil)
+			req, err := http.NewRequest(`GET`,
+				l.endpoint().url(`/api/v1/item/`).String(),
+				nil,
+			)
 			if err != nil {
 				continue
 			}
 			var resp *http.Response
 			if resp, err = client.Do(req); err != nil {
-				ioutil.ReadAll(resp.Body)
-				resp.Body.Close()
+				// try the next eye instance
+				l.taste(true)
 				continue
 			}
 			// allow connection reuse
@@ -361,10 +425,11 @@ func (l *Lookup) WaitEye() chan struct{} {
 }
 
 // processRequest handles the multi-stage lookup of querying the
-// cache, the profile server and keeps the cache updated
-func (l *Lookup) processRequest(lookID string) (map[string]Threshold, error) {
+// cache, the profile server and keeps the cache updated. If eye can
+// not be queried, the last known thresholds are served.
+func (l *Lookup) processRequest(ctx context.Context, lookID string) (map[string]Threshold, error) {
 	// fetch from local cache
-	thr, err := l.lookupRedis(lookID)
+	thr, err := l.lookupCache(ctx, lookID)
 	if err == nil {
 		return thr, nil
 	} else if err == ErrUnconfigured {
@@ -377,163 +442,214 @@ func (l *Lookup) processRequest(lookID string) (map[string]Threshold, error) {
 	}
 
 	// local cache did not hit or was not available
-	// fetch from eye
+	// fetch from eye, sharing the request with concurrent lookups
+	// of lookID
+	thr, err, shared := l.flights.do(ctx, lookID, func(fctx context.Context) (map[string]Threshold, error) {
+		atomic.AddUint64(&l.stats.Flights, 1)
+		return l.fetchEye(fctx, lookID)
+	})
+	if shared {
+		atomic.AddUint64(&l.stats.Coalesced, 1)
+	}
+	if e, ok := err.(*eyeError); ok {
+		return l.serveStale(lookID, e.err)
+	}
+	return thr, err
+}
+
+// fetchEye queries eye for lookID unless the circuit breaker is open
+// and records the outcome. Errors indicating that eye is unavailable
+// are returned as *eyeError.
+func (l *Lookup) fetchEye(ctx context.Context, lookID string) (map[string]Threshold, error) {
+	if !l.breaker.allow() {
+		atomic.AddUint64(&l.stats.BreakerRejected, 1)
+		return nil, &eyeError{err: ErrUnavailable}
+	}
 
+	thr, err := l.lookupEye(ctx, lookID)
+	switch err.(type) {
+	case nil:
+		l.eyeSuccess()
+		l.stale.store(lookID, thr)
+		return thr, nil
+	case *eyeError:
+		l.eyeFailure()
+		return nil, err
+	}
+
+	if err == ErrUnconfigured {
+		l.eyeSuccess()
+		l.stale.remove(lookID)
+		return nil, ErrUnconfigured
+	}
+	l.breaker.release()
+	return nil, err
+}
+
+// eyeError wraps errors of requests to eye that indicate that eye is
+// unavailable
+type eyeError struct {
+	err error
+}
+
+// Error implements error
+func (e *eyeError) Error() string {
+	return e.err.Error()
+}
+
+// lookupEye queries eye for lookID and stores the result in the local
+// cache. Errors indicating that eye is unavailable are returned as
+// *eyeError. On connection errors or if the eye instance does not
+// answer in time, the request is repeated against the eye instance
+// that was failed over to.
+func (l *Lookup) lookupEye(ctx context.Context, lookID string) (map[string]Threshold, error) {
+	// an update written by eye while the request is in progress is
+	// newer than its result
+	version := l.cacheVersion(ctx, lookID)
+
+	for attempt := 1; ; attempt++ {
+		ep := l.endpoint()
+		attempts := len(l.candidates(true)) - attempt + 1
+		thr, err := l.lookupEndpoint(ctx, ep, lookID, attempts, version)
+		ce, ok := err.(*connError)
+		if !ok {
+			return thr, err
+		}
+
+		l.endpointFailed(ctx, ep, ce.err)
+		if attempts <= 1 || ctx.Err() != nil ||
+			l.endpoint().addr() == ep.addr() {
+			// no other eye instance is available
+			return nil, l.wrapEyeError(ctx, ce.err)
+		}
+	}
+}
+
+// lookupEndpoint queries the eye instance ep for lookID and stores the
+// result in the local cache unless eye wrote an update newer than
+// version. The request to ep gets its share of the time left for the
+// given number of attempts.
+func (l *Lookup) lookupEndpoint(ctx context.Context, ep *endpoint, lookID string, attempts int, version int64) (map[string]Threshold, error) {
 	// apiVersion is not initialized, run a quick tasting
-	if l.apiVersion == proto.ProtocolInvalid {
+	if ep.apiVersion == proto.ProtocolInvalid {
 		l.taste(true)
+		ep = l.endpoint()
 	}
 
-	switch l.apiVersion {
+	switch ep.apiVersion {
 	case proto.ProtocolInvalid:
 		// apiVersion is still uninitialized, this is now a hard error
 		// since the cache does not have the required data and eye can
 		// not be queried
-		return nil, ErrUnavailable
+		return nil, &eyeError{err: ErrUnavailable}
 
 	case proto.ProtocolOne:
-		cnf, err := l.v1LookupEye(lookID)
-		if err == ErrUnconfigured {
-			return nil, ErrUnconfigured
-		} else if err != nil {
-			return nil, err
+		actx, cancel := attemptContext(ctx, attempts)
+		cnf, err := l.v1LookupEye(actx, ep, lookID, version)
+		cancel()
+		if err != nil {
+			return nil, l.wrapEyeError(ctx, err)
 		}
 
 		// process result from eye and store in redis
-		thr, err = l.v1Process(lookID, cnf)
-		if err == ErrUnconfigured {
-			return nil, ErrUnconfigured
-		} else if err != nil {
-			return nil, err
-		}
+		return l.v1Process(ctx, lookID, cnf, version)
 
 	case proto.ProtocolTwo:
-		res, err := l.v2LookupEye(lookID)
-		if err == ErrUnconfigured {
-			return nil, ErrUnconfigured
-		} else if err != nil {
-			return nil, err
+		actx, cancel := attemptContext(ctx, attempts)
+		res, err := l.v2LookupEye(actx, ep, lookID, version)
+		cancel()
+		if err != nil {
+			return nil, l.wrapEyeError(ctx, err)
 		}
 
 		// process result from eye and store in redis
-		thr, err = l.v2Process(lookID, res)
-		if err == ErrUnconfigured {
-			return nil, ErrUnconfigured
-		} else if err != nil {
-			return nil, err
-		}
+		return l.v2Process(ctx, lookID, res, version)
 
 	default:
-		return nil, fmt.Errorf("eyewall.Lookup: attempted processing for unsupported API version %d", l.apiVersion)
+		return nil, fmt.Errorf("eyewall.Lookup: attempted processing for unsupported API version %d", ep.apiVersion)
 	}
-
-	return thr, nil
 }
 
-// lookupRedis queries the Redis profile cache
-func (l *Lookup) lookupRedis(lookID string) (map[string]Threshold, error) {
-	if l.Config.Eyewall.NoLocalRedis {
-		return nil, ErrNoCache
-	}
-
-	res := make(map[string]Threshold)
-	data, err := l.redis.HGetAll(lookID).Result()
-	if err != nil {
-		return nil, err
+// wrapEyeError returns err as *eyeError unless it was caused by the
+// request itself or the caller canceled ctx
+func (l *Lookup) wrapEyeError(ctx context.Context, err error) error {
+	switch {
+	case err == ErrUnconfigured, err == ErrMalformed:
+		return err
+	case ctx.Err() == context.Canceled:
+		return err
 	}
-	if len(data) == 0 {
-		return nil, ErrNotFound
+	if _, ok := err.(*connError); ok {
+		// connection errors are handled by lookupEye
+		return err
 	}
-dataloop:
-	for key := range data {
-		if key == `unconfigured` {
-			if len(data) == 1 {
-				return nil, ErrUnconfigured
-			}
-			continue dataloop
-		}
-		val, err := l.redis.Get(key).Result()
-		if err != nil {
-			return nil, err
-		}
+	return &eyeError{err: err}
+}
 
-		t := Threshold{}
-		err = json.Unmarshal([]byte(val), &t)
-		if err != nil {
-			return nil, err
-		}
-		res[t.ID] = t
+// eyeSuccess records a successful request to eye and starts the
+// revalidation of stale entries if eye recovered
+func (l *Lookup) eyeSuccess() {
+	if l.breaker.success() {
+		go l.revalidate()
 	}
-	return res, nil
 }
 
-// setUnconfigured writes a negative cache entry into the local cache
-func (l *Lookup) setUnconfigured(lookID string) {
-	if l.Config.Eyewall.NoLocalRedis {
+// eyeFailure records a failed request to eye
+func (l *Lookup) eyeFailure() {
+	if !l.breaker.failure() {
 		return
 	}
-
-	if _, err := l.redis.HSet(
-		lookID,
-		`unconfigured`,
-		time.Now().UTC().Format(time.RFC3339),
-	).Result(); err != nil {
-		if l.log != nil {
-			l.log.Errorf("eyewall/cache: %s", err.Error())
-		}
-		return
+	atomic.AddUint64(&l.stats.BreakerOpened, 1)
+	if l.log != nil {
+		l.log.Warnf("eyewall/lookup: eye unavailable, pausing requests for %s",
+			l.breaker.cooldown.String())
 	}
+}
 
-	if _, err := l.redis.Expire(
-		lookID,
-		l.cacheTimeout,
-	).Result(); err != nil {
-		if l.log != nil {
-			l.log.Errorf("eyewall/cache: %s", err.Error())
-		}
+// lookupCache queries the local profile cache
+func (l *Lookup) lookupCache(ctx context.Context, lookID string) (map[string]Threshold, error) {
+	if l.cache == nil {
+		return nil, ErrNoCache
 	}
+	return l.cache.Lookup(ctx, lookID)
 }
 
-// storeThreshold writes t into the local cache
-func (l *Lookup) storeThreshold(lookID string, t *Threshold) {
-	if l.Config.Eyewall.NoLocalRedis {
-		return
+// cacheVersion returns the version of lookID in the local cache, which
+// has to be read before querying eye for lookID
+func (l *Lookup) cacheVersion(ctx context.Context, lookID string) int64 {
+	if l.cache == nil {
+		return 0
 	}
 
-	buf, err := json.Marshal(t)
-	if err != nil {
-		if l.log != nil {
-			l.log.Errorf("eyewall/cache: %s", err.Error())
-		}
-		return
+	version, err := l.cache.Version(ctx, lookID)
+	if err != nil && l.log != nil {
+		l.log.Errorf("eyewall/cache: %s", err.Error())
 	}
+	return version
+}
 
-	if _, err := l.redis.Set(
-		t.ID,
-		string(buf),
-		l.cacheTimeout,
-	).Result(); err != nil {
-		if l.log != nil {
-			l.log.Errorf("eyewall/cache: %s", err.Error())
-		}
+// setUnconfigured writes a negative cache entry into the local cache
+// unless eye wrote an update newer than version
+func (l *Lookup) setUnconfigured(ctx context.Context, lookID string, version int64) {
+	if l.cache == nil {
 		return
 	}
 
-	if _, err := l.redis.HSet(
-		lookID,
-		t.ID,
-		time.Now().UTC().Format(time.RFC3339),
-	).Result(); err != nil {
+	if err := l.cache.SetUnconfigured(ctx, lookID, version); err != nil {
 		if l.log != nil {
 			l.log.Errorf("eyewall/cache: %s", err.Error())
 		}
+	}
+}
+
+// storeThreshold writes t into the local cache unless eye wrote an
+// update newer than version
+func (l *Lookup) storeThreshold(ctx context.Context, lookID string, t *Threshold, version int64) {
+	if l.cache == nil {
 		return
 	}
 
-	if _, err := l.redis.Expire(
-		lookID,
-		l.cacheTimeout,
-	).Result(); err != nil {
+	if err := l.cache.StoreThreshold(ctx, lookID, t, version); err != nil {
 		if l.log != nil {
 			l.log.Errorf("eyewall/cache: %s", err.Error())
 		}
@@ -541,8 +657,9 @@ func (l *Lookup) storeThreshold(lookID string, t *Threshold) {
 }
 
 // APIVersion returns the Eye API version discovered via taste testing
+// of the eye instance currently in use
 func (l *Lookup) APIVersion() int {
-	return l.apiVersion
+	return l.endpoint().apiVersion
 }
 
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -10,6 +10,7 @@
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -19,29 +20,29 @@ import (
 	"github.com/solnx/eye/lib/eye.proto/v1"
 )
 
-// v1LookupEye queries the Eye monitoring profile server
-func (l *Lookup) v1LookupEye(lookID string) (*v1.ConfigurationData, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest(`GET`, fmt.Sprintf(
-		"http://%s:%s/%s/%s",
-		l.Config.Eyewall.Host,
-		l.Config.Eyewall.Port,
+// v1LookupEye queries the Eye monitoring profile server ep
+func (l *Lookup) v1LookupEye(ctx context.Context, ep *endpoint, lookID string, version int64) (*v1.ConfigurationData, error) {
+	client := &http.Client{Transport: l.transport}
+	req, err := http.NewRequest(`GET`, ep.url(fmt.Sprintf(
+		"/%s/%s",
 		l.Config.Eyewall.Path,
 		lookID,
-	), nil)
+	)).String(), nil)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 
 	var resp *http.Response
-	defer resp.Body.Close()
-	l.limit.Start()
 	if resp, err = client.Do(req); err != nil {
-		return nil, err
-	} else if resp.StatusCode == 400 {
-		return nil, fmt.Errorf(`Lookup: malformed LookupID`)
+		return nil, &connError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 400 {
+		return nil, ErrMalformed
 	} else if resp.StatusCode == 404 {
-		l.setUnconfigured(lookID)
+		l.setUnconfigured(ctx, lookID, version)
 		return nil, ErrUnconfigured
 	} else if resp.StatusCode >= 500 {
 		return nil, fmt.Errorf(
@@ -49,7 +50,6 @@ func (l *Lookup) v1LookupEye(lookID string) (*v1.ConfigurationData, error) {
 			resp.StatusCode,
 		)
 	}
-	l.limit.Done()
 	var buf []byte
 	buf, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -65,13 +65,14 @@ func (l *Lookup) v1LookupEye(lookID string) (*v1.ConfigurationData, error) {
 }
 
 // v1Process converts t into Threshold and stores it in the
-// local cache if available
-func (l *Lookup) v1Process(lookID string, t *v1.ConfigurationData) (map[string]Threshold, error) {
+// local cache if available, unless eye wrote an update newer than
+// version
+func (l *Lookup) v1Process(ctx context.Context, lookID string, t *v1.ConfigurationData, version int64) (map[string]Threshold, error) {
 	if t.Configurations == nil {
 		return nil, fmt.Errorf(`lookup.process received t.Configurations == nil`)
 	}
 	if len(t.Configurations) == 0 {
-		l.setUnconfigured(lookID)
+		l.setUnconfigured(ctx, lookID, version)
 		return nil, ErrUnconfigured
 	}
 	res := make(map[string]Threshold)
@@ -93,7 +94,7 @@ func (l *Lookup) v1Process(lookID string, t *v1.ConfigurationData) (map[string]T
 			t.Predicate = tl.Predicate
 			t.Thresholds[lvl] = tl.Value
 		}
-		l.storeThreshold(lookID, &t)
+		l.storeThreshold(ctx, lookID, &t, version)
 		res[t.ID] = t
 	}
 	return res, nil
@@ -9,6 +9,7 @@
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -17,23 +18,28 @@ import (
 	"github.com/solnx/eye/lib/eye.proto/v2"
 )
 
-// v2LookupEye queries the Eye monitoring profile server
-func (l *Lookup) v2LookupEye(lookID string) (*v2.Result, error) {
+// v2LookupEye queries the Eye monitoring profile server ep
+func (l *Lookup) v2LookupEye(ctx context.Context, ep *endpoint, lookID string, version int64) (*v2.Result, error) {
 	var err error
 	var resp *resty.Response
 	var result *v2.Result
 
 	if resp, err = l.client.R().
+		SetContext(ctx).
 		SetPathParams(map[string]string{
 			`lookID`: lookID,
 		}).Get(
-		l.eyeLookupURL.String(),
+		ep.lookupURL.String(),
 	); err != nil {
-		return nil, fmt.Errorf("eyewall.Lookup: %s", err.Error())
+		return nil, &connError{
+			err: fmt.Errorf("eyewall.Lookup: %s", err.Error()),
+		}
 	}
 
 	switch resp.StatusCode() {
 	case http.StatusOK:
+	case http.StatusBadRequest:
+		return nil, ErrMalformed
 	default:
 		return nil, fmt.Errorf("eyewall.Lookup: %s", resp.String())
 	}
@@ -45,7 +51,7 @@ func (l *Lookup) v2LookupEye(lookID string) (*v2.Result, error) {
 		return result, nil
 	case ErrUnconfigured:
 		// no profiles for lookID
-		l.setUnconfigured(lookID)
+		l.setUnconfigured(ctx, lookID, version)
 		return nil, ErrUnconfigured
 	default:
 		return nil, fmt.Errorf("eyewall.Lookup: %s", err.Error())
@@ -53,42 +59,50 @@ func (l *Lookup) v2LookupEye(lookID string) (*v2.Result, error) {
 }
 
 // v2Process converts t into Threshold and stores it in the
-// local cache if available
-func (l *Lookup) v2Process(lookID string, pr *v2.Result) (map[string]Threshold, error) {
+// local cache if available, unless eye wrote an update newer than
+// version
+func (l *Lookup) v2Process(ctx context.Context, lookID string, pr *v2.Result, version int64) (map[string]Threshold, error) {
 	if pr.Configurations == nil {
 		return nil, fmt.Errorf(`eyewall.Lookup: v2Process received pr.Configurations == nil`)
 	}
 	if len(*pr.Configurations) == 0 {
-		l.setUnconfigured(lookID)
+		l.setUnconfigured(ctx, lookID, version)
 		return nil, ErrUnconfigured
 	}
 
 	res := make(map[string]Threshold)
 	for _, i := range *pr.Configurations {
-		t := Threshold{
-			ID:             i.ID,
-			Metric:         i.Metric,
-			HostID:         i.HostID,
-			Oncall:         i.Data[0].Oncall,
-			Interval:       i.Data[0].Interval,
-			MetaMonitoring: i.Data[0].Monitoring,
-			MetaTeam:       i.Data[0].Team,
-			MetaSource:     i.Data[0].Source,
-			MetaTargethost: i.Data[0].Targethost,
-		}
-		l.v2UpdateCachedActivation(i.ID, i.ActivatedAt)
-
-		t.Thresholds = make(map[string]int64)
-		for _, tl := range i.Data[0].Thresholds {
-			lvl := strconv.FormatUint(uint64(tl.Level), 10)
-			t.Predicate = tl.Predicate
-			t.Thresholds[lvl] = tl.Value
-		}
+		t := v2Threshold(&i)
+		l.v2UpdateCachedActivation(ctx, i.ID, i.ActivatedAt)
 
-		l.storeThreshold(lookID, &t)
+		l.storeThreshold(ctx, lookID, &t, version)
 		res[t.ID] = t
 	}
 	return res, nil
 }
 
+// v2Threshold converts configuration c into the Threshold format
+// stored in the cache
+func v2Threshold(c *v2.Configuration) Threshold {
+	t := Threshold{
+		ID:             c.ID,
+		Metric:         c.Metric,
+		HostID:         c.HostID,
+		Oncall:         c.Data[0].Oncall,
+		Interval:       c.Data[0].Interval,
+		MetaMonitoring: c.Data[0].Monitoring,
+		MetaTeam:       c.Data[0].Team,
+		MetaSource:     c.Data[0].Source,
+		MetaTargethost: c.Data[0].Targethost,
+	}
+
+	t.Thresholds = make(map[string]int64)
+	for _, tl := range c.Data[0].Thresholds {
+		lvl := strconv.FormatUint(uint64(tl.Level), 10)
+		t.Predicate = tl.Predicate
+		t.Thresholds[lvl] = tl.Value
+	}
+	return t
+}
+
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
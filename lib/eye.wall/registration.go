@@ -8,19 +8,31 @@
 
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
-import proto "github.com/solnx/eye/lib/eye.proto"
+import (
+	"context"
+
+	proto "github.com/solnx/eye/lib/eye.proto"
+)
 
 // Register adds this eyewall cache to the list of active caches that
 // must be invalidated by Eye
 func (l *Lookup) Register() error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return l.RegisterContext(ctx)
+}
+
+// RegisterContext is Register with a caller provided context
+func (l *Lookup) RegisterContext(ctx context.Context) error {
 	// apiVersion is not initialized, run a quick tasting
-	if l.apiVersion == proto.ProtocolInvalid {
+	if l.APIVersion() == proto.ProtocolInvalid {
 		l.taste(true)
 	}
 
-	switch l.apiVersion {
+	switch l.APIVersion() {
 	case proto.ProtocolTwo:
-		return l.v2Register()
+		return l.v2Register(ctx)
 	}
 
 	return ErrProtocol
@@ -28,14 +40,41 @@ func (l *Lookup) Register() error {
 
 // Unregister removes the cache invalidation registration from Eye
 func (l *Lookup) Unregister() error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return l.UnregisterContext(ctx)
+}
+
+// UnregisterContext is Unregister with a caller provided context
+func (l *Lookup) UnregisterContext(ctx context.Context) error {
 	// apiVersion is not initialized, can't possibly be registered
-	if l.apiVersion == proto.ProtocolInvalid {
+	if l.APIVersion() == proto.ProtocolInvalid {
 		l.taste(true)
 	}
 
-	switch l.apiVersion {
+	switch l.APIVersion() {
 	case proto.ProtocolTwo:
-		return l.v2Unregister()
+		return l.v2Unregister(ctx)
+	}
+
+	return ErrProtocol
+}
+
+// Renew extends the lease of the cache invalidation registration. It
+// is called periodically while the cache is registered.
+func (l *Lookup) Renew() error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return l.RenewContext(ctx)
+}
+
+// RenewContext is Renew with a caller provided context
+func (l *Lookup) RenewContext(ctx context.Context) error {
+	switch l.APIVersion() {
+	case proto.ProtocolTwo:
+		return l.v2Renew(ctx)
 	}
 
 	return ErrProtocol
@@ -43,9 +82,18 @@ func (l *Lookup) Unregister() error {
 
 // LookupRegistrations returns the registrations for app
 func (l *Lookup) LookupRegistrations(app string) (*proto.Result, error) {
-	switch l.apiVersion {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return l.LookupRegistrationsContext(ctx, app)
+}
+
+// LookupRegistrationsContext is LookupRegistrations with a caller
+// provided context
+func (l *Lookup) LookupRegistrationsContext(ctx context.Context, app string) (*proto.Result, error) {
+	switch l.APIVersion() {
 	case proto.ProtocolTwo:
-		return l.v2LookupRegistrations(app)
+		return l.v2LookupRegistrations(ctx, app)
 	}
 
 	return nil, ErrProtocol
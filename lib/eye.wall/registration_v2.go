@@ -9,9 +9,11 @@
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-resty/resty"
 	proto "github.com/solnx/eye/lib/eye.proto"
@@ -19,14 +21,26 @@ import (
 )
 
 // v2Register implements the cache registration for API version 2
-func (l *Lookup) v2Register() error {
+func (l *Lookup) v2Register(ctx context.Context) error {
+	l.regLock.Lock()
+	defer l.regLock.Unlock()
+
 	// already registered - unregister first
 	if l.registration != `` {
-		if err := l.v2Unregister(); err != nil {
+		if err := l.v2Delete(ctx); err != nil {
 			return err
 		}
 	}
 
+	if err := l.v2Add(ctx); err != nil {
+		return err
+	}
+	l.startRenewal()
+	return nil
+}
+
+// v2Add creates a new cache registration via API version 2
+func (l *Lookup) v2Add(ctx context.Context) error {
 	rq := v2.NewRegistrationRequest()
 	rq.Registration = &v2.Registration{
 		Application: l.name,
@@ -38,15 +52,21 @@ func (l *Lookup) v2Register() error {
 		10, 64)
 
 	// register cache in Eye
-	var err error
 	var resp *resty.Response
 	var r *v2.Result
 
+	ep, err := l.v2Endpoint()
+	if err != nil {
+		return err
+	}
+
 	if resp, err = l.client.R().
+		SetContext(ctx).
 		SetBody(rq).
 		Post(
-			l.eyeRegAddURL.String(),
+			ep.regAddURL.String(),
 		); err != nil {
+		l.endpointFailed(ctx, ep, err)
 		return fmt.Errorf("eyewall.v2Register: %s", err.Error())
 	}
 
@@ -54,28 +74,44 @@ func (l *Lookup) v2Register() error {
 		return fmt.Errorf("eyewall.v2Register: %s", err.Error())
 	}
 
-	// record our cache registrationID
+	// record our cache registrationID and lease
 	l.registration = (*r.Registrations)[0].ID
+	l.leaseTTL = time.Duration((*r.Registrations)[0].LeaseTTL) * time.Second
 
 	return nil
 }
 
 // v2Unregister implements the cache unregistration for API version 2
-func (l *Lookup) v2Unregister() error {
+func (l *Lookup) v2Unregister(ctx context.Context) error {
+	l.regLock.Lock()
+	defer l.regLock.Unlock()
+
+	l.stopRenewal()
+	return l.v2Delete(ctx)
+}
+
+// v2Delete deletes the cache registration via API version 2
+func (l *Lookup) v2Delete(ctx context.Context) error {
 	// not registered
 	if l.registration == `` {
 		return nil
 	}
 
-	var err error
 	var resp *resty.Response
 
+	ep, err := l.v2Endpoint()
+	if err != nil {
+		return err
+	}
+
 	if resp, err = l.client.R().
+		SetContext(ctx).
 		SetPathParams(map[string]string{
 			`registrationID`: l.registration,
 		}).Delete(
-		l.eyeRegDelURL.String(),
+		ep.regDelURL.String(),
 	); err != nil {
+		l.endpointFailed(ctx, ep, err)
 		return fmt.Errorf("eyewall.v2Unregister: %s", err.Error())
 	}
 
@@ -87,19 +123,110 @@ func (l *Lookup) v2Unregister() error {
 	return nil
 }
 
+// v2Renew implements the registration lease renewal for API version 2.
+// If eye has already expired the lease, the cache is registered again.
+func (l *Lookup) v2Renew(ctx context.Context) error {
+	l.regLock.Lock()
+	defer l.regLock.Unlock()
+
+	// not registered
+	if l.registration == `` {
+		return nil
+	}
+
+	var resp *resty.Response
+
+	ep, err := l.v2Endpoint()
+	if err != nil {
+		return err
+	}
+
+	if resp, err = l.client.R().
+		SetContext(ctx).
+		SetPathParams(map[string]string{
+			`registrationID`: l.registration,
+		}).Put(
+		ep.regRenURL.String(),
+	); err != nil {
+		l.endpointFailed(ctx, ep, err)
+		return fmt.Errorf("eyewall.v2Renew: %s", err.Error())
+	}
+
+	switch _, err = v2Result(resp.Body()); err {
+	case nil:
+		return nil
+	case ErrUnconfigured:
+		// lease expired and eye no longer invalidates this cache.
+		// Entries may have missed invalidations in between, flush
+		// them before registering again. On error the renewal is
+		// retried with the expired registration.
+		if err = l.cache.Flush(ctx); err != nil {
+			return fmt.Errorf("eyewall.v2Renew: %s", err.Error())
+		}
+		l.registration = ``
+		return l.v2Add(ctx)
+	default:
+		return fmt.Errorf("eyewall.v2Renew: %s", err.Error())
+	}
+}
+
+// startRenewal starts the periodic renewal of the registration lease.
+// The caller must hold l.regLock.
+func (l *Lookup) startRenewal() {
+	if l.leaseTTL <= 0 || l.renewStop != nil {
+		return
+	}
+	l.renewStop = make(chan struct{})
+	go l.renewLoop(l.renewStop, l.leaseTTL/3)
+}
+
+// stopRenewal stops the periodic renewal of the registration lease.
+// The caller must hold l.regLock.
+func (l *Lookup) stopRenewal() {
+	if l.renewStop == nil {
+		return
+	}
+	close(l.renewStop)
+	l.renewStop = nil
+}
+
+// renewLoop renews the registration lease every interval until stop is
+// closed
+func (l *Lookup) renewLoop(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := l.Renew(); err != nil && l.log != nil {
+				l.log.Errorf("eyewall/cache: %s", err.Error())
+			}
+		}
+	}
+}
+
 // v2LookupRegistrations returns the cache registrations of app via API
 // version 2
-func (l *Lookup) v2LookupRegistrations(app string) (*proto.Result, error) {
-	var err error
+func (l *Lookup) v2LookupRegistrations(ctx context.Context, app string) (*proto.Result, error) {
 	var resp *resty.Response
 	var r *v2.Result
 
+	ep, err := l.v2Endpoint()
+	if err != nil {
+		return nil, err
+	}
+
 	if resp, err = l.client.R().
+		SetContext(ctx).
 		SetPathParams(map[string]string{
 			`app`: app,
 		}).Get(
-		l.eyeRegGetURL.String(),
+		ep.regGetURL.String(),
 	); err != nil {
+		l.endpointFailed(ctx, ep, err)
 		return nil, fmt.Errorf("eyewall.v2LookupRegistrations: %s", err.Error())
 	}
 
@@ -0,0 +1,363 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	proto "github.com/solnx/eye/lib/eye.proto"
+)
+
+// snapshotVersion is the format version of snapshot files
+const snapshotVersion = 1
+
+// snapshotFile is the content of a snapshot file
+type snapshotFile struct {
+	Version   int                      `json:"version"`
+	WrittenAt time.Time                `json:"writtenAt"`
+	Entries   map[string]snapshotEntry `json:"entries"`
+}
+
+// snapshotEntry are the thresholds of a lookID within a snapshot file
+type snapshotEntry struct {
+	StoredAt   time.Time   `json:"storedAt"`
+	Thresholds []Threshold `json:"thresholds"`
+}
+
+// EnableSnapshot configures Lookup to write the last known thresholds
+// kept for serving stale thresholds to the gzip compressed file at
+// path every interval and on Close. If neither eye nor the local Redis
+// are reachable by Start, the snapshot is loaded and served read-only
+// instead, with all returned thresholds marked stale. Snapshots
+// require serving stale thresholds to be enabled. It must be called
+// before Start.
+func (l *Lookup) EnableSnapshot(path string, interval time.Duration) {
+	l.snapPath = path
+	l.snapInterval = interval
+}
+
+// fallbackRetryInterval is the interval at which Lookup checks
+// whether eye and the local Redis became available while serving a
+// snapshot
+const fallbackRetryInterval = 30 * time.Second
+
+// Fallback reports whether Lookup serves a snapshot read-only since
+// neither eye nor the local Redis were reachable by Start. Lookup
+// queries eye as soon as it becomes available and leaves this mode
+// once the local Redis is reachable as well.
+func (l *Lookup) Fallback() bool {
+	return atomic.LoadInt32(&l.fallback) == 1
+}
+
+// startFallback loads the snapshot as read-only fallback if eye is not
+// reachable and reports whether it was loaded
+func (l *Lookup) startFallback() bool {
+	if l.snapPath == `` || l.APIVersion() != proto.ProtocolInvalid {
+		return false
+	}
+
+	entries, err := readSnapshot(l.snapPath)
+	if err != nil {
+		if l.log != nil {
+			l.log.Errorf("eyewall/snapshot: %s", err.Error())
+		}
+		return false
+	}
+
+	l.stale.load(entries)
+	fc := newFallbackCache()
+	l.cache = fc
+	atomic.StoreInt32(&l.fallback, 1)
+	if l.log != nil {
+		l.log.Warnf("eyewall/snapshot: eye and cache unavailable, serving %d lookIDs from %s",
+			len(entries), l.snapPath)
+	}
+
+	l.recoverStop = make(chan struct{})
+	l.recoverDone = make(chan struct{})
+	go l.fallbackRecovery(fc, l.recoverStop, l.recoverDone)
+	return true
+}
+
+// fallbackRecovery periodically tries to leave the fallback mode until
+// it succeeds or stop is closed
+func (l *Lookup) fallbackRecovery(fc *fallbackCache, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(fallbackRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if l.leaveFallback(fc) {
+				return
+			}
+		}
+	}
+}
+
+// leaveFallback switches Lookup to the local Redis cache and registers
+// it with eye if both are reachable again, and reports whether it did
+func (l *Lookup) leaveFallback(fc *fallbackCache) bool {
+	if l.APIVersion() == proto.ProtocolInvalid {
+		l.taste(true)
+		if l.APIVersion() == proto.ProtocolInvalid {
+			return false
+		}
+	}
+
+	cache, err := l.newRedisCache()
+	if err != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err = cache.ResetReceived(ctx); err != nil {
+		cache.Close()
+		return false
+	}
+
+	// the snapshot entries are subject to the regular maximum age
+	// from now on
+	l.stale.unpin()
+	fc.swap(cache)
+	l.ownCache = true
+	atomic.StoreInt32(&l.fallback, 0)
+	if l.log != nil {
+		l.log.Infof("eyewall/snapshot: eye and cache available, leaving fallback mode")
+	}
+
+	if err = l.register(); err != nil && l.log != nil {
+		l.log.Errorf("eyewall/snapshot: %s", err.Error())
+	}
+	return true
+}
+
+// stopRecovery stops trying to leave the fallback mode
+func (l *Lookup) stopRecovery() {
+	if l.recoverStop == nil {
+		return
+	}
+	close(l.recoverStop)
+	<-l.recoverDone
+	l.recoverStop = nil
+}
+
+// fallbackCache is the Cache of Lookup in fallback mode. It stores
+// nothing until the local Redis becomes available and is swapped in.
+type fallbackCache struct {
+	current atomic.Value
+}
+
+// cacheRef wraps a Cache to store it within an atomic.Value
+type cacheRef struct {
+	Cache
+}
+
+// newFallbackCache returns a fallbackCache that stores nothing
+func newFallbackCache() *fallbackCache {
+	fc := &fallbackCache{}
+	fc.current.Store(cacheRef{NewNoopCache()})
+	return fc
+}
+
+// swap replaces the cache all calls are forwarded to
+func (fc *fallbackCache) swap(c Cache) {
+	fc.current.Store(cacheRef{c})
+}
+
+// cache returns the cache all calls are forwarded to
+func (fc *fallbackCache) cache() Cache {
+	return fc.current.Load().(cacheRef).Cache
+}
+
+// Lookup implements Cache
+func (fc *fallbackCache) Lookup(ctx context.Context, lookID string) (map[string]Threshold, error) {
+	return fc.cache().Lookup(ctx, lookID)
+}
+
+// Version implements Cache
+func (fc *fallbackCache) Version(ctx context.Context, lookID string) (int64, error) {
+	return fc.cache().Version(ctx, lookID)
+}
+
+// StoreThreshold implements Cache
+func (fc *fallbackCache) StoreThreshold(ctx context.Context, lookID string, t *Threshold, version int64) error {
+	return fc.cache().StoreThreshold(ctx, lookID, t, version)
+}
+
+// SetUnconfigured implements Cache
+func (fc *fallbackCache) SetUnconfigured(ctx context.Context, lookID string, version int64) error {
+	return fc.cache().SetUnconfigured(ctx, lookID, version)
+}
+
+// Invalidate implements Cache
+func (fc *fallbackCache) Invalidate(ctx context.Context, lookID string) error {
+	return fc.cache().Invalidate(ctx, lookID)
+}
+
+// Flush implements Cache
+func (fc *fallbackCache) Flush(ctx context.Context) error {
+	return fc.cache().Flush(ctx)
+}
+
+// Activation implements Cache
+func (fc *fallbackCache) Activation(ctx context.Context, profileID string) (string, error) {
+	return fc.cache().Activation(ctx, profileID)
+}
+
+// SetActivation implements Cache
+func (fc *fallbackCache) SetActivation(ctx context.Context, profileID, ts string) error {
+	return fc.cache().SetActivation(ctx, profileID, ts)
+}
+
+// Heartbeat implements Cache
+func (fc *fallbackCache) Heartbeat(ctx context.Context, key string, ts time.Time) error {
+	return fc.cache().Heartbeat(ctx, key, ts)
+}
+
+// Evaluated implements Cache
+func (fc *fallbackCache) Evaluated(ctx context.Context, profileID string, ts time.Time) error {
+	return fc.cache().Evaluated(ctx, profileID, ts)
+}
+
+// IncrReceived implements Cache
+func (fc *fallbackCache) IncrReceived(ctx context.Context) error {
+	return fc.cache().IncrReceived(ctx)
+}
+
+// ResetReceived implements Cache
+func (fc *fallbackCache) ResetReceived(ctx context.Context) error {
+	return fc.cache().ResetReceived(ctx)
+}
+
+// Close implements Cache
+func (fc *fallbackCache) Close() error {
+	return fc.cache().Close()
+}
+
+// startSnapshots starts the periodic writing of snapshots
+func (l *Lookup) startSnapshots() {
+	if l.snapPath == `` || l.snapInterval <= 0 ||
+		l.snapStop != nil {
+		return
+	}
+	l.snapStop = make(chan struct{})
+	go l.snapshotLoop(l.snapStop, l.snapInterval)
+}
+
+// stopSnapshots stops the periodic writing of snapshots and writes a
+// final snapshot
+func (l *Lookup) stopSnapshots() {
+	if l.snapStop == nil {
+		return
+	}
+	close(l.snapStop)
+	l.snapStop = nil
+
+	if err := l.writeSnapshot(); err != nil && l.log != nil {
+		l.log.Errorf("eyewall/snapshot: %s", err.Error())
+	}
+}
+
+// snapshotLoop writes a snapshot every interval until stop is closed
+func (l *Lookup) snapshotLoop(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := l.writeSnapshot(); err != nil && l.log != nil {
+				l.log.Errorf("eyewall/snapshot: %s", err.Error())
+			}
+		}
+	}
+}
+
+// writeSnapshot writes the last known thresholds to the snapshot file.
+// The snapshot is not written while it is served read-only.
+func (l *Lookup) writeSnapshot() error {
+	if l.Fallback() {
+		return nil
+	}
+
+	tmp, err := os.Create(filepath.Join(
+		filepath.Dir(l.snapPath),
+		`.`+filepath.Base(l.snapPath)+`.tmp`,
+	))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	if err = json.NewEncoder(zw).Encode(snapshotFile{
+		Version:   snapshotVersion,
+		WrittenAt: time.Now().UTC(),
+		Entries:   l.stale.dump(),
+	}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	// replace the previous snapshot atomically
+	return os.Rename(tmp.Name(), l.snapPath)
+}
+
+// readSnapshot returns the entries of the snapshot file at path
+func readSnapshot(path string) (map[string]snapshotEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	snap := snapshotFile{}
+	if err = json.NewDecoder(zr).Decode(&snap); err != nil {
+		return nil, err
+	}
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d in %s",
+			snap.Version, path)
+	}
+	return snap.Entries, nil
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,292 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStaleMaxAge is the default time Lookup keeps the last known
+// thresholds of a lookID to serve them while eye is unavailable
+const DefaultStaleMaxAge = 24 * time.Hour
+
+// DefaultStaleSize is the default number of lookIDs Lookup keeps the
+// last known thresholds of
+const DefaultStaleSize = 10000
+
+// staleStore holds the last known thresholds of up to size lookIDs
+// fetched from eye, which outlive their expiry in the cache and are
+// served while eye can not be queried. If the store is full, the
+// lookID that was fetched least recently is evicted.
+type staleStore struct {
+	lock    sync.Mutex
+	maxAge  time.Duration
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// staleEntry is the last known state of a single lookID
+type staleEntry struct {
+	lookID     string
+	thresholds map[string]Threshold
+	storedAt   time.Time
+	// served is set if the entry was served while eye was
+	// unavailable and has to be revalidated once eye recovers
+	served bool
+	// snapshot is set if the entry was loaded from a snapshot file,
+	// which is served regardless of its age
+	snapshot bool
+}
+
+// newStaleStore returns a staleStore that keeps up to size entries
+// for maxAge. A maxAge or size of 0 disables the store.
+func newStaleStore(maxAge time.Duration, size int) *staleStore {
+	if size <= 0 {
+		maxAge = 0
+	}
+	return &staleStore{
+		maxAge:  maxAge,
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// store records thr as the last known thresholds of lookID
+func (s *staleStore) store(lookID string, thr map[string]Threshold) {
+	if s.maxAge <= 0 {
+		return
+	}
+
+	cp := make(map[string]Threshold, len(thr))
+	for id, t := range thr {
+		cp[id] = copyThreshold(t)
+	}
+	entry := &staleEntry{
+		lookID:     lookID,
+		thresholds: cp,
+		storedAt:   time.Now(),
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if elem, ok := s.entries[lookID]; ok {
+		elem.Value = entry
+		s.order.MoveToFront(elem)
+	} else {
+		s.entries[lookID] = s.order.PushFront(entry)
+	}
+	s.prune()
+}
+
+// prune evicts entries beyond size and expired entries. The list is
+// ordered by storedAt, expired entries are at its back.
+func (s *staleStore) prune() {
+	for s.order.Len() > s.size {
+		s.evict(s.order.Back())
+	}
+	for elem := s.order.Back(); elem != nil; elem = s.order.Back() {
+		if !s.expired(elem.Value.(*staleEntry)) {
+			return
+		}
+		s.evict(elem)
+	}
+}
+
+// expired reports whether entry is older than maxAge
+func (s *staleStore) expired(entry *staleEntry) bool {
+	return !entry.snapshot && time.Since(entry.storedAt) > s.maxAge
+}
+
+// evict removes elem from the store
+func (s *staleStore) evict(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*staleEntry).lookID)
+}
+
+// remove deletes the entry of lookID
+func (s *staleStore) remove(lookID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if elem, ok := s.entries[lookID]; ok {
+		s.evict(elem)
+	}
+}
+
+// serve returns a copy of the last known thresholds of lookID, marked
+// as stale. The entry is revalidated once eye recovers.
+func (s *staleStore) serve(lookID string) (map[string]Threshold, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	elem, ok := s.entries[lookID]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*staleEntry)
+	if s.expired(entry) {
+		s.evict(elem)
+		return nil, false
+	}
+	entry.served = true
+
+	res := make(map[string]Threshold, len(entry.thresholds))
+	for id, t := range entry.thresholds {
+		t = copyThreshold(t)
+		t.Stale = true
+		t.StaleSince = entry.storedAt
+		res[id] = t
+	}
+	return res, true
+}
+
+// pending returns the lookIDs that were served while eye was
+// unavailable. Entries older than maxAge are pruned.
+func (s *staleStore) pending() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	res := []string{}
+	for lookID, elem := range s.entries {
+		entry := elem.Value.(*staleEntry)
+		if s.expired(entry) {
+			s.evict(elem)
+			continue
+		}
+		if entry.served {
+			res = append(res, lookID)
+		}
+	}
+	return res
+}
+
+// dump returns a copy of all entries
+func (s *staleStore) dump() map[string]snapshotEntry {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	res := make(map[string]snapshotEntry, len(s.entries))
+	for lookID, elem := range s.entries {
+		entry := elem.Value.(*staleEntry)
+		se := snapshotEntry{
+			StoredAt:   entry.storedAt,
+			Thresholds: make([]Threshold, 0, len(entry.thresholds)),
+		}
+		for _, t := range entry.thresholds {
+			se.Thresholds = append(se.Thresholds, copyThreshold(t))
+		}
+		res[lookID] = se
+	}
+	return res
+}
+
+// load adds the entries of a snapshot file for all lookIDs that have
+// no entry yet, as long as the store is not full. They are evicted
+// before all entries fetched from eye.
+func (s *staleStore) load(entries map[string]snapshotEntry) {
+	if s.maxAge <= 0 {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for lookID, se := range entries {
+		if s.order.Len() >= s.size {
+			return
+		}
+		if _, ok := s.entries[lookID]; ok {
+			continue
+		}
+		entry := &staleEntry{
+			lookID:     lookID,
+			thresholds: make(map[string]Threshold, len(se.Thresholds)),
+			storedAt:   se.StoredAt,
+			snapshot:   true,
+		}
+		for _, t := range se.Thresholds {
+			entry.thresholds[t.ID] = t
+		}
+		s.entries[lookID] = s.order.PushBack(entry)
+	}
+}
+
+// unpin subjects the entries loaded from a snapshot file to maxAge
+func (s *staleStore) unpin() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, elem := range s.entries {
+		elem.Value.(*staleEntry).snapshot = false
+	}
+}
+
+// serveStale returns the last known thresholds of lookID. If none are
+// available, err is returned.
+func (l *Lookup) serveStale(lookID string, err error) (map[string]Threshold, error) {
+	if thr, ok := l.stale.serve(lookID); ok {
+		atomic.AddUint64(&l.stats.StaleServed, 1)
+		return thr, nil
+	}
+	atomic.AddUint64(&l.stats.StaleMissed, 1)
+	return nil, err
+}
+
+// revalidate refreshes all entries that were served stale while eye
+// was unavailable. It stops if eye becomes unavailable again.
+func (l *Lookup) revalidate() {
+	if !atomic.CompareAndSwapInt32(&l.revalidating, 0, 1) {
+		// revalidation is already running
+		return
+	}
+	defer atomic.StoreInt32(&l.revalidating, 0)
+
+	for _, lookID := range l.stale.pending() {
+		if !l.breaker.allow() {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		thr, err := l.lookupEye(ctx, lookID)
+		cancel()
+
+		switch err.(type) {
+		case nil:
+			l.breaker.success()
+			l.stale.store(lookID, thr)
+			atomic.AddUint64(&l.stats.Revalidated, 1)
+			continue
+		case *eyeError:
+			l.eyeFailure()
+			atomic.AddUint64(&l.stats.RevalidateFailed, 1)
+			return
+		}
+
+		if err == ErrUnconfigured {
+			l.breaker.success()
+			l.stale.remove(lookID)
+			atomic.AddUint64(&l.stats.Revalidated, 1)
+			continue
+		}
+		l.breaker.release()
+		atomic.AddUint64(&l.stats.RevalidateFailed, 1)
+		if l.log != nil {
+			l.log.Errorf("eyewall/revalidate: %s", err.Error())
+		}
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,63 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"sync/atomic"
+)
+
+// Statistics are the counters kept by Lookup
+type Statistics struct {
+	// StaleServed is the number of lookups answered with stale
+	// thresholds while eye was unavailable
+	StaleServed uint64
+	// StaleMissed is the number of lookups that failed while eye was
+	// unavailable since no stale thresholds were available
+	StaleMissed uint64
+	// BreakerOpened is the number of times the circuit breaker opened
+	BreakerOpened uint64
+	// BreakerRejected is the number of requests to eye skipped since
+	// the circuit breaker was open
+	BreakerRejected uint64
+	// Revalidated is the number of stale entries refreshed from eye
+	// after it recovered
+	Revalidated uint64
+	// RevalidateFailed is the number of stale entries that could not
+	// be refreshed from eye after it recovered
+	RevalidateFailed uint64
+	// SyncRefreshed is the number of lookIDs refreshed by the
+	// incremental sync
+	SyncRefreshed uint64
+	// SyncFailed is the number of lookIDs the incremental sync failed
+	// to refresh
+	SyncFailed uint64
+	// Flights is the number of lookups sent to eye
+	Flights uint64
+	// Coalesced is the number of lookups that shared the result of a
+	// concurrent lookup of the same lookID instead of querying eye
+	Coalesced uint64
+}
+
+// Statistics returns a snapshot of the counters of l
+func (l *Lookup) Statistics() Statistics {
+	return Statistics{
+		StaleServed:      atomic.LoadUint64(&l.stats.StaleServed),
+		StaleMissed:      atomic.LoadUint64(&l.stats.StaleMissed),
+		BreakerOpened:    atomic.LoadUint64(&l.stats.BreakerOpened),
+		BreakerRejected:  atomic.LoadUint64(&l.stats.BreakerRejected),
+		Revalidated:      atomic.LoadUint64(&l.stats.Revalidated),
+		RevalidateFailed: atomic.LoadUint64(&l.stats.RevalidateFailed),
+		SyncRefreshed:    atomic.LoadUint64(&l.stats.SyncRefreshed),
+		SyncFailed:       atomic.LoadUint64(&l.stats.SyncFailed),
+		Flights:          atomic.LoadUint64(&l.stats.Flights),
+		Coalesced:        atomic.LoadUint64(&l.stats.Coalesced),
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,100 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-redis/redis"
+)
+
+// DisableRegistration configures Lookup to not register the local
+// cache for invalidation with eye. It is used together with
+// SubscribeInvalidation, so that eye does not need to reach the local
+// cache. It must be called before Start.
+func (l *Lookup) DisableRegistration() {
+	l.noRegister = true
+}
+
+// SubscribeInvalidation subscribes l to the invalidation messages eye
+// publishes on channel of the Redis described by opts, and removes the
+// invalidated lookupIDs from the local cache. This replaces the
+// registration of the local cache with eye, which is skipped by Start
+// after DisableRegistration.
+// Messages published while the subscription is reconnecting are lost,
+// affected entries expire via the cache timeout.
+func (l *Lookup) SubscribeInvalidation(opts *redis.Options, channel string) error {
+	if _, noop := l.cache.(noopCache); l.cache == nil || noop {
+		return ErrNoCache
+	}
+	if channel == `` {
+		channel = DefaultInvalidationChannel
+	}
+
+	client := redis.NewClient(opts)
+	if _, err := client.Ping().Result(); err != nil {
+		client.Close()
+		return err
+	}
+	sub := client.Subscribe(channel)
+	// wait for the subscription to be confirmed
+	if _, err := sub.Receive(); err != nil {
+		sub.Close()
+		client.Close()
+		return err
+	}
+
+	l.subLock.Lock()
+	l.unsubscribe()
+	l.subClient = client
+	l.subscription = sub
+	l.subLock.Unlock()
+
+	go l.receiveInvalidation(sub.Channel())
+	return nil
+}
+
+// unsubscribe closes an active invalidation subscription. The caller
+// must hold l.subLock.
+func (l *Lookup) unsubscribe() {
+	if l.subscription != nil {
+		l.subscription.Close()
+		l.subscription = nil
+	}
+	if l.subClient != nil {
+		l.subClient.Close()
+		l.subClient = nil
+	}
+}
+
+// receiveInvalidation processes invalidation messages until ch is
+// closed
+func (l *Lookup) receiveInvalidation(ch <-chan *redis.Message) {
+	for m := range ch {
+		inv := InvalidationMessage{}
+		if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil {
+			if l.log != nil {
+				l.log.Errorf("eyewall/subscription: %s", err.Error())
+			}
+			continue
+		}
+		if inv.LookupID == `` {
+			continue
+		}
+
+		if err := l.cache.Invalidate(context.Background(), inv.LookupID); err != nil {
+			if l.log != nil {
+				l.log.Errorf("eyewall/subscription: %s", err.Error())
+			}
+		}
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
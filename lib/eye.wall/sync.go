@@ -0,0 +1,254 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// syncTimeout is the timeout of a single sync run
+const syncTimeout = 30 * time.Second
+
+// SyncStatus describes the state of the incremental sync
+type SyncStatus struct {
+	// Running is set if the sync is started
+	Running bool
+	// Cursor is the changeID of the change feed up to which changes
+	// have been synced
+	Cursor int64
+	// LastSync is the start of the last successful sync run. The cache
+	// reflects all changes made in eye before it.
+	LastSync time.Time
+	// Lag is the time since LastSync
+	Lag time.Duration
+}
+
+// syncer is the state of the incremental sync
+type syncer struct {
+	lock    sync.Mutex
+	stop    chan struct{}
+	started time.Time
+	// cursor is the changeID of the last synced change
+	cursor int64
+	// expired is the time before which changes have expired from the
+	// cache and need not be synced
+	expired time.Time
+	// retry are the lookIDs that failed to refresh during the last
+	// run
+	retry    map[string]bool
+	lastSync time.Time
+}
+
+// StartSync starts the periodic incremental sync of the local cache.
+// Every interval, the change feed of eye is read from the last synced
+// change on. Cached lookIDs affected by the changes are refreshed from
+// eye. The sync requires API version 2.
+func (l *Lookup) StartSync(interval time.Duration) {
+	l.syncer.lock.Lock()
+	defer l.syncer.lock.Unlock()
+
+	if l.syncer.stop != nil {
+		return
+	}
+
+	// the feed is read from its start, but older changes have expired
+	// from the cache
+	l.syncer.started = time.Now()
+	l.syncer.cursor = 0
+	l.syncer.expired = l.syncer.started.Add(-time.Duration(
+		l.Config.Redis.CacheTimeout,
+	) * time.Second)
+	l.syncer.retry = map[string]bool{}
+	l.syncer.lastSync = time.Time{}
+	l.syncer.stop = make(chan struct{})
+	go l.syncLoop(l.syncer.stop, interval)
+}
+
+// StopSync stops the incremental sync
+func (l *Lookup) StopSync() {
+	l.syncer.lock.Lock()
+	defer l.syncer.lock.Unlock()
+
+	if l.syncer.stop == nil {
+		return
+	}
+	close(l.syncer.stop)
+	l.syncer.stop = nil
+}
+
+// SyncStatus returns the state of the incremental sync
+func (l *Lookup) SyncStatus() SyncStatus {
+	l.syncer.lock.Lock()
+	defer l.syncer.lock.Unlock()
+
+	st := SyncStatus{
+		Running:  l.syncer.stop != nil,
+		Cursor:   l.syncer.cursor,
+		LastSync: l.syncer.lastSync,
+	}
+	switch {
+	case !st.Running:
+	case st.LastSync.IsZero():
+		st.Lag = time.Since(l.syncer.started)
+	default:
+		st.Lag = time.Since(st.LastSync)
+	}
+	return st
+}
+
+// SyncLag returns the time since the last successful sync run
+func (l *Lookup) SyncLag() time.Duration {
+	return l.SyncStatus().Lag
+}
+
+// syncLoop runs the sync every interval until stop is closed
+func (l *Lookup) syncLoop(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := l.syncRun(); err != nil && l.log != nil {
+				l.log.Errorf("eyewall/sync: %s", err.Error())
+			}
+		}
+	}
+}
+
+// syncRun performs a single sync run. The lookIDs that failed to
+// refresh are retried during the next run.
+func (l *Lookup) syncRun() error {
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+
+	changed := map[string]bool{}
+	l.syncer.lock.Lock()
+	cursor := l.syncer.cursor
+	expired := l.syncer.expired
+	for lookID := range l.syncer.retry {
+		changed[lookID] = true
+	}
+	l.syncer.lock.Unlock()
+
+	// read the feed until it is exhausted
+	next := cursor
+	for {
+		changes, err := l.v2ChangesSince(ctx, next)
+		if err != nil {
+			return err
+		}
+
+		progress := false
+		for i := range changes {
+			if changes[i].ID <= next {
+				// already synced
+				continue
+			}
+			next = changes[i].ID
+			progress = true
+
+			if changes[i].LookupID == `` {
+				continue
+			}
+			if l.syncExpired(&changes[i], expired) {
+				continue
+			}
+			changed[changes[i].LookupID] = true
+		}
+		if !progress {
+			break
+		}
+	}
+
+	retry := map[string]bool{}
+	for lookID := range changed {
+		if err := l.syncRefresh(ctx, lookID); err != nil {
+			retry[lookID] = true
+			atomic.AddUint64(&l.stats.SyncFailed, 1)
+			if l.log != nil {
+				l.log.Errorf("eyewall/sync: %s: %s", lookID, err.Error())
+			}
+			continue
+		}
+		atomic.AddUint64(&l.stats.SyncRefreshed, 1)
+	}
+
+	l.syncer.lock.Lock()
+	l.syncer.cursor = next
+	l.syncer.retry = retry
+	if len(retry) == 0 {
+		l.syncer.lastSync = started
+	}
+	l.syncer.lock.Unlock()
+	return nil
+}
+
+// syncExpired reports whether change c was made before expired, in
+// which case it has expired from the cache. Changes with an unreadable
+// timestamp are synced.
+func (l *Lookup) syncExpired(c *v2.Change, expired time.Time) bool {
+	ts, err := time.Parse(time.RFC3339, c.ChangedAt)
+	if err != nil {
+		if l.log != nil {
+			l.log.Warnf("eyewall/sync: change %d: %s", c.ID, err.Error())
+		}
+		return false
+	}
+	return ts.Before(expired)
+}
+
+// syncRefresh reloads lookID from eye if it is in the local cache.
+// LookIDs that are not cached are loaded on their next lookup. The
+// cached entry is only replaced once eye answered, a failed refresh
+// keeps the previous entry.
+func (l *Lookup) syncRefresh(ctx context.Context, lookID string) error {
+	switch _, err := l.lookupCache(ctx, lookID); err {
+	case nil, ErrUnconfigured:
+	case ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+
+	version := l.cacheVersion(ctx, lookID)
+	thr, err := l.lookupEye(ctx, lookID)
+	switch err {
+	case nil, ErrUnconfigured:
+	default:
+		return err
+	}
+
+	// lookupEye added the current profiles to the cached entry,
+	// replace it to drop the profiles that were removed in eye
+	if ierr := l.cache.Invalidate(ctx, lookID); ierr != nil {
+		return ierr
+	}
+	if err == ErrUnconfigured {
+		l.setUnconfigured(ctx, lookID, version)
+		l.stale.remove(lookID)
+		return nil
+	}
+	for id := range thr {
+		t := thr[id]
+		l.storeThreshold(ctx, lookID, &t, version)
+	}
+	l.stale.store(lookID, thr)
+	return nil
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
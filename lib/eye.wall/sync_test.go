@@ -0,0 +1,315 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjolnir42/erebos"
+	proto "github.com/solnx/eye/lib/eye.proto"
+	"github.com/solnx/eye/lib/eye.proto/v2"
+)
+
+// fakeEye serves the change feed and configuration lookups of eye
+type fakeEye struct {
+	lock sync.Mutex
+	// changes is the change feed, which is served pageSize changes at
+	// a time
+	changes  []v2.Change
+	pageSize int
+	// resend serves the whole feed at once regardless of the cursor
+	resend bool
+	// configurations are the profiles of each lookID, lookIDs without
+	// an entry are unconfigured
+	configurations map[string][]string
+	// failing lookIDs are answered with a server error
+	failing map[string]bool
+	// lookups counts the lookups of each lookID
+	lookups map[string]int
+}
+
+// ServeHTTP implements http.Handler
+func (fe *fakeEye) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fe.lock.Lock()
+	defer fe.lock.Unlock()
+
+	res := v2.Result{}
+	res.SetStatus(v2.StatusOK)
+
+	switch {
+	case r.URL.Path == `/api/v2/changes`:
+		since, _ := strconv.ParseInt(r.URL.Query().Get(`since`), 10, 64)
+		page := []v2.Change{}
+		for _, c := range fe.changes {
+			if fe.resend || (c.ID > since && len(page) < fe.pageSize) {
+				page = append(page, c)
+			}
+		}
+		res.Changes = &page
+
+	case strings.HasPrefix(r.URL.Path, `/api/v2/lookup/configuration/`):
+		lookID := strings.TrimPrefix(r.URL.Path, `/api/v2/lookup/configuration/`)
+		fe.lookups[lookID]++
+		if fe.failing[lookID] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		profiles, ok := fe.configurations[lookID]
+		if !ok {
+			res.SetStatus(v2.StatusNotFound)
+			break
+		}
+		configurations := []v2.Configuration{}
+		for _, profileID := range profiles {
+			configurations = append(configurations, v2.Configuration{
+				ID:          profileID,
+				LookupID:    lookID,
+				ActivatedAt: `never`,
+				Data:        []v2.Data{{Thresholds: []v2.Threshold{}}},
+			})
+		}
+		res.Configurations = &configurations
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&res)
+}
+
+// newTestLookup returns a Lookup with a local cache that queries eye
+// via API version 2
+func newTestLookup(t *testing.T, eye http.Handler) (*Lookup, *httptest.Server) {
+	srv := httptest.NewServer(eye)
+
+	conf := &erebos.Config{}
+	conf.Eyewall.ConcurrencyLimit = 4
+	l := NewLookup(conf, `test`)
+	l.SetCache(NewLRUCache(100, time.Minute))
+
+	u, _ := url.Parse(srv.URL)
+	if err := l.SetEndpoints(u.Host); err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	l.use(l.endpoint().tasted(proto.ProtocolTwo))
+	return l, srv
+}
+
+// cachedProfiles returns the sorted profileIDs cached for lookID, or
+// the cache error
+func cachedProfiles(l *Lookup, lookID string) ([]string, error) {
+	thr, err := l.cache.Lookup(context.Background(), lookID)
+	if err != nil {
+		return nil, err
+	}
+	profiles := []string{}
+	for profileID := range thr {
+		profiles = append(profiles, profileID)
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
+
+func TestSyncRun(t *testing.T) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	change := func(id int64, lookID string) v2.Change {
+		return v2.Change{ID: id, LookupID: lookID, ChangedAt: now}
+	}
+
+	tests := []struct {
+		name    string
+		cursor  int64
+		expired time.Time
+		retry   []string
+		resend  bool
+		changes []v2.Change
+		// cached are the profiles cached before the run
+		cached         map[string][]string
+		configurations map[string][]string
+		failing        []string
+		wantCursor     int64
+		wantLookups    map[string]int
+		wantRetry      []string
+		// wantCached are the profiles cached after the run, nil for
+		// unconfigured lookIDs
+		wantCached map[string][]string
+	}{
+		{
+			name:   `changes of a lookID across pages are synced once`,
+			cursor: 2,
+			changes: []v2.Change{
+				change(1, `a`), change(2, `b`), change(3, `a`),
+				change(4, `a`), change(5, `b`),
+			},
+			cached:         map[string][]string{`a`: {`p1`}, `b`: {`p2`}},
+			configurations: map[string][]string{`a`: {`p1`, `p3`}, `b`: {`p2`}},
+			wantCursor:     5,
+			wantLookups:    map[string]int{`a`: 1, `b`: 1},
+			wantCached:     map[string][]string{`a`: {`p1`, `p3`}, `b`: {`p2`}},
+		},
+		{
+			name:           `changes at the cursor are not synced again`,
+			cursor:         3,
+			resend:         true,
+			changes:        []v2.Change{change(2, `a`), change(3, `a`), change(4, `b`)},
+			cached:         map[string][]string{`a`: {`p1`}, `b`: {`p2`}},
+			configurations: map[string][]string{`a`: {`p1`}, `b`: {`p2`}},
+			wantCursor:     4,
+			wantLookups:    map[string]int{`b`: 1},
+			wantCached:     map[string][]string{`a`: {`p1`}, `b`: {`p2`}},
+		},
+		{
+			name:           `lookIDs that are not cached are skipped`,
+			changes:        []v2.Change{change(1, `a`)},
+			configurations: map[string][]string{`a`: {`p1`}},
+			wantCursor:     1,
+			wantLookups:    map[string]int{},
+			wantCached:     map[string][]string{},
+		},
+		{
+			name:           `failed refreshes are retried`,
+			changes:        []v2.Change{change(1, `a`), change(2, `b`)},
+			cached:         map[string][]string{`a`: {`p1`}, `b`: {`p2`}},
+			configurations: map[string][]string{`a`: {`p1`, `p3`}, `b`: {`p2`, `p4`}},
+			failing:        []string{`a`},
+			wantCursor:     2,
+			wantLookups:    map[string]int{`a`: 1, `b`: 1},
+			wantRetry:      []string{`a`},
+			wantCached:     map[string][]string{`a`: {`p1`}, `b`: {`p2`, `p4`}},
+		},
+		{
+			name:           `retried lookIDs are synced without new changes`,
+			cursor:         2,
+			retry:          []string{`a`},
+			changes:        []v2.Change{change(1, `a`), change(2, `b`)},
+			cached:         map[string][]string{`a`: {`p1`}},
+			configurations: map[string][]string{`a`: {`p1`, `p3`}},
+			wantCursor:     2,
+			wantLookups:    map[string]int{`a`: 1},
+			wantCached:     map[string][]string{`a`: {`p1`, `p3`}},
+		},
+		{
+			name:           `removed profiles are dropped`,
+			changes:        []v2.Change{change(1, `a`)},
+			cached:         map[string][]string{`a`: {`p1`, `p2`}},
+			configurations: map[string][]string{`a`: {`p2`}},
+			wantCursor:     1,
+			wantLookups:    map[string]int{`a`: 1},
+			wantCached:     map[string][]string{`a`: {`p2`}},
+		},
+		{
+			name:        `removed configurations are unconfigured`,
+			changes:     []v2.Change{change(1, `a`)},
+			cached:      map[string][]string{`a`: {`p1`}},
+			wantCursor:  1,
+			wantLookups: map[string]int{`a`: 1},
+			wantCached:  map[string][]string{`a`: nil},
+		},
+		{
+			name:    `expired changes are skipped`,
+			expired: time.Now().Add(time.Hour),
+			changes: []v2.Change{
+				change(1, `a`),
+				{ID: 2, LookupID: `b`, ChangedAt: `garbage`},
+			},
+			cached:         map[string][]string{`a`: {`p1`}, `b`: {`p2`}},
+			configurations: map[string][]string{`a`: {`p1`, `p3`}, `b`: {`p2`, `p4`}},
+			wantCursor:     2,
+			wantLookups:    map[string]int{`b`: 1},
+			wantCached:     map[string][]string{`a`: {`p1`}, `b`: {`p2`, `p4`}},
+		},
+	}
+
+	for _, tt := range tests {
+		eye := &fakeEye{
+			changes:        tt.changes,
+			pageSize:       2,
+			resend:         tt.resend,
+			configurations: tt.configurations,
+			failing:        map[string]bool{},
+			lookups:        map[string]int{},
+		}
+		for _, lookID := range tt.failing {
+			eye.failing[lookID] = true
+		}
+		l, srv := newTestLookup(t, eye)
+
+		for lookID, profiles := range tt.cached {
+			for _, profileID := range profiles {
+				l.storeThreshold(context.Background(), lookID, &Threshold{ID: profileID}, 0)
+			}
+		}
+		l.syncer.cursor = tt.cursor
+		l.syncer.expired = tt.expired
+		l.syncer.retry = map[string]bool{}
+		for _, lookID := range tt.retry {
+			l.syncer.retry[lookID] = true
+		}
+
+		if err := l.syncRun(); err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err.Error())
+		}
+		srv.Close()
+
+		if st := l.SyncStatus(); st.Cursor != tt.wantCursor {
+			t.Errorf("%s: cursor is %d, want %d", tt.name, st.Cursor, tt.wantCursor)
+		}
+		for lookID, n := range tt.wantLookups {
+			if eye.lookups[lookID] != n {
+				t.Errorf("%s: %s was looked up %d times, want %d",
+					tt.name, lookID, eye.lookups[lookID], n)
+			}
+		}
+		for lookID, n := range eye.lookups {
+			if _, ok := tt.wantLookups[lookID]; !ok {
+				t.Errorf("%s: %s was looked up %d times, want 0", tt.name, lookID, n)
+			}
+		}
+
+		if len(l.syncer.retry) != len(tt.wantRetry) {
+			t.Errorf("%s: retrying %v, want %v", tt.name, l.syncer.retry, tt.wantRetry)
+		}
+		for _, lookID := range tt.wantRetry {
+			if !l.syncer.retry[lookID] {
+				t.Errorf("%s: %s is not retried", tt.name, lookID)
+			}
+		}
+		if synced := !l.syncer.lastSync.IsZero(); synced != (len(tt.wantRetry) == 0) {
+			t.Errorf("%s: last sync is %s with %d retries", tt.name,
+				l.syncer.lastSync, len(tt.wantRetry))
+		}
+
+		for lookID, want := range tt.wantCached {
+			profiles, err := cachedProfiles(l, lookID)
+			switch {
+			case want == nil && err != ErrUnconfigured:
+				t.Errorf("%s: %s is cached as %v/%v, want unconfigured",
+					tt.name, lookID, profiles, err)
+			case want != nil && err != nil:
+				t.Errorf("%s: %s: unexpected error: %s", tt.name, lookID, err.Error())
+			case want != nil && strings.Join(profiles, `,`) != strings.Join(want, `,`):
+				t.Errorf("%s: %s is cached as %v, want %v", tt.name, lookID, profiles, want)
+			}
+		}
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -9,6 +9,10 @@
 
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
+import (
+	"time"
+)
+
 // Threshold is an internal datastructure for monitoring profile
 // thresholds suitable for storage in the Cache
 type Threshold struct {
@@ -23,6 +27,11 @@ type Threshold struct {
 	MetaTargethost string
 	Predicate      string
 	Thresholds     map[string]int64
+	// Stale is set if the threshold is the last known copy served
+	// while eye was unavailable, StaleSince is the time the copy was
+	// loaded
+	Stale      bool      `json:"-"`
+	StaleSince time.Time `json:"-"`
 }
 
 // vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -0,0 +1,182 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mjolnir42/erebos"
+	"github.com/mjolnir42/limit"
+)
+
+// Security configures how Lookup connects and authenticates to eye.
+// It applies to all requests, including API version tasting. NewLookup
+// reads it from the Eyewall section of the configuration.
+type Security struct {
+	// HTTPS enables https for all requests to eye
+	HTTPS bool
+	// CAFile is a PEM encoded bundle of the CAs used to verify eye. The
+	// system CAs are used if it is empty.
+	CAFile string
+	// CertFile and KeyFile are the PEM encoded client certificate and
+	// its key presented to eye
+	CertFile string
+	KeyFile  string
+	// Username and Password are sent via BasicAuth
+	Username string
+	Password string
+	// Token is sent as bearer token, it takes precedence over
+	// BasicAuth
+	Token string
+}
+
+// securityFromConfig returns the Security settings of the Eyewall
+// section of conf
+func securityFromConfig(conf *erebos.Config) Security {
+	return Security{
+		HTTPS:    conf.Eyewall.TLS,
+		CAFile:   conf.Eyewall.CAFile,
+		CertFile: conf.Eyewall.CertFile,
+		KeyFile:  conf.Eyewall.KeyFile,
+		Username: conf.Eyewall.Username,
+		Password: conf.Eyewall.Password,
+		Token:    conf.Eyewall.Token,
+	}
+}
+
+// SetSecurity configures TLS and authentication for all requests to
+// eye, overriding the settings from the configuration. It must be
+// called before Start.
+func (l *Lookup) SetSecurity(sec Security) error {
+	tlsConf, err := sec.tlsConfig()
+	if err != nil {
+		return fmt.Errorf("eyewall.SetSecurity: %s", err.Error())
+	}
+	l.secErr = nil
+
+	scheme := `http`
+	if sec.HTTPS {
+		scheme = `https`
+	}
+
+	l.transport.base.TLSClientConfig = tlsConf
+	l.transport.authorization = sec.authorization()
+
+	l.epLock.Lock()
+	defer l.epLock.Unlock()
+
+	l.scheme = scheme
+	for i, ep := range l.endpoints {
+		l.endpoints[i] = newEndpoint(scheme, ep.host, ep.port)
+	}
+	l.current = l.endpoints[0]
+	return nil
+}
+
+// tlsConfig returns the TLS configuration described by sec
+func (sec Security) tlsConfig() (*tls.Config, error) {
+	if !sec.HTTPS {
+		return nil, nil
+	}
+
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if sec.CAFile != `` {
+		pem, err := ioutil.ReadFile(sec.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s",
+				sec.CAFile)
+		}
+	}
+
+	if sec.CertFile != `` || sec.KeyFile != `` {
+		cert, err := tls.LoadX509KeyPair(sec.CertFile, sec.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// authorization returns the Authorization header value described by
+// sec
+func (sec Security) authorization() string {
+	switch {
+	case sec.Token != ``:
+		return `Bearer ` + sec.Token
+	case sec.Username != ``:
+		return `Basic ` + base64.StdEncoding.EncodeToString(
+			[]byte(sec.Username+`:`+sec.Password),
+		)
+	}
+	return ``
+}
+
+// eyeTransport is the http.RoundTripper used for all requests to eye.
+// It enforces the concurrency limit of Lookup, also releasing it if
+// the request fails, and authenticates the requests.
+type eyeTransport struct {
+	limit         *limit.Limit
+	base          *http.Transport
+	authorization string
+}
+
+// newEyeTransport returns an eyeTransport that enforces lim
+func newEyeTransport(lim *limit.Limit) *eyeTransport {
+	return &eyeTransport{
+		limit: lim,
+		base: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *eyeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.authorization != `` && req.Header.Get(`Authorization`) == `` {
+		// a RoundTripper must not modify the request
+		r := new(http.Request)
+		*r = *req
+		r.Header = make(http.Header, len(req.Header)+1)
+		for k, v := range req.Header {
+			r.Header[k] = v
+		}
+		r.Header.Set(`Authorization`, t.authorization)
+		req = r
+	}
+
+	t.limit.Start()
+	defer t.limit.Done()
+
+	return t.base.RoundTrip(req)
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
@@ -8,11 +8,17 @@
 
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
+import "context"
+
 // UpdateReceived increments the counter of received metrics
 func (l *Lookup) UpdateReceived() {
-	if _, err := l.redis.Incr(
-		`received_metrics`,
-	).Result(); err != nil {
+	l.UpdateReceivedContext(context.Background())
+}
+
+// UpdateReceivedContext is UpdateReceived with a caller provided
+// context
+func (l *Lookup) UpdateReceivedContext(ctx context.Context) {
+	if err := l.cache.IncrReceived(ctx); err != nil {
 		if l.log != nil {
 			l.log.Errorf("eyewall/updateReceived: %s", err.Error())
 		}
@@ -21,12 +27,8 @@ func (l *Lookup) UpdateReceived() {
 
 // resetReceived is called during startup to reset the number of
 // received metrics set within the cache
-func (l *Lookup) resetReceived() error {
-	if _, err := l.redis.Set(
-		`received_metrics`,
-		`0`,
-		0,
-	).Result(); err != nil {
+func (l *Lookup) resetReceived(ctx context.Context) error {
+	if err := l.cache.ResetReceived(ctx); err != nil {
 		if l.log != nil {
 			l.log.Errorf("eyewall/resetReceived: %s", err.Error())
 		}
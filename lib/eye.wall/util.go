@@ -9,10 +9,13 @@
 package wall // import "github.com/solnx/eye/lib/eye.wall"
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/solnx/eye/lib/eye.proto/v1"
@@ -32,6 +35,17 @@ func foldSlashes(u *url.URL) {
 	}
 }
 
+// CalculateLookupID returns the lookID eye uses for the monitoring
+// profiles of metric on the host with hostID
+func CalculateLookupID(hostID uint64, metric string) string {
+	asset := strconv.FormatUint(hostID, 10)
+	hash := sha256.New()
+	hash.Write([]byte(asset))
+	hash.Write([]byte(metric))
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
 // v1ConfigurationData returns a deserialized v1.ConfigurationData from
 // a response body
 func v1ConfigurationData(body []byte) (data *v1.ConfigurationData, err error) {
@@ -43,7 +57,9 @@ func v1ConfigurationData(body []byte) (data *v1.ConfigurationData, err error) {
 
 // v2Result returns a deserialized v2.Result from a response body
 func v2Result(body []byte) (result *v2.Result, err error) {
+	result = &v2.Result{}
 	if err = json.Unmarshal(body, result); err != nil {
+		result = nil
 		return
 	}
 
@@ -57,7 +73,6 @@ func v2Result(body []byte) (result *v2.Result, err error) {
 		err = ErrUnconfigured
 	default:
 		// there was some error
-		result = nil
 		err = fmt.Errorf("eye(%s|%s) %d/%s: %v",
 			result.Section,
 			result.Action,
@@ -65,6 +80,7 @@ func v2Result(body []byte) (result *v2.Result, err error) {
 			result.StatusText,
 			result.Errors,
 		)
+		result = nil
 	}
 	return
 }
@@ -0,0 +1,131 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// warmupTimeout is the timeout for loading a single lookID during
+// the warm-up
+const warmupTimeout = time.Second
+
+// WarmupProgress reports the progress of a cache warm-up
+type WarmupProgress struct {
+	// Total is the number of lookIDs to load
+	Total int
+	// Done is the number of lookIDs processed so far
+	Done int
+	// Configured is the number of lookIDs with monitoring profiles
+	Configured int
+	// Unconfigured is the number of lookIDs without monitoring
+	// profiles, which have a negative cache entry
+	Unconfigured int
+	// Failed is the number of lookIDs that could not be loaded
+	Failed int
+}
+
+// Warmup loads the monitoring profiles of lookIDs into the local cache,
+// including negative cache entries for lookIDs without profiles. LookIDs
+// are loaded by as many workers as the configured concurrency limit.
+// Their requests to eye share the concurrency limit with all other
+// requests of Lookup, a warm-up running next to live traffic does not
+// exceed it. After every lookID, progress is called if it is not nil.
+// Calls to progress are not concurrent. The returned error is set if
+// ctx is done before all lookIDs are processed.
+func (l *Lookup) Warmup(ctx context.Context, lookIDs []string, progress func(WarmupProgress)) (WarmupProgress, error) {
+	unique := make([]string, 0, len(lookIDs))
+	seen := make(map[string]bool, len(lookIDs))
+	for _, lookID := range lookIDs {
+		if !seen[lookID] {
+			seen[lookID] = true
+			unique = append(unique, lookID)
+		}
+	}
+
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+	state := WarmupProgress{Total: len(unique)}
+	queue := make(chan string)
+
+	workers := int(l.Config.Eyewall.ConcurrencyLimit)
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for lookID := range queue {
+				rqCtx, cancel := context.WithTimeout(ctx, warmupTimeout)
+				thr, err := l.processRequest(rqCtx, lookID)
+				cancel()
+
+				lock.Lock()
+				state.Done++
+				switch {
+				case err == ErrUnconfigured:
+					state.Unconfigured++
+				case err != nil, isStale(thr):
+					state.Failed++
+					if err != nil && l.log != nil {
+						l.log.Errorf("eyewall/warmup: %s: %s", lookID, err.Error())
+					}
+				default:
+					state.Configured++
+				}
+				if progress != nil {
+					progress(state)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+lookloop:
+	for _, lookID := range unique {
+		select {
+		case <-ctx.Done():
+			break lookloop
+		case queue <- lookID:
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return state, ctx.Err()
+}
+
+// WarmupHosts loads the monitoring profiles of metrics on the hosts
+// with hostIDs into the local cache. See Warmup.
+func (l *Lookup) WarmupHosts(ctx context.Context, hostIDs []uint64, metrics []string, progress func(WarmupProgress)) (WarmupProgress, error) {
+	lookIDs := make([]string, 0, len(hostIDs)*len(metrics))
+	for _, hostID := range hostIDs {
+		for _, metric := range metrics {
+			lookIDs = append(lookIDs, CalculateLookupID(hostID, metric))
+		}
+	}
+	return l.Warmup(ctx, lookIDs, progress)
+}
+
+// isStale reports whether thr was served stale since eye could not be
+// queried
+func isStale(thr map[string]Threshold) bool {
+	for _, t := range thr {
+		return t.Stale
+	}
+	return false
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix
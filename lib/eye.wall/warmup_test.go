@@ -0,0 +1,97 @@
+/*-
+ * Copyright © 2018, 1&1 Internet SE
+ * All rights reserved.
+ *
+ * Use of this source code is governed by a 2-clause BSD license
+ * that can be found in the LICENSE file.
+ */
+
+package wall // import "github.com/solnx/eye/lib/eye.wall"
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWarmupPartialFailure(t *testing.T) {
+	eye := &fakeEye{
+		configurations: map[string][]string{
+			`a`: {`p1`},
+			`b`: {`p2`, `p3`},
+		},
+		failing: map[string]bool{`c`: true},
+		lookups: map[string]int{},
+	}
+	l, srv := newTestLookup(t, eye)
+	defer srv.Close()
+
+	calls := 0
+	var last WarmupProgress
+	st, err := l.Warmup(context.Background(),
+		[]string{`a`, `b`, `c`, `d`, `a`},
+		func(p WarmupProgress) {
+			calls++
+			last = p
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	want := WarmupProgress{Total: 4, Done: 4, Configured: 2, Unconfigured: 1, Failed: 1}
+	if st != want {
+		t.Errorf("progress is %+v, want %+v", st, want)
+	}
+	if calls != 4 || last != want {
+		t.Errorf("progress was called %d times, last with %+v", calls, last)
+	}
+
+	if profiles, err := cachedProfiles(l, `b`); err != nil || len(profiles) != 2 {
+		t.Errorf("b is cached as %v/%v, want 2 profiles", profiles, err)
+	}
+	if _, err := cachedProfiles(l, `d`); err != ErrUnconfigured {
+		t.Errorf("d is cached with %v, want %v", err, ErrUnconfigured)
+	}
+	if _, err := cachedProfiles(l, `c`); err != ErrNotFound {
+		t.Errorf("c is cached with %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestWarmupHostsUnconfigured(t *testing.T) {
+	configured := CalculateLookupID(1, `cpu.usage.percent`)
+	eye := &fakeEye{
+		configurations: map[string][]string{configured: {`p1`}},
+		failing:        map[string]bool{},
+		lookups:        map[string]int{},
+	}
+	l, srv := newTestLookup(t, eye)
+	defer srv.Close()
+
+	st, err := l.WarmupHosts(context.Background(),
+		[]uint64{1, 2},
+		[]string{`cpu.usage.percent`, `disk.free`},
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	want := WarmupProgress{Total: 4, Done: 4, Configured: 1, Unconfigured: 3}
+	if st != want {
+		t.Errorf("progress is %+v, want %+v", st, want)
+	}
+
+	// hosts without profiles have negative cache entries and are not
+	// queried again
+	lookID := CalculateLookupID(2, `cpu.usage.percent`)
+	if _, err := l.LookupThresholdContext(context.Background(), lookID); err != ErrUnconfigured {
+		t.Errorf("lookup of unconfigured host returned %v, want %v", err, ErrUnconfigured)
+	}
+	eye.lock.Lock()
+	defer eye.lock.Unlock()
+	if n := eye.lookups[lookID]; n != 1 {
+		t.Errorf("unconfigured host was queried %d times, want 1", n)
+	}
+}
+
+// vim: ts=4 sw=4 sts=4 noet fenc=utf-8 ffs=unix